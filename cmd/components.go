@@ -0,0 +1,747 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/cloudevents"
+	"github.com/kubewarden/audit-scanner/internal/events"
+	"github.com/kubewarden/audit-scanner/internal/gate"
+	"github.com/kubewarden/audit-scanner/internal/history"
+	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/metrics"
+	"github.com/kubewarden/audit-scanner/internal/output"
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/scanner"
+	"github.com/kubewarden/audit-scanner/internal/scheme"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// auditComponents groups the components shared by the commands auditing the cluster.
+type auditComponents struct {
+	logger         *slog.Logger
+	dynamicClient  dynamic.Interface
+	policiesClient *policies.Client
+	k8sClient      *k8s.Client
+	scanner        *scanner.Scanner
+	// summaryStore stores the run summaries, it's nil when they are not stored
+	summaryStore *summary.Store
+	// metrics collects the Prometheus metrics, it's nil when they are disabled
+	metrics *metrics.Metrics
+	// events emits the Kubernetes Events of the failing results, it's nil when they are disabled
+	events *events.Recorder
+	// metricsAddress is the address where the metrics are exposed
+	metricsAddress string
+	// gate evaluates the results of the scan, it's nil when the gating mode is disabled
+	gate *gate.Config
+	// namespaceFilter selects the namespaces to audit
+	namespaceFilter k8s.NamespaceFilter
+	// resultStream receives the reports and the summaries of the scan runs,
+	// it's nil when they are not streamed
+	resultStream *output.Stream
+	// outputFormat is the format the results of the scan runs are rendered
+	// in, it's empty when they are not rendered
+	outputFormat output.Format
+	// outputFile is the file the rendered results are written to, stdout when empty
+	outputFile string
+	// cloudEventsSink sends the reports as CloudEvents, it's nil when they are not sent
+	cloudEventsSink *cloudevents.Sink
+	// files are the files opened for the logs and the streamed results
+	files []*os.File
+}
+
+// closeTimeout is the time given to the components to send the data they
+// still buffer when the command exits.
+const closeTimeout = 10 * time.Second
+
+// newAuditComponents builds the components used to audit the cluster from
+// the flags of the given command.
+//
+//nolint:gocognit,funlen // This function reads all the CLI flags and it's expected to be long.
+func newAuditComponents(cmd *cobra.Command) (_ *auditComponents, err error) {
+	level, err := cmd.Flags().GetString("loglevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loglevel flag: %w", err)
+	}
+	outputScan, err := cmd.Flags().GetBool("output-scan")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-scan flag: %w", err)
+	}
+	outputScanFile, err := cmd.Flags().GetString("output-scan-file")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-scan-file flag: %w", err)
+	}
+	logFile, err := cmd.Flags().GetString("log-file")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log-file flag: %w", err)
+	}
+	outputFormatStr, err := cmd.Flags().GetString("output-format")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-format flag: %w", err)
+	}
+	var outputFormat output.Format
+	if outputFormatStr != "" {
+		outputFormat, err = output.ParseFormat(outputFormatStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse output-format flag: %w", err)
+		}
+	}
+	outputFile, err := cmd.Flags().GetString("output-file")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-file flag: %w", err)
+	}
+	if outputScan && isStdout(outputScanFile) && outputFormat != "" && isStdout(outputFile) {
+		return nil, errors.New("output-scan and output-format cannot both write to stdout: set output-scan-file or output-file")
+	}
+	skippedNs, err := cmd.Flags().GetStringSlice("ignore-namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignore-namespaces flag: %w", err)
+	}
+	insecureSSL, err := cmd.Flags().GetBool("insecure-ssl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get insecure-ssl flag: %w", err)
+	}
+	disableStore, err := cmd.Flags().GetBool("disable-store")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disable-store flag: %w", err)
+	}
+	incremental, err := cmd.Flags().GetBool("incremental")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incremental flag: %w", err)
+	}
+	diff, err := cmd.Flags().GetBool("diff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff flag: %w", err)
+	}
+	// the reports of the previous scans are read from the cluster, not from the
+	// other stores the reports are written to when the store is disabled
+	if incremental && disableStore {
+		return nil, errors.New("incremental scans require the report store to be enabled")
+	}
+	if diff && disableStore {
+		return nil, errors.New("comparing the results with the previous scan requires the report store to be enabled")
+	}
+	emitEvents, err := cmd.Flags().GetBool("emit-events")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emit-events flag: %w", err)
+	}
+	runSummary, err := cmd.Flags().GetBool("run-summary")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run-summary flag: %w", err)
+	}
+	runSummaryHistory, err := cmd.Flags().GetInt("run-summary-history")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run-summary-history flag: %w", err)
+	}
+	metricsAddress, err := cmd.Flags().GetString("metrics-address")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics-address flag: %w", err)
+	}
+	kubewardenNamespace, err := cmd.Flags().GetString("kubewarden-namespace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubewarden-namespace flag: %w", err)
+	}
+	policyServerURL, err := cmd.Flags().GetString("policy-server-url")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy-server-url flag: %w", err)
+	}
+	caFile, err := cmd.Flags().GetString("extra-ca")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extra-ca flag: %w", err)
+	}
+	clientCertFile, err := cmd.Flags().GetString("client-cert")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client-cert flag: %w", err)
+	}
+	clientKeyFile, err := cmd.Flags().GetString("client-key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client-key flag: %w", err)
+	}
+	parallelNamespacesAudits, err := cmd.Flags().GetInt("parallel-namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-namespaces flag: %w", err)
+	}
+	parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-resources flag: %w", err)
+	}
+	parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parallel-policies flag: %w", err)
+	}
+	policyServerPreflight, err := cmd.Flags().GetBool("policy-server-preflight")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy-server-preflight flag: %w", err)
+	}
+	policyServerWait, err := cmd.Flags().GetDuration("policy-server-wait")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy-server-wait flag: %w", err)
+	}
+	maxRetries, err := cmd.Flags().GetInt("max-retries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max-retries flag: %w", err)
+	}
+	retryInitialBackoff, err := cmd.Flags().GetDuration("retry-initial-backoff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry-initial-backoff flag: %w", err)
+	}
+	retryMaxBackoff, err := cmd.Flags().GetDuration("retry-max-backoff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry-max-backoff flag: %w", err)
+	}
+	retryMaxElapsedTime, err := cmd.Flags().GetDuration("retry-max-elapsed-time")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retry-max-elapsed-time flag: %w", err)
+	}
+	circuitBreakerFailures, err := cmd.Flags().GetInt("circuit-breaker-failures")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit-breaker-failures flag: %w", err)
+	}
+	circuitBreakerOpenTimeout, err := cmd.Flags().GetDuration("circuit-breaker-open-timeout")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get circuit-breaker-open-timeout flag: %w", err)
+	}
+	rateLimit, err := getRateLimitConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	scanGate, err := getGateConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := getUserInfoConfig(cmd, kubewardenNamespace)
+	if err != nil {
+		return nil, err
+	}
+	includeResources, err := cmd.Flags().GetStringSlice("include-resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get include-resources flag: %w", err)
+	}
+	excludeResources, err := cmd.Flags().GetStringSlice("exclude-resources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exclude-resources flag: %w", err)
+	}
+	namespaceFilter, err := getNamespaceFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	resourceFilter, err := getWildcardResourceFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	policyFilter, err := getPolicyFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	drainTimeout, err := cmd.Flags().GetDuration("drain-timeout")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drain-timeout flag: %w", err)
+	}
+	pageSize, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page-size flag: %w", err)
+	}
+	reportKindStr, err := cmd.Flags().GetString("report-kind")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report-kind flag: %w", err)
+	}
+
+	var reportKind report.CrdKind
+	switch reportKindStr {
+	case report.OpenReportsKind:
+		reportKind = report.ReportKindOpenReport
+	case report.PolicyReportKind:
+		reportKind = report.ReportKindPolicyReport
+	default:
+		return nil, fmt.Errorf("invalid report-kind '%s': supported values are '%s' and '%s'", reportKindStr, report.OpenReportsKind, report.PolicyReportKind)
+	}
+	outputDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-dir flag: %w", err)
+	}
+	outputDirFormat, err := cmd.Flags().GetString("output-dir-format")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output-dir-format flag: %w", err)
+	}
+	historyDB, err := cmd.Flags().GetString("history-db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history-db flag: %w", err)
+	}
+	historyRuns, err := cmd.Flags().GetInt("history-runs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history-runs flag: %w", err)
+	}
+	reportAggregation, err := cmd.Flags().GetString("report-aggregation")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report-aggregation flag: %w", err)
+	}
+	switch reportAggregation {
+	case report.AggregationResource:
+	case report.AggregationNamespace:
+		if reportKind != report.ReportKindOpenReport {
+			return nil, fmt.Errorf("report-aggregation '%s' requires report-kind '%s'", reportAggregation, report.OpenReportsKind)
+		}
+	default:
+		return nil, fmt.Errorf("invalid report-aggregation '%s': supported values are '%s' and '%s'", reportAggregation, report.AggregationResource, report.AggregationNamespace)
+	}
+
+	config := ctrl.GetConfigOrDie()
+	dynamicClient := dynamic.NewForConfigOrDie(config)
+	clientset := kubernetes.NewForConfigOrDie(config)
+
+	auditScheme, err := scheme.NewScheme()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheme: %w", err)
+	}
+	client, err := client.New(config, client.Options{Scheme: auditScheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	var files []*os.File
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, closeFiles(files))
+		}
+	}()
+	logOutput := io.Writer(os.Stderr)
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gosec // the logs are not sensitive
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the log file: %w", err)
+		}
+		files = append(files, file)
+		logOutput = file
+	}
+	logger := slog.New(NewHandler(logOutput, level))
+	var resultStream *output.Stream
+	if outputScan {
+		resultOutput := io.Writer(os.Stdout)
+		if !isStdout(outputScanFile) {
+			file, err := os.Create(outputScanFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the output-scan file: %w", err)
+			}
+			files = append(files, file)
+			resultOutput = file
+		}
+		resultStream = output.NewStream(resultOutput)
+	}
+	discoveryClient := memory.NewMemCacheClient(clientset.Discovery())
+	policiesClient := policies.NewClient(client, discoveryClient, resourceFilter, policyFilter, kubewardenNamespace, policyServerURL, logger)
+
+	k8sClient := k8s.NewClient(dynamicClient, clientset, kubewardenNamespace, skippedNs, namespaceFilter, int64(pageSize), logger)
+	reportStore := report.NewReportStoreOfKind(reportKind, client, logger)
+	if reportAggregation == report.AggregationNamespace {
+		reportStore = report.NewAggregatedOpenReportStore(client, logger)
+	}
+	// the stores the reports are written to, besides the cluster
+	var extraStores []report.Store
+	if outputDir != "" {
+		fileStore, err := report.NewFileStore(outputDir, outputDirFormat, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the report file store: %w", err)
+		}
+		extraStores = append(extraStores, fileStore)
+	}
+	cloudEventsSink, err := getCloudEventsSink(cmd, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cloudEventsSink != nil {
+		extraStores = append(extraStores, cloudEventsSink)
+	}
+	if historyDB != "" {
+		historyStore, err := history.NewStore(historyDB, historyRuns, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the history store: %w", err)
+		}
+		extraStores = append(extraStores, historyStore)
+	}
+	if len(extraStores) > 0 {
+		if disableStore {
+			// the reports are only written to the other stores
+			reportStore = report.NewMultiStore(extraStores[0], extraStores[1:]...)
+			disableStore = false
+		} else {
+			reportStore = report.NewMultiStore(reportStore, extraStores...)
+		}
+	}
+
+	var scannerMetrics *metrics.Metrics
+	if metricsAddress != "" {
+		scannerMetrics = metrics.NewMetrics()
+	}
+	var eventsRecorder *events.Recorder
+	if emitEvents {
+		eventsRecorder = events.NewRecorder(clientset, logger)
+	}
+
+	scannerConfig := scanner.Config{
+		PoliciesClient: policiesClient,
+		K8sClient:      k8sClient,
+		ReportStore:    reportStore,
+		TLS: scanner.TLSConfig{
+			Insecure:       insecureSSL,
+			CAFile:         caFile,
+			ClientCertFile: clientCertFile,
+			ClientKeyFile:  clientKeyFile,
+		},
+		Parallelization: scanner.ParallelizationConfig{
+			ParallelNamespacesAudits: parallelNamespacesAudits,
+			ParallelResourcesAudits:  parallelResourcesAudits,
+			PoliciesAudits:           parallelPoliciesAudit,
+		},
+		Retry: scanner.RetryConfig{
+			MaxRetries:     maxRetries,
+			InitialBackoff: retryInitialBackoff,
+			MaxBackoff:     retryMaxBackoff,
+			MaxElapsedTime: retryMaxElapsedTime,
+		},
+		CircuitBreaker: scanner.CircuitBreakerConfig{
+			FailureThreshold: circuitBreakerFailures,
+			OpenTimeout:      circuitBreakerOpenTimeout,
+		},
+		RateLimit: rateLimit,
+		Preflight: scanner.PreflightConfig{
+			Enabled:     policyServerPreflight,
+			WaitTimeout: policyServerWait,
+		},
+		UserInfo: userInfo,
+		ResourceFilter: scanner.ResourceFilter{
+			Include: includeResources,
+			Exclude: excludeResources,
+		},
+		DrainTimeout: drainTimeout,
+		ResultStream: resultStream,
+		DisableStore: disableStore,
+		Incremental:  incremental,
+		Diff:         diff,
+		Metrics:      scannerMetrics,
+		Events:       eventsRecorder,
+		Logger:       logger.With("component", "scanner"),
+		ReportKind:   reportKind,
+	}
+
+	scanner, err := scanner.NewScanner(scannerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scanner: %w", err)
+	}
+
+	var summaryStore *summary.Store
+	if runSummary {
+		if runSummaryHistory < 1 {
+			return nil, errors.New("run-summary-history must be greater than zero")
+		}
+		summaryStore = summary.NewStore(client, kubewardenNamespace, runSummaryHistory, logger)
+	}
+
+	return &auditComponents{
+		logger:         logger,
+		dynamicClient:  dynamicClient,
+		policiesClient: policiesClient,
+		k8sClient:      k8sClient,
+		scanner:        scanner,
+		summaryStore:   summaryStore,
+		metrics:        scannerMetrics,
+		events:         eventsRecorder,
+		metricsAddress: metricsAddress,
+		gate:           scanGate,
+		// the namespace filter is also applied by the k8sClient, it's kept
+		// here to compute the scope of the scans
+		namespaceFilter: namespaceFilter,
+		resultStream:    resultStream,
+		outputFormat:    outputFormat,
+		outputFile:      outputFile,
+		cloudEventsSink: cloudEventsSink,
+		files:           files,
+	}, nil
+}
+
+// close releases the components, sending the data they still buffer, then
+// closes the files, which are written until then. It returns an error if some
+// of the data is lost.
+func (c *auditComponents) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	// the events recorded since the last scan run, like in watch mode, are
+	// sent before stopping the recorder
+	c.events.Flush(ctx)
+	c.events.Shutdown()
+
+	var errs []error
+	if err := c.cloudEventsSink.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close the CloudEvents sink: %w", err))
+	}
+	errs = append(errs, closeFiles(c.files))
+	return errors.Join(errs...)
+}
+
+// closeFiles flushes the given files to the disk and closes them.
+func closeFiles(files []*os.File) error {
+	var errs []error
+	for _, file := range files {
+		if err := file.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync %s: %w", file.Name(), err))
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", file.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isStdout returns true if the given output file, as set by the flags, is stdout.
+func isStdout(file string) bool {
+	return file == "" || file == "-"
+}
+
+// serveMetrics exposes the metrics in background until the context is
+// canceled, if they are enabled.
+func (c *auditComponents) serveMetrics(ctx context.Context) {
+	if c.metrics == nil {
+		return
+	}
+
+	c.logger.InfoContext(ctx, "exposing metrics", slog.String("address", c.metricsAddress))
+	go func() {
+		if err := c.metrics.Serve(ctx, c.metricsAddress); err != nil {
+			c.logger.ErrorContext(ctx, "error exposing metrics", slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// getCloudEventsSink builds the sink sending the reports as CloudEvents from
+// the flags. It returns nil when no endpoint is set.
+func getCloudEventsSink(cmd *cobra.Command, logger *slog.Logger) (*cloudevents.Sink, error) {
+	endpoint, err := cmd.Flags().GetString("cloudevents-endpoint")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-endpoint flag: %w", err)
+	}
+	if endpoint == "" {
+		return nil, nil //nolint:nilnil // the sink is disabled
+	}
+	source, err := cmd.Flags().GetString("cloudevents-source")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-source flag: %w", err)
+	}
+	typePrefix, err := cmd.Flags().GetString("cloudevents-type-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-type-prefix flag: %w", err)
+	}
+	batchSize, err := cmd.Flags().GetInt("cloudevents-batch-size")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-batch-size flag: %w", err)
+	}
+	maxRetries, err := cmd.Flags().GetInt("cloudevents-max-retries")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-max-retries flag: %w", err)
+	}
+	caFile, err := cmd.Flags().GetString("cloudevents-ca")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-ca flag: %w", err)
+	}
+	clientCertFile, err := cmd.Flags().GetString("cloudevents-client-cert")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-client-cert flag: %w", err)
+	}
+	clientKeyFile, err := cmd.Flags().GetString("cloudevents-client-key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloudevents-client-key flag: %w", err)
+	}
+
+	sink, err := cloudevents.NewSink(cloudevents.Config{
+		Endpoint:       endpoint,
+		Source:         source,
+		TypePrefix:     typePrefix,
+		BatchSize:      batchSize,
+		QueueSize:      cloudevents.DefaultQueueSize,
+		MaxRetries:     maxRetries,
+		InitialBackoff: cloudevents.DefaultInitialBackoff,
+		MaxBackoff:     cloudevents.DefaultMaxBackoff,
+		TLS: cloudevents.TLSConfig{
+			CAFile:         caFile,
+			ClientCertFile: clientCertFile,
+			ClientKeyFile:  clientKeyFile,
+		},
+		Logger: logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CloudEvents sink: %w", err)
+	}
+	return sink, nil
+}
+
+// getRateLimitConfig builds the PolicyServers rate limits from the flags.
+func getRateLimitConfig(cmd *cobra.Command) (scanner.RateLimitConfig, error) {
+	qps, err := cmd.Flags().GetFloat64("policy-server-qps")
+	if err != nil {
+		return scanner.RateLimitConfig{}, fmt.Errorf("failed to get policy-server-qps flag: %w", err)
+	}
+	burst, err := cmd.Flags().GetInt("policy-server-burst")
+	if err != nil {
+		return scanner.RateLimitConfig{}, fmt.Errorf("failed to get policy-server-burst flag: %w", err)
+	}
+	maxInFlight, err := cmd.Flags().GetInt("policy-server-max-in-flight")
+	if err != nil {
+		return scanner.RateLimitConfig{}, fmt.Errorf("failed to get policy-server-max-in-flight flag: %w", err)
+	}
+	overrides, err := cmd.Flags().GetStringSlice("policy-server-rate-limit")
+	if err != nil {
+		return scanner.RateLimitConfig{}, fmt.Errorf("failed to get policy-server-rate-limit flag: %w", err)
+	}
+
+	config := scanner.RateLimitConfig{
+		Default:   scanner.RateLimit{QPS: qps, Burst: burst, MaxInFlight: maxInFlight},
+		Overrides: make(map[string]scanner.RateLimit),
+	}
+	for _, override := range overrides {
+		policyServer, rateLimit, err := scanner.ParseRateLimitOverride(override)
+		if err != nil {
+			return scanner.RateLimitConfig{}, fmt.Errorf("failed to parse policy-server-rate-limit flag: %w", err)
+		}
+		config.Overrides[policyServer] = rateLimit
+	}
+
+	return config, nil
+}
+
+// getUserInfoConfig returns the user sending the simulated admission requests.
+// The audit identity defaults to the ServiceAccount of the scanner.
+func getUserInfoConfig(cmd *cobra.Command, kubewardenNamespace string) (scanner.UserInfoConfig, error) {
+	mode, err := cmd.Flags().GetString("user-info-mode")
+	if err != nil {
+		return scanner.UserInfoConfig{}, fmt.Errorf("failed to get user-info-mode flag: %w", err)
+	}
+	username, err := cmd.Flags().GetString("audit-user")
+	if err != nil {
+		return scanner.UserInfoConfig{}, fmt.Errorf("failed to get audit-user flag: %w", err)
+	}
+	groups, err := cmd.Flags().GetStringSlice("audit-groups")
+	if err != nil {
+		return scanner.UserInfoConfig{}, fmt.Errorf("failed to get audit-groups flag: %w", err)
+	}
+
+	if username == "" {
+		username = fmt.Sprintf("system:serviceaccount:%s:%s", kubewardenNamespace, auditScannerServiceAccount)
+	}
+	if !cmd.Flags().Changed("audit-groups") {
+		groups = []string{"system:serviceaccounts", "system:serviceaccounts:" + kubewardenNamespace, "system:authenticated"}
+	}
+
+	return scanner.UserInfoConfig{
+		Mode:     scanner.UserInfoMode(mode),
+		Username: username,
+		Groups:   groups,
+	}, nil
+}
+
+// getGateConfig builds the gate from the flags. It returns nil when none of
+// the gating flags is set.
+func getGateConfig(cmd *cobra.Command) (*gate.Config, error) {
+	failOn, err := cmd.Flags().GetString("fail-on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fail-on flag: %w", err)
+	}
+	maxFailures, err := cmd.Flags().GetInt("max-failures")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max-failures flag: %w", err)
+	}
+	gatePolicies, err := cmd.Flags().GetStringSlice("fail-on-policies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fail-on-policies flag: %w", err)
+	}
+	if failOn == "" && maxFailures < 0 && len(gatePolicies) == 0 {
+		return nil, nil //nolint:nilnil // the gating mode is disabled
+	}
+
+	config := &gate.Config{
+		MaxFailures: max(maxFailures, 0),
+		Policies:    gatePolicies,
+	}
+	if failOn != "" {
+		config.MinSeverity, err = gate.ParseFailOn(failOn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fail-on flag: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// getNamespaceFilter returns the filter selecting the namespaces to audit.
+func getNamespaceFilter(cmd *cobra.Command) (k8s.NamespaceFilter, error) {
+	namespaces, err := cmd.Flags().GetStringSlice("namespace")
+	if err != nil {
+		return k8s.NamespaceFilter{}, fmt.Errorf("failed to get namespace flag: %w", err)
+	}
+	selector, err := cmd.Flags().GetString("namespace-selector")
+	if err != nil {
+		return k8s.NamespaceFilter{}, fmt.Errorf("failed to get namespace-selector flag: %w", err)
+	}
+	include, err := cmd.Flags().GetStringSlice("include-namespaces")
+	if err != nil {
+		return k8s.NamespaceFilter{}, fmt.Errorf("failed to get include-namespaces flag: %w", err)
+	}
+	exclude, err := cmd.Flags().GetStringSlice("exclude-namespaces")
+	if err != nil {
+		return k8s.NamespaceFilter{}, fmt.Errorf("failed to get exclude-namespaces flag: %w", err)
+	}
+
+	namespaceFilter, err := k8s.NewNamespaceFilter(namespaces, selector, include, exclude)
+	if err != nil {
+		return k8s.NamespaceFilter{}, fmt.Errorf("invalid namespace selection: %w", err)
+	}
+	return namespaceFilter, nil
+}
+
+// getPolicyFilter returns the filter selecting the audited policies.
+func getPolicyFilter(cmd *cobra.Command) (policies.PolicyFilter, error) {
+	names, err := cmd.Flags().GetStringSlice("policy")
+	if err != nil {
+		return policies.PolicyFilter{}, fmt.Errorf("failed to get policy flag: %w", err)
+	}
+	selector, err := cmd.Flags().GetString("policy-selector")
+	if err != nil {
+		return policies.PolicyFilter{}, fmt.Errorf("failed to get policy-selector flag: %w", err)
+	}
+
+	policyFilter, err := policies.NewPolicyFilter(names, selector)
+	if err != nil {
+		return policies.PolicyFilter{}, fmt.Errorf("invalid policy selection: %w", err)
+	}
+	return policyFilter, nil
+}
+
+// getWildcardResourceFilter returns the filter bounding the expansion of the wildcard rules.
+func getWildcardResourceFilter(cmd *cobra.Command) (policies.ResourceFilter, error) {
+	include, err := cmd.Flags().GetStringSlice("wildcard-include-resources")
+	if err != nil {
+		return policies.ResourceFilter{}, fmt.Errorf("failed to get wildcard-include-resources flag: %w", err)
+	}
+	exclude, err := cmd.Flags().GetStringSlice("wildcard-exclude-resources")
+	if err != nil {
+		return policies.ResourceFilter{}, fmt.Errorf("failed to get wildcard-exclude-resources flag: %w", err)
+	}
+
+	resourceFilter := policies.ResourceFilter{
+		Include: include,
+		Exclude: exclude,
+	}
+	if err := resourceFilter.Validate(); err != nil {
+		return policies.ResourceFilter{}, fmt.Errorf("invalid wildcard resources: %w", err)
+	}
+
+	return resourceFilter, nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+// Exit codes of the audit scanner.
+const (
+	exitCodeError = 1
+	// exitCodeInterrupted is returned when the scan is interrupted by a
+	// signal before completing
+	exitCodeInterrupted = 2
+	// exitCodeViolations is returned in gating mode when the scan found more
+	// policy violations than tolerated
+	exitCodeViolations = 3
+	// exitCodeScanErrors is returned in gating mode when the scan could not
+	// audit all the resources
+	exitCodeScanErrors = 4
+)
+
+// exitError is an error making the audit scanner exit with a specific code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
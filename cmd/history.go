@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// Formats of the history command output.
+const (
+	historyOutputTable = "table"
+	historyOutputJSON  = "json"
+)
+
+func newHistoryCommand() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Shows the trends of the results recorded by --history-db",
+		Long: `Reads the local database the results of the scan runs are recorded in with --history-db, and shows how
+the results of each namespace, policy or resource changed across the runs. It doesn't need access to the cluster.
+The database cannot be read while a scanner is writing to it, like in watch mode.`,
+		Example: `  audit-scanner history --history-db audit.db
+  audit-scanner history --history-db audit.db --by policy --runs 5
+  audit-scanner history --history-db audit.db --by resource --filter-namespace default --output json`,
+		Args: cobra.NoArgs,
+
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			historyDB, err := cmd.Flags().GetString("history-db")
+			if err != nil {
+				return fmt.Errorf("failed to get history-db flag: %w", err)
+			}
+			if historyDB == "" {
+				return errors.New("--history-db is required")
+			}
+			query, err := getHistoryQuery(cmd)
+			if err != nil {
+				return err
+			}
+			outputFormat, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return fmt.Errorf("failed to get output flag: %w", err)
+			}
+			if outputFormat != historyOutputTable && outputFormat != historyOutputJSON {
+				return fmt.Errorf("invalid output '%s': supported values are '%s' and '%s'", outputFormat, historyOutputTable, historyOutputJSON)
+			}
+
+			historyStore, err := history.OpenReadOnly(historyDB)
+			if err != nil {
+				return err //nolint:wrapcheck // the history already wraps the errors with context
+			}
+			defer historyStore.Close()
+			trends, err := historyStore.Trends(query)
+			if err != nil {
+				return err //nolint:wrapcheck // the history already wraps the errors with context
+			}
+
+			if outputFormat == historyOutputJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(trends); err != nil {
+					return fmt.Errorf("failed to write the trends: %w", err)
+				}
+				return nil
+			}
+			return writeHistoryTable(cmd.OutOrStdout(), query.GroupBy, trends)
+		},
+	}
+
+	historyCmd.Flags().String("by", string(history.GroupByNamespace), fmt.Sprintf("dimension the results are grouped by. Supported values are: %q", history.SupportedGroupBy()))
+	historyCmd.Flags().String("filter-namespace", "", fmt.Sprintf("only show the results of the given namespace, '%s' for the cluster-wide resources", history.ClusterKey))
+	historyCmd.Flags().String("filter-policy", "", "only show the results of the given policy, by unique name (e.g. 'clusterwide-my-policy')")
+	historyCmd.Flags().String("filter-resource", "", "only show the results of the given resource, as namespace/Kind/name or Kind/name for the cluster-wide resources")
+	historyCmd.Flags().Int("runs", 0, "number of latest scan runs shown. Zero shows all the recorded runs")
+	historyCmd.Flags().String("output", historyOutputTable, fmt.Sprintf("format of the trends. Supported values are '%s' and '%s'", historyOutputTable, historyOutputJSON))
+
+	return historyCmd
+}
+
+func getHistoryQuery(cmd *cobra.Command) (history.Query, error) {
+	by, err := cmd.Flags().GetString("by")
+	if err != nil {
+		return history.Query{}, fmt.Errorf("failed to get by flag: %w", err)
+	}
+	groupBy, err := history.ParseGroupBy(by)
+	if err != nil {
+		return history.Query{}, err //nolint:wrapcheck // the error already describes the flag value
+	}
+	namespace, err := cmd.Flags().GetString("filter-namespace")
+	if err != nil {
+		return history.Query{}, fmt.Errorf("failed to get filter-namespace flag: %w", err)
+	}
+	policy, err := cmd.Flags().GetString("filter-policy")
+	if err != nil {
+		return history.Query{}, fmt.Errorf("failed to get filter-policy flag: %w", err)
+	}
+	resource, err := cmd.Flags().GetString("filter-resource")
+	if err != nil {
+		return history.Query{}, fmt.Errorf("failed to get filter-resource flag: %w", err)
+	}
+	runs, err := cmd.Flags().GetInt("runs")
+	if err != nil {
+		return history.Query{}, fmt.Errorf("failed to get runs flag: %w", err)
+	}
+	if runs < 0 {
+		return history.Query{}, errors.New("--runs cannot be negative")
+	}
+
+	return history.Query{
+		GroupBy:   groupBy,
+		Namespace: namespace,
+		Policy:    policy,
+		Resource:  resource,
+		Runs:      runs,
+	}, nil
+}
+
+// writeHistoryTable writes a row for each run of each trend, with the change
+// of the failing results since the previous run of the trend.
+func writeHistoryTable(w io.Writer, groupBy history.GroupBy, trends []history.Trend) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "%s\tRUN\tTIME\tPASS\tFAIL\tWARN\tERROR\tSKIP\tFAIL CHANGE\n", groupByHeader(groupBy))
+	for _, trend := range trends {
+		for i, point := range trend.Points {
+			change := "-"
+			if i > 0 {
+				change = fmt.Sprintf("%+d", point.Summary.Fail-trend.Points[i-1].Summary.Fail)
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+				trend.Key, point.RunUID, point.Time.Format(time.RFC3339),
+				point.Summary.Pass, point.Summary.Fail, point.Summary.Warn, point.Summary.Error, point.Summary.Skip, change)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("failed to write the trends: %w", err)
+	}
+	return nil
+}
+
+func groupByHeader(groupBy history.GroupBy) string {
+	switch groupBy {
+	case history.GroupByPolicy:
+		return "POLICY"
+	case history.GroupByResource:
+		return "RESOURCE"
+	default:
+		return "NAMESPACE"
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/audit-scanner/internal/manifests"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/spf13/cobra"
+)
+
+func newManifestsCommand() *cobra.Command {
+	manifestsCmd := &cobra.Command{
+		Use:   "manifests <path>...",
+		Short: "Audits the resources defined in manifest files with your already deployed Kubewarden policies",
+		Long: `Reads the resources defined in YAML or JSON manifests and evaluates them with the policies deployed in the cluster,
+without creating them. This allows to check the rendered output of Helm charts or Kustomize overlays before it
+reaches the cluster. A path can be a file, a directory, whose YAML and JSON files are read recursively, or '-'
+to read from the standard input. Files can contain multiple YAML documents.
+The reports are not stored in the cluster, they are printed to stdout unless --output-scan=false is given.`,
+		Example: `  helm template my-chart | audit-scanner manifests -
+  kustomize build overlays/staging | audit-scanner manifests - --fail-on 'severity>=high'
+  audit-scanner manifests deploy/`,
+		Args: cobra.MinimumNArgs(1),
+
+		RunE: func(cmd *cobra.Command, paths []string) (err error) {
+			resources, err := manifests.Load(paths, os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to load the manifests: %w", err)
+			}
+
+			// the reports of the manifests are printed, unless explicitly disabled
+			if !cmd.Flags().Changed("output-scan") {
+				if err := cmd.Flags().Set("output-scan", "true"); err != nil {
+					return fmt.Errorf("failed to set output-scan flag: %w", err)
+				}
+			}
+			components, err := newAuditComponents(cmd)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err = errors.Join(err, components.close())
+			}()
+
+			runUID := uuid.New().String()
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			context.AfterFunc(ctx, stop)
+
+			data, err := components.run(ctx, summary.ScopeManifests, "", runUID, func(ctx context.Context) error {
+				return components.scanner.ScanManifests(ctx, resources, runUID) //nolint:wrapcheck // the scanner already wraps the errors with context
+			})
+			return components.checkRun(ctx, data, err)
+		},
+	}
+
+	return manifestsCmd
+}
@@ -2,41 +2,59 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/kubewarden/audit-scanner/internal/k8s"
-	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/cloudevents"
+	"github.com/kubewarden/audit-scanner/internal/gate"
+	"github.com/kubewarden/audit-scanner/internal/output"
 	"github.com/kubewarden/audit-scanner/internal/report"
 	"github.com/kubewarden/audit-scanner/internal/scanner"
-	"github.com/kubewarden/audit-scanner/internal/scheme"
+	"github.com/kubewarden/audit-scanner/internal/summary"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	defaultKubewardenNamespace = "kubewarden"
-	defaultParallelResources   = 100
-	defaultParallelPolicies    = 5
-	defaultParallelNamespaces  = 1
-	defaultPageSize            = 100
+	defaultKubewardenNamespace       = "kubewarden"
+	defaultParallelResources         = 100
+	defaultParallelPolicies          = 5
+	defaultParallelNamespaces        = 1
+	defaultPageSize                  = 100
+	defaultRunSummaryHistory         = 10
+	defaultHistoryRuns               = 100
+	defaultMaxRetries                = 3
+	defaultRetryInitialBackoff       = 500 * time.Millisecond
+	defaultRetryMaxBackoff           = 10 * time.Second
+	defaultRetryMaxElapsedTime       = 30 * time.Second
+	defaultCircuitBreakerFailures    = 5
+	defaultCircuitBreakerOpenTimeout = 30 * time.Second
+	defaultDrainTimeout              = 20 * time.Second
+	auditScannerServiceAccount       = "audit-scanner"
 )
 
-//nolint:gocognit,funlen // This function is the CLI entrypoint and it's expected to be long.
-func NewRootCommand() *cobra.Command {
-	var (
-		level        string   // log level.
-		outputScan   bool     // print result of scan as JSON to stdout.
-		skippedNs    []string // list of namespaces to be skipped from scan.
-		insecureSSL  bool     // skip SSL cert validation when connecting to PolicyServers endpoints.
-		disableStore bool     // disable storing the results in the k8s cluster.
-	)
+// defaultWildcardExcludedResources returns the resources the wildcard rules are
+// not expanded to by default: they are frequently updated and not meaningful
+// to audit, like the events and the reports written by the scanner itself.
+func defaultWildcardExcludedResources() []string {
+	return []string{
+		"events",
+		"events.events.k8s.io",
+		"leases.coordination.k8s.io",
+		"policyreports.wgpolicyk8s.io",
+		"clusterpolicyreports.wgpolicyk8s.io",
+		"reports.openreports.io",
+		"clusterreports.openreports.io",
+	}
+}
 
+func NewRootCommand() *cobra.Command {
 	// rootCmd represents the base command when called without any subcommands.
 	rootCmd := &cobra.Command{
 		Use:   "audit-scanner",
@@ -45,110 +63,20 @@ func NewRootCommand() *cobra.Command {
 Each namespace will have a PolicyReport with the outcome of the scan for resources within this namespace.
 There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			namespace, err := cmd.Flags().GetString("namespace")
-			if err != nil {
-				return fmt.Errorf("failed to get namespace flag %w", err)
-			}
-			kubewardenNamespace, err := cmd.Flags().GetString("kubewarden-namespace")
-			if err != nil {
-				return fmt.Errorf("failed to get kubewarden-namespace flag: %w", err)
-			}
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
 			clusterWide, err := cmd.Flags().GetBool("cluster")
 			if err != nil {
 				return fmt.Errorf("failed to get cluster flag: %w", err)
 			}
-			policyServerURL, err := cmd.Flags().GetString("policy-server-url")
-			if err != nil {
-				return fmt.Errorf("failed to get policy-server-url flag: %w", err)
-			}
-			caFile, err := cmd.Flags().GetString("extra-ca")
-			if err != nil {
-				return fmt.Errorf("failed to get extra-ca flag: %w", err)
-			}
-			clientCertFile, err := cmd.Flags().GetString("client-cert")
-			if err != nil {
-				return fmt.Errorf("failed to get client-cert flag: %w", err)
-			}
-			clientKeyFile, err := cmd.Flags().GetString("client-key")
-			if err != nil {
-				return fmt.Errorf("failed to get client-key flag: %w", err)
-			}
-			parallelNamespacesAudits, err := cmd.Flags().GetInt("parallel-namespaces")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-namespaces flag: %w", err)
-			}
-			parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-resources flag: %w", err)
-			}
-			parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-policies flag: %w", err)
-			}
-			pageSize, err := cmd.Flags().GetInt("page-size")
-			if err != nil {
-				return fmt.Errorf("failed to get page-size flag: %w", err)
-			}
-			reportKindStr, err := cmd.Flags().GetString("report-kind")
-			if err != nil {
-				return fmt.Errorf("failed to get report-kind flag: %w", err)
-			}
-
-			var reportKind report.CrdKind
-			switch reportKindStr {
-			case report.OpenReportsKind:
-				reportKind = report.ReportKindOpenReport
-			case report.PolicyReportKind:
-				reportKind = report.ReportKindPolicyReport
-			default:
-				return fmt.Errorf("invalid report-kind '%s': supported values are '%s' and '%s'", reportKindStr, report.OpenReportsKind, report.PolicyReportKind)
-			}
 
-			config := ctrl.GetConfigOrDie()
-			dynamicClient := dynamic.NewForConfigOrDie(config)
-			clientset := kubernetes.NewForConfigOrDie(config)
-
-			auditScheme, err := scheme.NewScheme()
-			if err != nil {
-				return fmt.Errorf("failed to create scheme: %w", err)
-			}
-			client, err := client.New(config, client.Options{Scheme: auditScheme})
+			components, err := newAuditComponents(cmd)
 			if err != nil {
-				return fmt.Errorf("failed to create kubernetes client: %w", err)
+				return err
 			}
-			logger := slog.New(NewHandler(os.Stdout, level))
-			policiesClient := policies.NewClient(client, kubewardenNamespace, policyServerURL, logger)
-
-			k8sClient := k8s.NewClient(dynamicClient, clientset, kubewardenNamespace, skippedNs, int64(pageSize), logger)
-			reportStore := report.NewReportStoreOfKind(reportKind, client, logger)
-
-			scannerConfig := scanner.Config{
-				PoliciesClient: policiesClient,
-				K8sClient:      k8sClient,
-				ReportStore:    reportStore,
-				TLS: scanner.TLSConfig{
-					Insecure:       insecureSSL,
-					CAFile:         caFile,
-					ClientCertFile: clientCertFile,
-					ClientKeyFile:  clientKeyFile,
-				},
-				Parallelization: scanner.ParallelizationConfig{
-					ParallelNamespacesAudits: parallelNamespacesAudits,
-					ParallelResourcesAudits:  parallelResourcesAudits,
-					PoliciesAudits:           parallelPoliciesAudit,
-				},
-				OutputScan:   outputScan,
-				DisableStore: disableStore,
-				Logger:       logger.With("component", "scanner"),
-				ReportKind:   reportKind,
-			}
-
-			scanner, err := scanner.NewScanner(scannerConfig)
-			if err != nil {
-				return fmt.Errorf("failed to create scanner: %w", err)
-			}
-			return startScanner(namespace, clusterWide, scanner)
+			defer func() {
+				err = errors.Join(err, components.close())
+			}()
+			return startScanner(clusterWide, components)
 		},
 	}
 
@@ -156,24 +84,80 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 	rootCmd.SilenceErrors = true
 	rootCmd.SilenceUsage = true
 
-	rootCmd.Flags().StringP("namespace", "n", "", "namespace to be evaluated")
-	rootCmd.Flags().BoolP("cluster", "c", false, "scan cluster wide resources")
-	rootCmd.Flags().StringP("kubewarden-namespace", "k", defaultKubewardenNamespace, "namespace where the Kubewarden components (e.g. PolicyServer) are installed (required)")
-	rootCmd.Flags().StringP("policy-server-url", "u", "", "URI to the PolicyServers the Audit Scanner will query. Example: https://localhost:3000. Useful for out-of-cluster debugging")
-	rootCmd.Flags().StringVarP(&level, "loglevel", "l", "", fmt.Sprintf("level of the logs. Supported values are: %v", SupportedLogLevels()))
-	rootCmd.Flags().BoolVarP(&outputScan, "output-scan", "o", false, "print result of scan in JSON to stdout")
-	rootCmd.Flags().StringSliceVarP(&skippedNs, "ignore-namespaces", "i", nil, "comma separated list of namespace names to be skipped from scan. This flag can be repeated")
-	rootCmd.Flags().BoolVar(&insecureSSL, "insecure-ssl", false, "skip SSL cert validation when connecting to PolicyServers endpoints. Useful for development")
-	rootCmd.Flags().StringP("extra-ca", "f", "", "File path to CA cert in PEM format of PolicyServer endpoints")
-	rootCmd.Flags().StringP("client-cert", "", "", "File path to client cert in PEM format used for mTLS communication with the PolicyServer endpoints")
-	rootCmd.Flags().StringP("client-key", "", "", "File path to client key in PEM format used for mTLS communication with the PolicyServer endpoints")
+	rootCmd.PersistentFlags().StringSliceP("namespace", "n", nil, "namespaces to be evaluated. This flag can be repeated")
+	rootCmd.PersistentFlags().String("namespace-selector", "", "label selector of the namespaces to be evaluated, e.g. 'team=payments,env!=dev'")
+	rootCmd.PersistentFlags().StringSlice("include-namespaces", nil, "patterns of the names of the namespaces to be evaluated, e.g. 'team-*'. Patterns prefixed by 'regex:' are regular expressions matching the whole name. This flag can be repeated")
+	rootCmd.PersistentFlags().StringSlice("exclude-namespaces", nil, "patterns of the names of the namespaces to be skipped from scan, e.g. '*-sandbox'. Patterns prefixed by 'regex:' are regular expressions matching the whole name. This flag can be repeated")
+	rootCmd.PersistentFlags().BoolP("cluster", "c", false, "scan cluster wide resources")
+	rootCmd.PersistentFlags().StringP("kubewarden-namespace", "k", defaultKubewardenNamespace, "namespace where the Kubewarden components (e.g. PolicyServer) are installed (required)")
+	rootCmd.PersistentFlags().StringP("policy-server-url", "u", "", "URI to the PolicyServers the Audit Scanner will query. Example: https://localhost:3000. Useful for out-of-cluster debugging")
+	rootCmd.PersistentFlags().StringP("loglevel", "l", "", fmt.Sprintf("level of the logs. Supported values are: %v", SupportedLogLevels()))
+	rootCmd.PersistentFlags().BoolP("output-scan", "o", false, "print the reports of the scan to stdout as newline-delimited JSON, one record per report, and per change with --diff, followed by a summary record")
+	rootCmd.PersistentFlags().String("output-scan-file", "", "file the reports printed by --output-scan are written to. They are written to stdout when empty or '-'")
+	rootCmd.PersistentFlags().String("log-file", "", "file the logs are appended to. They are written to stderr when empty")
+	rootCmd.PersistentFlags().String("output-format", "", fmt.Sprintf("render the results of the scan, once completed, in the given format. Supported values are: %q", output.SupportedFormats()))
+	rootCmd.PersistentFlags().String("output-file", "", "file the results rendered by --output-format are written to. They are written to stdout when empty or '-'")
+	rootCmd.PersistentFlags().StringSliceP("ignore-namespaces", "i", nil, "comma separated list of namespace names to be skipped from scan. This flag can be repeated")
+	rootCmd.PersistentFlags().Bool("insecure-ssl", false, "skip SSL cert validation when connecting to PolicyServers endpoints. Useful for development")
+	rootCmd.PersistentFlags().StringP("extra-ca", "f", "", "File path to CA cert in PEM format of PolicyServer endpoints")
+	rootCmd.PersistentFlags().StringP("client-cert", "", "", "File path to client cert in PEM format used for mTLS communication with the PolicyServer endpoints")
+	rootCmd.PersistentFlags().StringP("client-key", "", "", "File path to client key in PEM format used for mTLS communication with the PolicyServer endpoints")
 	rootCmd.MarkFlagsRequiredTogether("client-cert", "client-key")
-	rootCmd.Flags().BoolVar(&disableStore, "disable-store", false, "disable storing the results in the k8s cluster")
-	rootCmd.Flags().IntP("parallel-namespaces", "", defaultParallelNamespaces, "number of Namespaces to scan in parallel")
-	rootCmd.Flags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel")
-	rootCmd.Flags().IntP("parallel-policies", "", defaultParallelPolicies, "number of policies to evaluate for a given resource in parallel")
-	rootCmd.Flags().IntP("page-size", "", defaultPageSize, "number of resources to fetch from the Kubernetes API server when paginating")
-	rootCmd.Flags().StringP("report-kind", "", report.PolicyReportKind, "Report resouce kind to be used. Supported values are 'openreport' and 'policyreport'")
+	rootCmd.PersistentFlags().Bool("disable-store", false, "disable storing the results in the k8s cluster")
+	rootCmd.PersistentFlags().Bool("run-summary", false, "store a summary of each scan run in a ConfigMap in the Kubewarden namespace")
+	rootCmd.PersistentFlags().Int("run-summary-history", defaultRunSummaryHistory, "number of run summaries to keep in the Kubewarden namespace")
+	rootCmd.PersistentFlags().String("metrics-address", "", "address where the Prometheus metrics are exposed, e.g. ':8080'. Metrics are disabled when empty")
+	rootCmd.PersistentFlags().Bool("incremental", false, "reuse the results of the previous scan for resources and policies that did not change since then")
+	rootCmd.PersistentFlags().Bool("diff", false, "compare the results with the reports of the previous scan before overwriting them, and report the results that went from pass to fail, from fail to pass or from error to another status, and the resources that disappeared. The changes are logged, written to the --output-scan stream and recorded in the run summary")
+	rootCmd.PersistentFlags().Bool("emit-events", false, "emit a Warning Event on the audited resources for each policy they fail, shown by 'kubectl describe'. The events are deduplicated and rate-limited by resource")
+	rootCmd.PersistentFlags().IntP("parallel-namespaces", "", defaultParallelNamespaces, "number of Namespaces to scan in parallel")
+	rootCmd.PersistentFlags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel")
+	rootCmd.PersistentFlags().IntP("parallel-policies", "", defaultParallelPolicies, "number of policies to evaluate for a given resource in parallel")
+	rootCmd.PersistentFlags().Bool("policy-server-preflight", false, "check the health of the PolicyServers before each scan: the policies of the PolicyServers whose Deployment has no ready replicas, or whose audit endpoint is unavailable, are recorded as errored once and not evaluated")
+	rootCmd.PersistentFlags().Duration("policy-server-wait", 0, "time given to the unhealthy PolicyServers to recover before scanning without them, when --policy-server-preflight is set. Zero scans without them right away")
+	rootCmd.PersistentFlags().Int("max-retries", defaultMaxRetries, "number of retries of the evaluations failing because of a transient PolicyServer error (network errors, 429, 502, 503 and 504 status codes). Zero disables the retries")
+	rootCmd.PersistentFlags().Duration("retry-initial-backoff", defaultRetryInitialBackoff, "delay before the first retry of an evaluation. The delay doubles at each retry")
+	rootCmd.PersistentFlags().Duration("retry-max-backoff", defaultRetryMaxBackoff, "maximum delay between two retries of an evaluation")
+	rootCmd.PersistentFlags().Duration("retry-max-elapsed-time", defaultRetryMaxElapsedTime, "maximum time spent evaluating a policy, retries included. Zero means no limit")
+	rootCmd.PersistentFlags().Int("circuit-breaker-failures", defaultCircuitBreakerFailures, "number of consecutive requests to a PolicyServer failing because of a network error or a 502, 503 or 504 status code after which its circuit opens: its evaluations are recorded as errored right away, without waiting for the PolicyServer. Zero disables the circuit breaker")
+	rootCmd.PersistentFlags().Duration("circuit-breaker-open-timeout", defaultCircuitBreakerOpenTimeout, "time after which an open circuit lets a single evaluation probe the PolicyServer, closing the circuit if it succeeds")
+	rootCmd.PersistentFlags().Float64("policy-server-qps", 0, "maximum number of evaluations per second sent to each PolicyServer. Zero means no limit")
+	rootCmd.PersistentFlags().Int("policy-server-burst", 1, "number of evaluations that can be sent at once to each PolicyServer, above --policy-server-qps")
+	rootCmd.PersistentFlags().Int("policy-server-max-in-flight", 0, "maximum number of concurrent evaluations sent to each PolicyServer. Zero means no limit")
+	rootCmd.PersistentFlags().StringSlice("policy-server-rate-limit", nil, "rate limit of a specific PolicyServer, overriding the global one, in the '<PolicyServer name>=<qps>:<burst>:<max in flight>' format. This flag can be repeated")
+	rootCmd.PersistentFlags().Duration("drain-timeout", defaultDrainTimeout, "time given to the evaluations in flight to complete, and to write their reports, when the scan is interrupted by SIGTERM or SIGINT")
+	rootCmd.PersistentFlags().String("fail-on", "", "gating mode: exit with code 3 if the scan finds failing results with at least the given severity, e.g. 'severity>=high'")
+	rootCmd.PersistentFlags().Int("max-failures", -1, "gating mode: exit with code 3 if the scan finds more than the given number of failing results. Negative values disable the check")
+	rootCmd.PersistentFlags().StringSlice("fail-on-policies", nil, "gating mode: only consider the results of the given policies, by unique name (e.g. 'clusterwide-my-policy'). This flag can be repeated")
+	rootCmd.PersistentFlags().String("user-info-mode", string(scanner.UserInfoModeFixed), fmt.Sprintf("user sending the simulated admission requests. Supported values are '%s', the audit identity, and '%s', the manager that last changed the resource according to its managedFields, sent without groups since they are not recorded", scanner.UserInfoModeFixed, scanner.UserInfoModeManager))
+	rootCmd.PersistentFlags().String("audit-user", "", "username of the audit identity. Defaults to the 'audit-scanner' ServiceAccount of the Kubewarden namespace")
+	rootCmd.PersistentFlags().StringSlice("audit-groups", nil, "groups of the audit identity. Defaults to the groups of the 'audit-scanner' ServiceAccount. They are not sent with --user-info-mode=manager, unless the resource has no manager. This flag can be repeated")
+	rootCmd.PersistentFlags().StringSlice("policy", nil, "policies to be evaluated: the name of a cluster-wide policy or namespace/name of a namespaced one. The results are merged into the existing reports, keeping the results of the other policies. This flag can be repeated")
+	rootCmd.PersistentFlags().String("policy-selector", "", "label selector of the policies to be evaluated, e.g. 'team=payments'. The results are merged into the existing reports like with --policy")
+	rootCmd.PersistentFlags().StringSlice("include-resources", nil, "resources to be evaluated, matched by resource name ('deployments', 'deployments.apps'), GVR ('apps/v1/deployments') or kind ('Deployment', 'Deployment.apps'). Shell patterns are supported. All the resources are evaluated when empty. This flag can be repeated")
+	rootCmd.PersistentFlags().StringSlice("exclude-resources", nil, "resources to be skipped from scan, matched by resource name, GVR or kind like --include-resources. This flag can be repeated")
+	rootCmd.PersistentFlags().StringSlice("wildcard-include-resources", nil, "resources the wildcard rules of the policies can be expanded to, qualified by their API group (e.g. 'deployments.apps', 'pods', '*.apps'). All the listable resources are included when empty. This flag can be repeated")
+	rootCmd.PersistentFlags().StringSlice("wildcard-exclude-resources", defaultWildcardExcludedResources(), "resources the wildcard rules of the policies are never expanded to, qualified by their API group (e.g. 'events', '*.example.com'). This flag can be repeated")
+	rootCmd.PersistentFlags().IntP("page-size", "", defaultPageSize, "number of resources to fetch from the Kubernetes API server when paginating")
+	rootCmd.PersistentFlags().String("report-aggregation", report.AggregationResource, "How the results are grouped into reports. Supported values are 'resource', one report per resource, and 'namespace', one report per namespace and one cluster report, split into shards when too large. 'namespace' requires --report-kind openreports")
+	rootCmd.PersistentFlags().StringP("report-kind", "", report.PolicyReportKind, "Report resouce kind to be used. Supported values are 'openreport' and 'policyreport'")
+	rootCmd.PersistentFlags().String("output-dir", "", "directory the reports are written to as files, laid out by namespace, kind and name of the audited resource, along with an index of all the reports. The reports are also stored in the cluster, unless --disable-store is given")
+	rootCmd.PersistentFlags().String("output-dir-format", report.FileFormatYAML, fmt.Sprintf("format of the files written to --output-dir. Supported values are '%s' and '%s'", report.FileFormatYAML, report.FileFormatJSON))
+	rootCmd.PersistentFlags().String("cloudevents-endpoint", "", "URL the reports are POSTed to as CloudEvents, in batches. The reports are also stored in the cluster, unless --disable-store is given")
+	rootCmd.PersistentFlags().String("cloudevents-source", cloudevents.DefaultSource, "source of the CloudEvents, identifying the audited cluster")
+	rootCmd.PersistentFlags().String("cloudevents-type-prefix", cloudevents.DefaultTypePrefix, "prefix of the type of the CloudEvents, followed by the kind of the report, e.g. '<prefix>.policyreport'")
+	rootCmd.PersistentFlags().Int("cloudevents-batch-size", cloudevents.DefaultBatchSize, "number of CloudEvents sent in a single request")
+	rootCmd.PersistentFlags().Int("cloudevents-max-retries", cloudevents.DefaultMaxRetries, "number of retries of the CloudEvents requests failing because of a transient error (network errors, 429 and 5xx status codes)")
+	rootCmd.PersistentFlags().String("cloudevents-ca", "", "File path to CA cert in PEM format of the CloudEvents endpoint")
+	rootCmd.PersistentFlags().String("cloudevents-client-cert", "", "File path to client cert in PEM format used for mTLS communication with the CloudEvents endpoint")
+	rootCmd.PersistentFlags().String("cloudevents-client-key", "", "File path to client key in PEM format used for mTLS communication with the CloudEvents endpoint")
+	rootCmd.MarkFlagsRequiredTogether("cloudevents-client-cert", "cloudevents-client-key")
+	rootCmd.PersistentFlags().String("history-db", "", "file of the local database the results of each scan run are recorded in, to follow their trends with the 'history' command. The reports are also stored in the cluster, unless --disable-store is given")
+	rootCmd.PersistentFlags().Int("history-runs", defaultHistoryRuns, "number of scan runs kept in --history-db")
+
+	rootCmd.AddCommand(newWatchCommand())
+	rootCmd.AddCommand(newManifestsCommand())
+	rootCmd.AddCommand(newHistoryCommand())
 
 	return rootCmd
 }
@@ -183,25 +167,159 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 func Execute(rootCmd *cobra.Command) {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error on cmd.Execute(): %s\n", err.Error())
-		os.Exit(1)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(exitCodeError)
 	}
 }
 
-//nolint:wrapcheck // this function calls internal package which already wrap the errors with context
-func startScanner(namespace string, clusterWide bool, scanner *scanner.Scanner) error {
-	if clusterWide && namespace != "" {
-		fmt.Fprintln(os.Stderr, "Cannot scan cluster wide and only a namespace at the same time")
+func startScanner(clusterWide bool, components *auditComponents) error {
+	if clusterWide && components.namespaceFilter.RestrictsScope() {
+		fmt.Fprintln(os.Stderr, "Cannot scan cluster wide and only some namespaces at the same time")
 	}
 
 	runUID := uuid.New().String()
-	ctx := context.Background()
-	if clusterWide {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// restore the default behavior once the first signal is received, so a
+	// second one kills the process without waiting for the scan to be drained
+	context.AfterFunc(ctx, stop)
+	components.serveMetrics(ctx)
+
+	data, err := components.runScan(ctx, clusterWide, runUID)
+	return components.checkRun(ctx, data, err)
+}
+
+// checkRun returns the error of a one-shot scan run, carrying the exit code
+// matching its outcome and, in gating mode, its results.
+func (c *auditComponents) checkRun(ctx context.Context, data summary.Data, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return &exitError{code: exitCodeInterrupted, err: fmt.Errorf("scan interrupted: %w", err)}
+	}
+	if c.gate != nil {
+		if gateErr := c.gate.Evaluate(data); gateErr != nil {
+			code := exitCodeScanErrors
+			if errors.Is(gateErr, gate.ErrViolations) {
+				code = exitCodeViolations
+			}
+			return &exitError{code: code, err: gateErr}
+		}
+	}
+	return err
+}
+
+// runScan performs a scan of the cluster and collects its summary.
+// The scan is restricted to the cluster-wide resources, or to the namespaces
+// selected by the namespace filter, if any.
+func (c *auditComponents) runScan(ctx context.Context, clusterWide bool, runUID string) (summary.Data, error) {
+	scope := summary.ScopeAll
+	namespace := ""
+	switch {
+	case clusterWide:
+		scope = summary.ScopeClusterWide
+	case c.namespaceFilter.SingleNamespace() != "":
+		scope = summary.ScopeNamespace
+		namespace = c.namespaceFilter.SingleNamespace()
+	case c.namespaceFilter.RestrictsScope():
+		scope = summary.ScopeNamespaces
+	}
+	return c.run(ctx, scope, namespace, runUID, func(ctx context.Context) error {
+		return scan(ctx, scope, namespace, c.scanner, runUID)
+	})
+}
+
+// run performs a scan and collects its summary. The summary is logged and,
+// if enabled, stored in the cluster.
+func (c *auditComponents) run(ctx context.Context, scope, namespace, runUID string, scan func(ctx context.Context) error) (summary.Data, error) {
+	runSummary := summary.NewRunSummary(runUID, scope, namespace)
+	c.saveRunSummary(ctx, runSummary)
+
+	scanCtx := summary.NewContext(ctx, runSummary)
+	var collector *output.Collector
+	if c.outputFormat != "" {
+		collector = output.NewCollector()
+		scanCtx = output.NewContext(scanCtx, collector)
+	}
+	err := c.scanner.Preflight(scanCtx)
+	if err == nil {
+		err = scan(scanCtx)
+	}
+	// the events are sent in background, they must be sent before exiting
+	c.events.Flush(context.WithoutCancel(ctx))
+
+	runSummary.Finish(err)
+	data := runSummary.Data()
+	c.metrics.RecordRun(scope, data.Outcome, data.EndTime.Sub(data.StartTime))
+	c.logger.InfoContext(ctx, "scan run summary", slog.Any("summary", data))
+	// the summary is saved even if the scan has been interrupted
+	c.saveRunSummary(context.WithoutCancel(ctx), runSummary)
+	if err := c.resultStream.WriteSummary(data); err != nil {
+		c.logger.ErrorContext(ctx, "error writing the run summary to the result stream", slog.String("error", err.Error()))
+	}
+	c.writeOutput(ctx, output.Document{Summary: data, Resources: collector.Resources()})
+
+	return data, err
+}
+
+// saveRunSummary stores the given summary in the cluster, if enabled.
+// Failures are only logged, since the summary is not needed to complete the scan.
+func (c *auditComponents) saveRunSummary(ctx context.Context, runSummary *summary.RunSummary) {
+	if c.summaryStore == nil {
+		return
+	}
+	if err := c.summaryStore.Save(ctx, runSummary); err != nil {
+		c.logger.ErrorContext(ctx, "error saving the run summary", slog.String("error", err.Error()))
+		return
+	}
+	if err := c.summaryStore.Prune(ctx); err != nil {
+		c.logger.ErrorContext(ctx, "error deleting the old run summaries", slog.String("error", err.Error()))
+	}
+}
+
+// writeOutput renders the results of a scan run in the output format, if any.
+// Failures are only logged, like for the run summary.
+func (c *auditComponents) writeOutput(ctx context.Context, document output.Document) {
+	if c.outputFormat == "" {
+		return
+	}
+	if isStdout(c.outputFile) {
+		c.writeOutputTo(ctx, os.Stdout, document)
+		return
+	}
+
+	file, err := os.Create(c.outputFile)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "error creating the output file", slog.String("error", err.Error()))
+		return
+	}
+	c.writeOutputTo(ctx, file, document)
+	if err := file.Close(); err != nil {
+		c.logger.ErrorContext(ctx, "error closing the output file", slog.String("error", err.Error()))
+	}
+}
+
+func (c *auditComponents) writeOutputTo(ctx context.Context, w io.Writer, document output.Document) {
+	if err := output.Write(w, c.outputFormat, document); err != nil {
+		c.logger.ErrorContext(ctx, "error writing the scan results",
+			slog.String("error", err.Error()),
+			slog.String("format", string(c.outputFormat)))
+	}
+}
+
+//nolint:wrapcheck // this function calls internal package which already wrap the errors with context
+func scan(ctx context.Context, scope, namespace string, scanner *scanner.Scanner, runUID string) error {
+	switch scope {
+	case summary.ScopeClusterWide:
 		// only scan clusterwide
 		return scanner.ScanClusterWideResources(ctx, runUID)
-	}
-	if namespace != "" {
+	case summary.ScopeNamespace:
 		// only scan namespace
 		return scanner.ScanNamespace(ctx, namespace, runUID)
+	case summary.ScopeNamespaces:
+		// only scan the selected namespaces
+		return scanner.ScanAllNamespaces(ctx, runUID)
 	}
 
 	// neither clusterWide flag nor namespace was provided, default
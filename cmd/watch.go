@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/watcher"
+	"github.com/spf13/cobra"
+)
+
+const defaultResyncPeriod = time.Hour
+
+func newWatchCommand() *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Continuously audits the resources of the cluster as they change",
+		Long: `Watches the resources targeted by the deployed Kubewarden policies and the policies themselves.
+A resource is audited again as soon as it changes, and all the resources targeted by a policy
+are audited again when the policy changes. A full scan is performed at startup and then
+periodically, to make sure the reports never drift from the state of the cluster.`,
+
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			clusterWide, err := cmd.Flags().GetBool("cluster")
+			if err != nil {
+				return fmt.Errorf("failed to get cluster flag: %w", err)
+			}
+			resyncPeriod, err := cmd.Flags().GetDuration("resync-period")
+			if err != nil {
+				return fmt.Errorf("failed to get resync-period flag: %w", err)
+			}
+			parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
+			if err != nil {
+				return fmt.Errorf("failed to get parallel-resources flag: %w", err)
+			}
+			components, err := newAuditComponents(cmd)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err = errors.Join(err, components.close())
+			}()
+			if clusterWide && components.namespaceFilter.RestrictsScope() {
+				return errors.New("cannot watch cluster wide and only some namespaces at the same time")
+			}
+			if components.gate != nil {
+				return errors.New("the gating mode cannot be used with the watch command")
+			}
+
+			watcher, err := watcher.NewWatcher(watcher.Config{
+				Scanner:        components.scanner,
+				PoliciesClient: components.policiesClient,
+				K8sClient:      components.k8sClient,
+				DynamicClient:  components.dynamicClient,
+				Namespace:      components.namespaceFilter.SingleNamespace(),
+				ClusterWide:    clusterWide,
+				NamespacedOnly: components.namespaceFilter.RestrictsScope(),
+				ResyncPeriod:   resyncPeriod,
+				Workers:        parallelResourcesAudits,
+				FullScan: func(ctx context.Context, runUID string) error {
+					_, err := components.runScan(ctx, clusterWide, runUID)
+					return err
+				},
+				Logger: components.logger,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create watcher: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			components.serveMetrics(ctx)
+
+			if err := watcher.Run(ctx); err != nil {
+				return fmt.Errorf("failed to watch the cluster: %w", err)
+			}
+			return nil
+		},
+	}
+
+	watchCmd.Flags().Duration("resync-period", defaultResyncPeriod, "interval between two full scans of the cluster")
+
+	return watchCmd
+}
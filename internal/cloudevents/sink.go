@@ -0,0 +1,449 @@
+// Package cloudevents streams the reports of the audited resources to an HTTP
+// endpoint as CloudEvents, so they can be consumed without polling the API
+// server.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/retry"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	specVersion = "1.0"
+	// batchContentType is the content type of the batched mode of the HTTP
+	// protocol binding: the body is a JSON array of events
+	batchContentType = "application/cloudevents-batch+json"
+	httpTimeout      = 30 * time.Second
+	// runUIDExtension is the extension attribute carrying the UID of the scan run
+	runUIDExtension = "runuid"
+)
+
+// Defaults of the Config.
+const (
+	DefaultSource         = "kubewarden.io/audit-scanner"
+	DefaultTypePrefix     = "io.kubewarden.auditscanner"
+	DefaultBatchSize      = 100
+	DefaultQueueSize      = 10
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// TLSConfig configures the TLS connection to the endpoint.
+type TLSConfig struct {
+	// CAFile is the CA certificate, in PEM format, of the endpoint. The
+	// system CAs are used when empty.
+	CAFile string
+	// ClientCertFile and ClientKeyFile are the client certificate and key, in
+	// PEM format, used for mTLS.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// Config configures the Sink.
+type Config struct {
+	// Endpoint is the URL the events are POSTed to.
+	Endpoint string
+	// Source identifies the producer of the events, like the cluster being audited.
+	Source string
+	// TypePrefix is the prefix of the type of the events, which is followed by
+	// the kind of the report, e.g. io.kubewarden.auditscanner.policyreport.
+	TypePrefix string
+	// BatchSize is the number of events sent in a single request.
+	BatchSize int
+	// QueueSize is the number of batches waiting to be sent. The batches
+	// exceeding it are dropped, so a slow endpoint doesn't stall the scan.
+	QueueSize int
+	// MaxRetries is the number of retries of a request failing because of a
+	// transient error. Zero disables the retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. The delay doubles
+	// at each retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	TLS            TLSConfig
+	Logger         *slog.Logger
+}
+
+// Event is a CloudEvent in the structured JSON format.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	RunUID          string          `json:"runuid,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Sink is a report.Store sending each report as a CloudEvent to an HTTP
+// endpoint, instead of storing it. The events are sent in batches, using the
+// batched content mode of the HTTP protocol binding. The pending events are
+// also sent when the scan of a namespace, or of the cluster-wide resources, is
+// completed.
+//
+// The batches are sent in background by a single worker, in order, so the scan
+// doesn't wait for the endpoint. The requests failing because of a transient
+// error are retried, the events of a batch that cannot be sent, or that
+// doesn't fit in the queue, are dropped. Close sends the remaining events.
+//
+// The sink doesn't keep the reports, so it's meant to be combined with
+// another store by a report.MultiStore.
+type Sink struct {
+	endpoint       string
+	source         string
+	typePrefix     string
+	batchSize      int
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	httpClient     *http.Client
+	logger         *slog.Logger
+	// mutex protects pending, closed, dropped and lastErr
+	mutex sync.Mutex
+	// pending are the events not queued yet
+	pending []Event
+	closed  bool
+	// dropped is the number of events that could not be sent, lastErr is the
+	// reason of the last failure
+	dropped int
+	lastErr error
+	// queue holds the batches to be sent by the worker
+	queue chan []Event
+	// done is closed when the worker has sent all the queued batches
+	done chan struct{}
+	// ctx is the context of the requests of the worker, canceled by cancel
+	// when Close gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewSink creates a new Sink.
+func NewSink(config Config) (*Sink, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("the CloudEvents endpoint cannot be empty")
+	}
+	if config.BatchSize < 1 {
+		return nil, errors.New("the CloudEvents batch size must be greater than zero")
+	}
+	if config.QueueSize < 1 {
+		return nil, errors.New("the CloudEvents queue size must be greater than zero")
+	}
+	if config.MaxRetries < 0 {
+		return nil, errors.New("the number of CloudEvents retries cannot be negative")
+	}
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("failed to build the CloudEvents HTTP client: failed http.Transport type assertion")
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := &Sink{
+		endpoint:       config.Endpoint,
+		source:         config.Source,
+		typePrefix:     config.TypePrefix,
+		batchSize:      config.BatchSize,
+		maxRetries:     config.MaxRetries,
+		initialBackoff: config.InitialBackoff,
+		maxBackoff:     config.MaxBackoff,
+		httpClient:     &http.Client{Transport: transport, Timeout: httpTimeout},
+		logger:         config.Logger.With("component", "cloudeventssink"),
+		queue:          make(chan []Event, config.QueueSize),
+		done:           make(chan struct{}),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	go sink.work()
+
+	return sink, nil
+}
+
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if config.CAFile != "" {
+		caCert, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %q with CA cert: %w", config.CAFile, err)
+		}
+		rootCAs := x509.NewCertPool()
+		if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("failed to parse the CA cert of file %q", config.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if config.ClientCertFile != "" && config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// GetReport always returns constants.ErrResourceNotFound, the sink doesn't keep the reports.
+func (s *Sink) GetReport(_ context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	return nil, fmt.Errorf("%w: the CloudEvents sink doesn't keep report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+}
+
+// CreateOrPatchReport queues an event with the given report, and hands the
+// queued events to the worker once they fill a batch.
+func (s *Sink) CreateOrPatchReport(_ context.Context, obj any) error {
+	return s.enqueue(obj)
+}
+
+// FlushReports hands the queued events to the worker.
+func (s *Sink) FlushReports(_ context.Context, _, _ string) error {
+	return s.flush()
+}
+
+// DeleteOldReports hands the queued events to the worker, the scan of the namespace is completed.
+func (s *Sink) DeleteOldReports(_ context.Context, _, _ string) error {
+	return s.flush()
+}
+
+// GetClusterReport always returns constants.ErrResourceNotFound, like GetReport.
+func (s *Sink) GetClusterReport(_ context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	return nil, fmt.Errorf("%w: the CloudEvents sink doesn't keep cluster report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+}
+
+// CreateOrPatchClusterReport queues an event with the given cluster report, like CreateOrPatchReport.
+func (s *Sink) CreateOrPatchClusterReport(_ context.Context, obj any) error {
+	return s.enqueue(obj)
+}
+
+// FlushClusterReports hands the queued events to the worker.
+func (s *Sink) FlushClusterReports(_ context.Context, _ string) error {
+	return s.flush()
+}
+
+// DeleteOldClusterReports hands the queued events to the worker, the scan of the cluster-wide resources is completed.
+func (s *Sink) DeleteOldClusterReports(_ context.Context, _ string) error {
+	return s.flush()
+}
+
+// Close sends the pending events and waits for the worker to send the queued
+// batches, until the context is done. It returns an error if some events could
+// not be sent since the sink was created.
+func (s *Sink) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	if len(s.pending) > 0 {
+		_ = s.queueBatch(s.pending)
+		s.pending = nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mutex.Unlock()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		// abort the request in flight, the worker drops the remaining batches
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.dropped > 0 {
+		return fmt.Errorf("failed to send %d CloudEvents: %w", s.dropped, s.lastErr)
+	}
+	return nil
+}
+
+func (s *Sink) enqueue(obj any) error {
+	event, err := s.newEvent(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.New("the CloudEvents sink is closed")
+	}
+	s.pending = append(s.pending, event)
+	if len(s.pending) < s.batchSize {
+		return nil
+	}
+	batch := s.pending
+	s.pending = nil
+	return s.queueBatch(batch)
+}
+
+func (s *Sink) flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed || len(s.pending) == 0 {
+		return nil
+	}
+	batch := s.pending
+	s.pending = nil
+	return s.queueBatch(batch)
+}
+
+// queueBatch hands a batch to the worker, without waiting for it to be sent.
+// The batch is dropped when the queue is full. The mutex must be held.
+func (s *Sink) queueBatch(batch []Event) error {
+	select {
+	case s.queue <- batch:
+		return nil
+	default:
+		err := fmt.Errorf("failed to queue %d CloudEvents: the queue is full", len(batch))
+		s.dropped += len(batch)
+		s.lastErr = err
+		return err
+	}
+}
+
+// work sends the queued batches, until the queue is closed.
+func (s *Sink) work() {
+	defer close(s.done)
+
+	for batch := range s.queue {
+		if s.ctx.Err() != nil {
+			s.recordDropped(len(batch), s.ctx.Err())
+			continue
+		}
+		if err := s.send(s.ctx, batch); err != nil {
+			s.logger.ErrorContext(s.ctx, "failed to send CloudEvents batch, dropping it", slog.String("error", err.Error()))
+			s.recordDropped(len(batch), err)
+		}
+	}
+}
+
+func (s *Sink) recordDropped(events int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dropped += events
+	s.lastErr = err
+}
+
+// newEvent wraps the given report into an event. The type of the event is
+// the type prefix followed by the kind of the report, its subject identifies
+// the audited resource.
+func (s *Sink) newEvent(obj any) (Event, error) {
+	auditReport, ok := obj.(report.Report)
+	if !ok {
+		return Event{}, fmt.Errorf("expected Report, got %T", obj)
+	}
+	data, err := json.Marshal(auditReport)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal the report: %w", err)
+	}
+	metadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return Event{}, fmt.Errorf("failed to read the metadata of the report: %w", err)
+	}
+
+	event := Event{
+		SpecVersion:     specVersion,
+		ID:              uuid.New().String(),
+		Source:          s.source,
+		Type:            s.typePrefix + "." + strings.ToLower(metadata.Kind),
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		RunUID:          metadata.Labels[auditConstants.AuditScannerRunUIDLabel],
+		Data:            data,
+	}
+	if scope := auditReport.GetScope(); scope != nil {
+		event.Subject = scope.Kind + "/" + scope.Name
+		if scope.Namespace != "" {
+			event.Subject = scope.Namespace + "/" + event.Subject
+		}
+	}
+	return event, nil
+}
+
+// send POSTs a batch of events, retrying the transient failures.
+func (s *Sink) send(ctx context.Context, batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the CloudEvents batch: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, body)
+		if err == nil {
+			s.logger.DebugContext(ctx, "CloudEvents batch sent", slog.Int("events", len(batch)))
+			return nil
+		}
+		if !retry.IsTransient(err) || attempt >= s.maxRetries || ctx.Err() != nil {
+			return fmt.Errorf("failed to send %d CloudEvents: %w", len(batch), err)
+		}
+
+		delay := retry.Backoff(s.initialBackoff, s.maxBackoff, attempt+1)
+		s.logger.WarnContext(ctx, "failed to send CloudEvents batch, retrying...",
+			slog.String("error", err.Error()),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to send %d CloudEvents: %w", len(batch), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *Sink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build the request: %w", err)
+	}
+	req.Header.Set("Content-Type", batchContentType)
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return &retry.TransientError{Err: err}
+	}
+	defer res.Body.Close()
+	// drain the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	err = fmt.Errorf("unexpected status code %d", res.StatusCode)
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError {
+		return &retry.TransientError{Err: err, StatusCode: res.StatusCode}
+	}
+	return err
+}
@@ -0,0 +1,284 @@
+package cloudevents
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// eventsServer records the batches of events it receives.
+type eventsServer struct {
+	mutex   sync.Mutex
+	batches [][]Event
+}
+
+func (s *eventsServer) handle(writer http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Content-Type") != batchContentType {
+		writer.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var batch []Event
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	s.batches = append(s.batches, batch)
+	s.mutex.Unlock()
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (s *eventsServer) received() [][]Event {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.batches
+}
+
+func newTestConfig(endpoint string) Config {
+	return Config{
+		Endpoint:   endpoint,
+		Source:     "test-cluster",
+		TypePrefix: DefaultTypePrefix,
+		BatchSize:  2,
+		QueueSize:  DefaultQueueSize,
+		MaxRetries: 2,
+		Logger:     slog.Default(),
+	}
+}
+
+func newTestResource(name, namespace string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetUID(types.UID(name + "-uid"))
+	return resource
+}
+
+func TestSinkBatches(t *testing.T) {
+	server := &eventsServer{}
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handle))
+	defer httpServer.Close()
+
+	sink, err := NewSink(newTestConfig(httpServer.URL))
+	require.NoError(t, err)
+
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod1", "default"))))
+	assert.Empty(t, server.received())
+	// the batch is sent once full
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod2", "default"))))
+	require.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+	// the pending events are sent when the scan of the namespace is completed
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod3", "default"))))
+	require.NoError(t, sink.DeleteOldReports(t.Context(), "run-uid", "default"))
+	require.NoError(t, sink.CreateOrPatchClusterReport(t.Context(), report.NewClusterOpenReport("run-uid", newTestResource("namespace", ""))))
+	require.NoError(t, sink.FlushClusterReports(t.Context(), "run-uid"))
+	// nothing is sent when there are no pending events
+	require.NoError(t, sink.FlushReports(t.Context(), "run-uid", "default"))
+	require.NoError(t, sink.Close(t.Context()))
+
+	batches := server.received()
+	require.Len(t, batches, 3)
+	require.Len(t, batches[0], 2)
+	require.Len(t, batches[1], 1)
+	require.Len(t, batches[2], 1)
+
+	event := batches[0][0]
+	assert.Equal(t, specVersion, event.SpecVersion)
+	assert.NotEmpty(t, event.ID)
+	assert.NotEqual(t, event.ID, batches[0][1].ID)
+	assert.Equal(t, "test-cluster", event.Source)
+	assert.Equal(t, "io.kubewarden.auditscanner.policyreport", event.Type)
+	assert.Equal(t, "default/Pod/pod1", event.Subject)
+	assert.Equal(t, "run-uid", event.RunUID)
+	assert.Equal(t, "application/json", event.DataContentType)
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(event.Data, &data))
+	assert.Equal(t, "PolicyReport", data["kind"])
+
+	event = batches[2][0]
+	assert.Equal(t, "io.kubewarden.auditscanner.clusterreport", event.Type)
+	assert.Equal(t, "Pod/namespace", event.Subject)
+}
+
+func TestSinkRetries(t *testing.T) {
+	server := &eventsServer{}
+	var requests atomic.Int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if requests.Add(1) == 1 {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.handle(writer, req)
+	}))
+	defer httpServer.Close()
+
+	sink, err := NewSink(newTestConfig(httpServer.URL))
+	require.NoError(t, err)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod", "default"))))
+	require.NoError(t, sink.Close(t.Context()))
+
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Len(t, server.received(), 1)
+}
+
+func TestSinkPermanentFailure(t *testing.T) {
+	var requests atomic.Int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writer.WriteHeader(http.StatusBadRequest)
+	}))
+	defer httpServer.Close()
+
+	sink, err := NewSink(newTestConfig(httpServer.URL))
+	require.NoError(t, err)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod", "default"))))
+	require.NoError(t, sink.FlushReports(t.Context(), "run-uid", "default"))
+	err = sink.Close(t.Context())
+	require.ErrorContains(t, err, "failed to send 1 CloudEvents: unexpected status code 400")
+
+	// the client errors are not retried, and the events are dropped
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestSinkDoesNotWaitForTheEndpoint(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+	defer httpServer.Close()
+
+	config := newTestConfig(httpServer.URL)
+	config.BatchSize = 1
+	config.QueueSize = 1
+	sink, err := NewSink(config)
+	require.NoError(t, err)
+
+	// the first batch is being sent, the second one is queued
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod1", "default"))))
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod2", "default"))))
+	// the batches exceeding the queue are dropped instead of stalling the scan
+	err = sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod3", "default")))
+	require.ErrorContains(t, err, "the queue is full")
+
+	close(release)
+	err = sink.Close(t.Context())
+	require.ErrorContains(t, err, "failed to send 1 CloudEvents")
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestSinkCloseTimeout(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		// the server notices the client going away once the body is read
+		_, _ = io.Copy(io.Discard, req.Body)
+		<-req.Context().Done()
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer httpServer.Close()
+
+	sink, err := NewSink(newTestConfig(httpServer.URL))
+	require.NoError(t, err)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod", "default"))))
+
+	// the request in flight is aborted once the context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorContains(t, sink.Close(ctx), "failed to send 1 CloudEvents")
+
+	// the sink is optional
+	var disabled *Sink
+	require.NoError(t, disabled.Close(t.Context()))
+}
+
+func TestSinkWithMTLS(t *testing.T) {
+	caCertPEM, caKeyPEM, err := testutils.GenerateTestCA()
+	require.NoError(t, err)
+	serverCertPEM, serverKeyPEM, err := testutils.GenerateTestCert(caCertPEM, caKeyPEM, "server")
+	require.NoError(t, err)
+	clientCertPEM, clientKeyPEM, err := testutils.GenerateTestCert(caCertPEM, caKeyPEM, "client")
+	require.NoError(t, err)
+	caCertFile, err := testutils.WriteTempFile(caCertPEM)
+	require.NoError(t, err)
+	clientCertFile, err := testutils.WriteTempFile(clientCertPEM)
+	require.NoError(t, err)
+	clientKeyFile, err := testutils.WriteTempFile(clientKeyPEM)
+	require.NoError(t, err)
+
+	serverCert, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	require.NoError(t, err)
+	caCertPool := x509.NewCertPool()
+	require.True(t, caCertPool.AppendCertsFromPEM(caCertPEM))
+
+	server := &eventsServer{}
+	httpServer := httptest.NewUnstartedServer(http.HandlerFunc(server.handle))
+	httpServer.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    caCertPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	httpServer.StartTLS()
+	defer httpServer.Close()
+
+	config := newTestConfig(httpServer.URL)
+	config.MaxRetries = 0
+	config.TLS = TLSConfig{
+		CAFile:         caCertFile,
+		ClientCertFile: clientCertFile,
+		ClientKeyFile:  clientKeyFile,
+	}
+	sink, err := NewSink(config)
+	require.NoError(t, err)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod", "default"))))
+	require.NoError(t, sink.Close(t.Context()))
+	assert.Len(t, server.received(), 1)
+
+	// the endpoint rejects the clients without a certificate
+	config.TLS.ClientCertFile = ""
+	config.TLS.ClientKeyFile = ""
+	sink, err = NewSink(config)
+	require.NoError(t, err)
+	require.NoError(t, sink.CreateOrPatchReport(t.Context(), report.NewPolicyReport("run-uid", newTestResource("pod", "default"))))
+	require.Error(t, sink.Close(t.Context()))
+}
+
+func TestNewSinkWithInvalidConfig(t *testing.T) {
+	_, err := NewSink(newTestConfig(""))
+	require.Error(t, err)
+
+	config := newTestConfig("http://localhost")
+	config.BatchSize = 0
+	_, err = NewSink(config)
+	require.Error(t, err)
+
+	config = newTestConfig("http://localhost")
+	config.QueueSize = 0
+	_, err = NewSink(config)
+	require.Error(t, err)
+}
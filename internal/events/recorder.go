@@ -0,0 +1,164 @@
+// Package events emits Kubernetes Events on the audited resources that fail
+// the policies, so the violations are shown by `kubectl describe` next to the
+// other events of the resources.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the events.
+const (
+	// ReasonPolicyViolation is the reason of the events of the failing results.
+	ReasonPolicyViolation = "PolicyViolation"
+	// ReasonNewPolicyViolation is the reason of the events of the results
+	// that were not failing in the previous scan.
+	ReasonNewPolicyViolation = "NewPolicyViolation"
+)
+
+const (
+	// component is the source of the events
+	component = "kubewarden-audit-scanner"
+	// flushTimeout bounds the time spent waiting for the events to be sent
+	flushTimeout  = 10 * time.Second
+	flushInterval = 100 * time.Millisecond
+)
+
+// Recorder emits a Warning Event on the audited resources for each policy they
+// fail. The events are sent in background by the client-go EventRecorder,
+// which deduplicates and rate-limits them: the events repeated by the
+// following scans are aggregated into the existing Event, incrementing its
+// count, and the events of each resource are limited by a token bucket, so
+// the scans don't flood the API server.
+// It's safe for concurrent use. All the methods are no-op on a nil Recorder, so
+// the callers don't need to check if the events are enabled.
+type Recorder struct {
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	// watcher delivers the recorded events to the sink
+	watcher watch.Interface
+	sink    *countingSink
+	logger  *slog.Logger
+}
+
+// NewRecorder creates a new Recorder sending the events with the given client.
+func NewRecorder(clientset kubernetes.Interface, logger *slog.Logger) *Recorder {
+	sink := &countingSink{EventSink: &typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")}}
+	broadcaster := record.NewBroadcaster()
+	return &Recorder{
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(clientgoscheme.Scheme, corev1.EventSource{Component: component}),
+		watcher:     broadcaster.StartRecordingToSink(sink),
+		sink:        sink,
+		logger:      logger.With("component", "events"),
+	}
+}
+
+// Record emits an event for each failing result of the given report, on the
+// audited resource. The results that were not failing in the previous report
+// of the resource, when given, are reported with the NewPolicyViolation reason.
+func (r *Recorder) Record(auditReport, previousReport report.Report) {
+	if r == nil || auditReport.GetScope() == nil {
+		return
+	}
+	previousStatuses := make(map[string]string)
+	if previousReport != nil {
+		for _, result := range previousReport.GetResults() {
+			previousStatuses[result.Policy] = result.Status
+		}
+	}
+
+	for _, result := range auditReport.GetResults() {
+		if result.Status != report.StatusFail {
+			continue
+		}
+		reason := ReasonPolicyViolation
+		if previousStatus, found := previousStatuses[result.Policy]; found && previousStatus != report.StatusFail {
+			reason = ReasonNewPolicyViolation
+		}
+		message := fmt.Sprintf("policy %s failed", result.Policy)
+		if result.Message != "" {
+			message = fmt.Sprintf("policy %s failed: %s", result.Policy, result.Message)
+		}
+		r.recorder.Event(auditReport.GetScope(), corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// Flush waits until the recorded events are sent, so they are not lost when
+// the scanner exits after a scan run. It gives up after a timeout.
+func (r *Recorder) Flush(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	// the events are idle when none is queued nor being sent. They must be
+	// idle twice in a row, since an event is neither queued nor being sent
+	// while the EventRecorder deduplicates it
+	idle := false
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.WarnContext(ctx, "timeout waiting for the events to be sent")
+			return
+		case <-ticker.C:
+			if len(r.watcher.ResultChan()) > 0 || r.sink.inFlight.Load() > 0 {
+				idle = false
+				continue
+			}
+			if idle {
+				return
+			}
+			idle = true
+		}
+	}
+}
+
+// Shutdown stops the goroutines sending the events. The events not sent yet
+// are dropped, so Flush must be called before. The Recorder must not be used
+// afterwards.
+func (r *Recorder) Shutdown() {
+	if r == nil {
+		return
+	}
+	r.broadcaster.Shutdown()
+}
+
+// countingSink counts the events being sent by the EventSink it wraps.
+type countingSink struct {
+	record.EventSink
+	inFlight atomic.Int64
+}
+
+func (s *countingSink) Create(event *corev1.Event) (*corev1.Event, error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	return s.EventSink.Create(event) //nolint:wrapcheck // the errors are handled by the EventRecorder
+}
+
+func (s *countingSink) Update(event *corev1.Event) (*corev1.Event, error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	return s.EventSink.Update(event) //nolint:wrapcheck // the errors are handled by the EventRecorder
+}
+
+func (s *countingSink) Patch(oldEvent *corev1.Event, data []byte) (*corev1.Event, error) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	return s.EventSink.Patch(oldEvent, data) //nolint:wrapcheck // the errors are handled by the EventRecorder
+}
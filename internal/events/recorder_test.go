@@ -0,0 +1,91 @@
+package events
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestReport returns a report of a Pod, with a result for each policy,
+// allowed or not.
+func newTestReport(results map[string]bool) report.Report {
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("pod")
+	resource.SetNamespace("default")
+	resource.SetUID(types.UID("pod-uid"))
+
+	auditReport := report.NewPolicyReport("uid", resource)
+	for policyName, allowed := range results {
+		policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: policyName}}
+		auditReport.AddResult(policy, &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: allowed,
+				Result:  &metav1.Status{Message: "not allowed"},
+			},
+		}, false, nil)
+	}
+	return auditReport
+}
+
+func listEvents(t *testing.T, clientset *fake.Clientset) map[string]corev1.Event {
+	t.Helper()
+
+	eventList, err := clientset.CoreV1().Events("default").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	events := make(map[string]corev1.Event)
+	for _, event := range eventList.Items {
+		events[event.Reason] = event
+	}
+	return events
+}
+
+func TestRecorder(t *testing.T) {
+	clientset := fake.NewClientset()
+	recorder := NewRecorder(clientset, slog.Default())
+
+	recorder.Record(newTestReport(map[string]bool{"failing": false, "passing": true}), nil)
+	recorder.Flush(t.Context())
+	events := listEvents(t, clientset)
+	require.Len(t, events, 1)
+	event := events[ReasonPolicyViolation]
+	assert.Equal(t, corev1.EventTypeWarning, event.Type)
+	assert.Equal(t, "policy clusterwide-failing failed: not allowed", event.Message)
+	assert.Equal(t, "pod", event.InvolvedObject.Name)
+	assert.Equal(t, "Pod", event.InvolvedObject.Kind)
+	assert.Equal(t, types.UID("pod-uid"), event.InvolvedObject.UID)
+	assert.Equal(t, component, event.Source.Component)
+
+	// the repeated events are aggregated, the results failing since the
+	// previous report get their own reason
+	previousReport := newTestReport(map[string]bool{"failing": false, "passing": true})
+	recorder.Record(newTestReport(map[string]bool{"failing": false, "passing": false}), previousReport)
+	recorder.Flush(t.Context())
+	events = listEvents(t, clientset)
+	require.Len(t, events, 2)
+	assert.Equal(t, int32(2), events[ReasonPolicyViolation].Count)
+	assert.Equal(t, "policy clusterwide-passing failed: not allowed", events[ReasonNewPolicyViolation].Message)
+
+	// the events recorded after the shutdown are dropped
+	recorder.Shutdown()
+	recorder.Record(newTestReport(map[string]bool{"failing": false}), nil)
+	recorder.Flush(t.Context())
+	assert.Equal(t, int32(2), listEvents(t, clientset)[ReasonPolicyViolation].Count)
+
+	// the events are optional
+	var disabled *Recorder
+	disabled.Record(newTestReport(map[string]bool{"failing": false}), nil)
+	disabled.Flush(t.Context())
+	disabled.Shutdown()
+}
@@ -0,0 +1,101 @@
+package gate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+)
+
+const severityPrefix = "severity>="
+
+// ErrViolations is returned when a scan found more policy violations than
+// tolerated.
+var ErrViolations = errors.New("policy violations found")
+
+// ErrScanErrors is returned when a scan could not audit all the resources,
+// so its results cannot be trusted to gate anything.
+var ErrScanErrors = errors.New("scan errors")
+
+// Config configures the conditions making a scan fail, so the audit scanner
+// can be used to block a pipeline.
+type Config struct {
+	// MinSeverity is the minimum severity of the failing results counted as
+	// violations. All the failing results are counted when empty.
+	MinSeverity string
+	// MaxFailures is the number of violations tolerated.
+	MaxFailures int
+	// Policies restricts the gate to the given policies, by unique name.
+	// All the policies are considered when empty.
+	Policies []string
+}
+
+// ParseFailOn parses a fail-on condition in the `severity>=<severity>` format
+// and returns the minimum severity.
+func ParseFailOn(failOn string) (string, error) {
+	severity, found := strings.CutPrefix(strings.ReplaceAll(failOn, " ", ""), severityPrefix)
+	if !found || severityRank(severity) == 0 {
+		return "", fmt.Errorf("invalid fail-on condition %q, expected %s<%s>", failOn, severityPrefix, strings.Join(severities(), "|"))
+	}
+	return severity, nil
+}
+
+// Evaluate checks the summary of a scan run against the gate. It returns an
+// error wrapping ErrViolations when the violations exceed the tolerated ones,
+// otherwise an error wrapping ErrScanErrors when the scan did not complete or
+// some policies could not be evaluated.
+func (c Config) Evaluate(data summary.Data) error {
+	violations := 0
+	errored := 0
+	for _, result := range data.FailedResults {
+		if !c.inScope(result.Policy) {
+			continue
+		}
+		switch result.Status {
+		case report.StatusFail:
+			if severityRank(result.Severity) >= severityRank(c.MinSeverity) {
+				violations += result.Count
+			}
+		case report.StatusError:
+			errored += result.Count
+		}
+	}
+	if violations > c.MaxFailures {
+		return fmt.Errorf("%w: %d violations, at most %d tolerated", ErrViolations, violations, c.MaxFailures)
+	}
+
+	if data.Outcome != summary.OutcomeCompleted {
+		return fmt.Errorf("%w: scan %s: %s", ErrScanErrors, data.Outcome, data.Error)
+	}
+	if listFailures := len(data.ListFailures) + data.ListFailuresDropped; listFailures > 0 {
+		return fmt.Errorf("%w: %d failures listing the resources to audit", ErrScanErrors, listFailures)
+	}
+	for _, policy := range data.ErroredPolicies {
+		if c.inScope(policy) {
+			errored++
+		}
+	}
+	if errored > 0 {
+		return fmt.Errorf("%w: %d policy evaluations errored", ErrScanErrors, errored)
+	}
+
+	return nil
+}
+
+func (c Config) inScope(policy string) bool {
+	return len(c.Policies) == 0 || slices.Contains(c.Policies, policy)
+}
+
+// severityRank returns the rank of the given severity, from 1 for info to 5 for
+// critical. Unknown and empty severities have rank 0.
+func severityRank(severity string) int {
+	return slices.Index(severities(), severity) + 1
+}
+
+// severities returns the severities sorted from the lowest to the highest.
+func severities() []string {
+	return []string{report.SeverityInfo, report.SeverityLow, report.SeverityMedium, report.SeverityHigh, report.SeverityCritical}
+}
@@ -0,0 +1,75 @@
+package gate
+
+import (
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFailOn(t *testing.T) {
+	severity, err := ParseFailOn("severity>=high")
+	require.NoError(t, err)
+	assert.Equal(t, report.SeverityHigh, severity)
+
+	severity, err = ParseFailOn("severity >= low")
+	require.NoError(t, err)
+	assert.Equal(t, report.SeverityLow, severity)
+
+	_, err = ParseFailOn("severity>=urgent")
+	require.Error(t, err)
+	_, err = ParseFailOn("high")
+	require.Error(t, err)
+}
+
+func TestEvaluate(t *testing.T) {
+	completed := summary.Data{
+		Outcome: summary.OutcomeCompleted,
+		FailedResults: []summary.PolicyResultCount{
+			{Policy: "clusterwide-critical", Status: report.StatusFail, Severity: report.SeverityCritical, Count: 2},
+			{Policy: "clusterwide-low", Status: report.StatusFail, Severity: report.SeverityLow, Count: 3},
+			{Policy: "clusterwide-none", Status: report.StatusFail, Count: 1},
+		},
+	}
+	withErrors := summary.Data{
+		Outcome: summary.OutcomeCompleted,
+		FailedResults: []summary.PolicyResultCount{
+			{Policy: "clusterwide-critical", Status: report.StatusError, Count: 1},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		config        Config
+		data          summary.Data
+		expectedError error
+	}{
+		{"any failure", Config{}, completed, ErrViolations},
+		{"failures tolerated", Config{MaxFailures: 6}, completed, nil},
+		{"failures above the tolerated ones", Config{MaxFailures: 5}, completed, ErrViolations},
+		{"severity threshold", Config{MinSeverity: report.SeverityHigh, MaxFailures: 2}, completed, nil},
+		{"severity threshold exceeded", Config{MinSeverity: report.SeverityLow, MaxFailures: 2}, completed, ErrViolations},
+		{"policies out of scope", Config{Policies: []string{"clusterwide-other"}}, completed, nil},
+		{"policies in scope", Config{Policies: []string{"clusterwide-low"}, MaxFailures: 2}, completed, ErrViolations},
+		{"errored results", Config{}, withErrors, ErrScanErrors},
+		{"errored results out of scope", Config{Policies: []string{"clusterwide-other"}}, withErrors, nil},
+		{"errored policies", Config{}, summary.Data{Outcome: summary.OutcomeCompleted, ErroredPolicies: []string{"clusterwide-critical"}}, ErrScanErrors},
+		{"list failures", Config{}, summary.Data{Outcome: summary.OutcomeCompleted, ListFailures: []summary.ListFailure{{Resource: "pods"}}}, ErrScanErrors},
+		{"dropped list failures", Config{}, summary.Data{Outcome: summary.OutcomeCompleted, ListFailuresDropped: 1}, ErrScanErrors},
+		{"failed run", Config{}, summary.Data{Outcome: summary.OutcomeFailed, Error: "cannot list policies"}, ErrScanErrors},
+		{"clean run", Config{}, summary.Data{Outcome: summary.OutcomeCompleted}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Evaluate(test.data)
+			if test.expectedError == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, test.expectedError)
+		})
+	}
+}
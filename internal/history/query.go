@@ -0,0 +1,228 @@
+package history
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	bolt "go.etcd.io/bbolt"
+)
+
+// GroupBy is the dimension the trends are computed on.
+type GroupBy string
+
+const (
+	GroupByNamespace GroupBy = "namespace"
+	GroupByPolicy    GroupBy = "policy"
+	GroupByResource  GroupBy = "resource"
+)
+
+// ClusterKey is the namespace key of the cluster-wide resources.
+const ClusterKey = "(cluster)"
+
+// SupportedGroupBy returns the dimensions the trends can be computed on.
+func SupportedGroupBy() []GroupBy {
+	return []GroupBy{GroupByNamespace, GroupByPolicy, GroupByResource}
+}
+
+// ParseGroupBy parses the name of a dimension.
+func ParseGroupBy(name string) (GroupBy, error) {
+	switch groupBy := GroupBy(name); groupBy {
+	case GroupByNamespace, GroupByPolicy, GroupByResource:
+		return groupBy, nil
+	default:
+		return "", fmt.Errorf("invalid group %q: supported values are %q", name, SupportedGroupBy())
+	}
+}
+
+// Query selects the results the trends are computed on.
+type Query struct {
+	// GroupBy is the dimension the trends are computed on
+	GroupBy GroupBy
+	// Namespace filters the results by namespace. ClusterKey selects the
+	// cluster-wide resources.
+	Namespace string
+	// Policy filters the results by the unique name of the policy
+	Policy string
+	// Resource filters the results by resource, identified as
+	// namespace/Kind/name, or Kind/name for the cluster-wide resources
+	Resource string
+	// Runs is the number of latest runs considered. Zero considers all the runs.
+	Runs int
+}
+
+// Point is the summary of the results of a trend in a scan run.
+type Point struct {
+	RunUID  string         `json:"runUID"`
+	Time    time.Time      `json:"time"`
+	Summary report.Summary `json:"summary"`
+}
+
+// Trend is the evolution of the results of a namespace, policy or resource
+// across the scan runs, oldest first.
+type Trend struct {
+	Key    string  `json:"key"`
+	Points []Point `json:"points"`
+}
+
+// OpenReadOnly opens the history database at the given path to query it. It
+// waits for the scanner writing to it to release the database.
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, fileMode, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the history database %s: %w", path, err)
+	}
+	return &Store{
+		path:   path,
+		db:     db,
+		logger: slog.New(slog.DiscardHandler),
+	}, nil
+}
+
+// Runs returns the recorded runs, oldest first.
+func (s *Store) Runs() ([]Run, error) {
+	var runs []Run
+	err := s.view(func(tx *bolt.Tx) error {
+		if tx.Bucket(runsBucket) == nil {
+			return nil
+		}
+		var err error
+		runs, err = readRuns(tx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the history: %w", err)
+	}
+	return runs, nil
+}
+
+// Trends returns the trends of the results selected by the query, sorted by key.
+// A trend has a point for each run it has results in.
+func (s *Store) Trends(query Query) ([]Trend, error) {
+	if _, err := ParseGroupBy(string(query.GroupBy)); err != nil {
+		return nil, err
+	}
+
+	trends := map[string]*Trend{}
+	err := s.view(func(tx *bolt.Tx) error {
+		if tx.Bucket(runsBucket) == nil || tx.Bucket(resultsBucket) == nil {
+			return nil
+		}
+		runs, err := readRuns(tx)
+		if err != nil {
+			return err
+		}
+		if query.Runs > 0 && len(runs) > query.Runs {
+			runs = runs[len(runs)-query.Runs:]
+		}
+		for _, run := range runs {
+			runResults := tx.Bucket(resultsBucket).Bucket([]byte(run.RunUID))
+			if runResults == nil {
+				continue
+			}
+			points := map[string]*Point{}
+			err := runResults.ForEach(func(_, value []byte) error {
+				var record Record
+				if err := json.Unmarshal(value, &record); err != nil {
+					return fmt.Errorf("failed to read the result of run %s: %w", run.RunUID, err)
+				}
+				if !query.matches(record) {
+					return nil
+				}
+				key := query.key(record)
+				point, ok := points[key]
+				if !ok {
+					point = &Point{RunUID: run.RunUID, Time: run.StartTime}
+					points[key] = point
+				}
+				addStatus(&point.Summary, record.Status)
+				return nil
+			})
+			if err != nil {
+				return err //nolint:wrapcheck // wrapped below
+			}
+			for key, point := range points {
+				trend, ok := trends[key]
+				if !ok {
+					trend = &Trend{Key: key}
+					trends[key] = trend
+				}
+				trend.Points = append(trend.Points, *point)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the history: %w", err)
+	}
+
+	result := make([]Trend, 0, len(trends))
+	for _, trend := range trends {
+		result = append(result, *trend)
+	}
+	slices.SortFunc(result, func(a, b Trend) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return result, nil
+}
+
+// matches returns true if the record is selected by the filters of the query.
+func (q Query) matches(record Record) bool {
+	if q.Namespace != "" && namespaceKey(record) != q.Namespace {
+		return false
+	}
+	if q.Policy != "" && record.Policy != q.Policy {
+		return false
+	}
+	if q.Resource != "" && resourceKey(record) != q.Resource {
+		return false
+	}
+	return true
+}
+
+// key returns the key of the trend the record belongs to.
+func (q Query) key(record Record) string {
+	switch q.GroupBy {
+	case GroupByPolicy:
+		return record.Policy
+	case GroupByResource:
+		return resourceKey(record)
+	default:
+		return namespaceKey(record)
+	}
+}
+
+func namespaceKey(record Record) string {
+	if record.Resource.Namespace == "" {
+		return ClusterKey
+	}
+	return record.Resource.Namespace
+}
+
+// resourceKey returns namespace/Kind/name, or Kind/name for the cluster-wide
+// resources.
+func resourceKey(record Record) string {
+	if record.Resource.Namespace == "" {
+		return record.Resource.Kind + "/" + record.Resource.Name
+	}
+	return record.Resource.Namespace + "/" + record.Resource.Kind + "/" + record.Resource.Name
+}
+
+func addStatus(summary *report.Summary, status string) {
+	switch status {
+	case report.StatusPass:
+		summary.Pass++
+	case report.StatusFail:
+		summary.Fail++
+	case report.StatusWarn:
+		summary.Warn++
+	case report.StatusError:
+		summary.Error++
+	case report.StatusSkip:
+		summary.Skip++
+	}
+}
@@ -0,0 +1,339 @@
+// Package history records the results of the scan runs in an embedded
+// database, so the compliance of the cluster can be followed over time.
+package history
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	bolt "go.etcd.io/bbolt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// runsBucket holds the runs, by run UID
+	runsBucket = []byte("runs")
+	// resultsBucket holds a bucket for each run, holding the results of the
+	// run by resource UID and policy
+	resultsBucket = []byte("results")
+)
+
+const (
+	fileMode = 0o600
+	// lockTimeout is the time waited for the lock of the database, held by
+	// the process writing to it
+	lockTimeout = 5 * time.Second
+)
+
+// Run describes a scan run recorded in the history.
+type Run struct {
+	RunUID string `json:"runUID"`
+	// StartTime is the time the first result of the run has been recorded
+	StartTime time.Time `json:"startTime"`
+}
+
+// Record is the result of a policy for a resource, in a scan run.
+type Record struct {
+	RunUID string    `json:"runUID"`
+	Time   time.Time `json:"time"`
+	// Resource is the audited resource
+	Resource corev1.ObjectReference `json:"resource"`
+	// Policy is the unique name of the policy
+	Policy   string `json:"policy"`
+	Status   string `json:"status"`
+	Severity string `json:"severity,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Store is a report.Store recording the results of the reports in a bbolt
+// database, keyed by run UID, resource UID and policy. The results are
+// buffered and written when the scan of a namespace, or of the cluster-wide
+// resources, is completed, or when the reports are flushed.
+// Only the latest runs are kept.
+//
+// The database is only opened while the records are written, so the history
+// can be queried while a scanner running in watch mode records it.
+//
+// The store doesn't return the reports of the previous scans, so it's meant
+// to be combined with another store by a report.MultiStore.
+type Store struct {
+	// path is the path of the database the records are written to
+	path string
+	// db is the database opened read-only by OpenReadOnly, it's nil for the
+	// stores writing the records
+	db *bolt.DB
+	// maxRuns is the number of runs kept in the history
+	maxRuns int
+	logger  *slog.Logger
+	// mutex protects pending
+	mutex sync.Mutex
+	// writeMutex serializes the writes, since the lock of the database is
+	// taken again by each of them
+	writeMutex sync.Mutex
+	// pending are the records not written yet
+	pending []Record
+}
+
+// NewStore creates the history database at the given path, unless it
+// exists, keeping the given number of runs.
+func NewStore(path string, maxRuns int, logger *slog.Logger) (*Store, error) {
+	if maxRuns < 1 {
+		return nil, errors.New("the number of runs kept in the history must be greater than zero")
+	}
+	store := &Store{
+		path:    path,
+		maxRuns: maxRuns,
+		logger:  logger.With("component", "historystore"),
+	}
+	err := store.update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(runsBucket); err != nil {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		_, err := tx.CreateBucketIfNotExists(resultsBucket)
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the history database %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Close closes the database opened by OpenReadOnly. The stores writing the
+// records don't keep the database open.
+func (s *Store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close the history database: %w", err)
+	}
+	return nil
+}
+
+// update opens the database, runs the given function in a read-write
+// transaction and closes the database, releasing its lock.
+func (s *Store) update(fn func(tx *bolt.Tx) error) (err error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	db, err := bolt.Open(s.path, fileMode, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open the history database %s: %w", s.path, err)
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close the history database %s: %w", s.path, closeErr))
+		}
+	}()
+
+	return db.Update(fn) //nolint:wrapcheck // wrapped by the callers
+}
+
+// view runs the given function in a read-only transaction, opening the
+// database for it unless it's already open.
+func (s *Store) view(fn func(tx *bolt.Tx) error) (err error) {
+	if s.db != nil {
+		return s.db.View(fn) //nolint:wrapcheck // wrapped by the callers
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	db, err := bolt.Open(s.path, fileMode, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open the history database %s: %w", s.path, err)
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close the history database %s: %w", s.path, closeErr))
+		}
+	}()
+
+	return db.View(fn) //nolint:wrapcheck // wrapped by the callers
+}
+
+// GetReport always returns constants.ErrResourceNotFound, the history doesn't
+// keep the reports.
+func (s *Store) GetReport(_ context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	return nil, fmt.Errorf("%w: the history doesn't keep report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+}
+
+// CreateOrPatchReport buffers the results of the given report.
+func (s *Store) CreateOrPatchReport(_ context.Context, obj any) error {
+	return s.buffer(obj)
+}
+
+// FlushReports writes the buffered results.
+func (s *Store) FlushReports(ctx context.Context, _, _ string) error {
+	return s.write(ctx)
+}
+
+// DeleteOldReports writes the buffered results. The results of the previous
+// runs are kept, the oldest runs are deleted when a new run is recorded.
+func (s *Store) DeleteOldReports(ctx context.Context, _, _ string) error {
+	return s.write(ctx)
+}
+
+// GetClusterReport always returns constants.ErrResourceNotFound, like GetReport.
+func (s *Store) GetClusterReport(_ context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	return nil, fmt.Errorf("%w: the history doesn't keep cluster report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+}
+
+// CreateOrPatchClusterReport buffers the results of the given cluster report.
+func (s *Store) CreateOrPatchClusterReport(_ context.Context, obj any) error {
+	return s.buffer(obj)
+}
+
+// FlushClusterReports writes the buffered results.
+func (s *Store) FlushClusterReports(ctx context.Context, _ string) error {
+	return s.write(ctx)
+}
+
+// DeleteOldClusterReports writes the buffered results, like DeleteOldReports.
+func (s *Store) DeleteOldClusterReports(ctx context.Context, _ string) error {
+	return s.write(ctx)
+}
+
+func (s *Store) buffer(obj any) error {
+	auditReport, ok := obj.(report.Report)
+	if !ok {
+		return fmt.Errorf("expected Report, got %T", obj)
+	}
+	scope := auditReport.GetScope()
+	if scope == nil {
+		return fmt.Errorf("cannot record report without scope %T", obj)
+	}
+	data, err := json.Marshal(auditReport)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the report of %s/%s: %w", scope.Namespace, scope.Name, err)
+	}
+	metadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return fmt.Errorf("failed to read the metadata of the report of %s/%s: %w", scope.Namespace, scope.Name, err)
+	}
+	runUID := metadata.Labels[auditConstants.AuditScannerRunUIDLabel]
+	now := time.Now().UTC()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, result := range auditReport.GetResults() {
+		s.pending = append(s.pending, Record{
+			RunUID:   runUID,
+			Time:     now,
+			Resource: *scope,
+			Policy:   result.Policy,
+			Status:   result.Status,
+			Severity: result.Severity,
+			Category: result.Category,
+		})
+	}
+	return nil
+}
+
+// write writes the buffered records in a single transaction, and deletes the
+// oldest runs when new ones are recorded.
+func (s *Store) write(ctx context.Context) error {
+	s.mutex.Lock()
+	records := s.pending
+	s.pending = nil
+	s.mutex.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := s.update(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(runsBucket)
+		results := tx.Bucket(resultsBucket)
+		newRun := false
+		for _, record := range records {
+			if runs.Get([]byte(record.RunUID)) == nil {
+				newRun = true
+				data, err := json.Marshal(Run{RunUID: record.RunUID, StartTime: record.Time})
+				if err != nil {
+					return fmt.Errorf("failed to marshal run %s: %w", record.RunUID, err)
+				}
+				if err := runs.Put([]byte(record.RunUID), data); err != nil {
+					return fmt.Errorf("failed to record run %s: %w", record.RunUID, err)
+				}
+			}
+			runResults, err := results.CreateBucketIfNotExists([]byte(record.RunUID))
+			if err != nil {
+				return fmt.Errorf("failed to create the results bucket of run %s: %w", record.RunUID, err)
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the result of policy %s: %w", record.Policy, err)
+			}
+			if err := runResults.Put(recordKey(record), data); err != nil {
+				return fmt.Errorf("failed to record the result of policy %s: %w", record.Policy, err)
+			}
+		}
+		if newRun {
+			return s.prune(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %d results to the history: %w", len(records), err)
+	}
+	s.logger.DebugContext(ctx, "results recorded in the history", slog.Int("results", len(records)))
+	return nil
+}
+
+// prune deletes the oldest runs, keeping maxRuns of them.
+func (s *Store) prune(tx *bolt.Tx) error {
+	runs, err := readRuns(tx)
+	if err != nil {
+		return err
+	}
+	if len(runs) <= s.maxRuns {
+		return nil
+	}
+	for _, run := range runs[:len(runs)-s.maxRuns] {
+		if err := tx.Bucket(runsBucket).Delete([]byte(run.RunUID)); err != nil {
+			return fmt.Errorf("failed to delete run %s: %w", run.RunUID, err)
+		}
+		if err := tx.Bucket(resultsBucket).DeleteBucket([]byte(run.RunUID)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("failed to delete the results of run %s: %w", run.RunUID, err)
+		}
+	}
+	return nil
+}
+
+// readRuns returns the recorded runs, oldest first.
+func readRuns(tx *bolt.Tx) ([]Run, error) {
+	var runs []Run
+	err := tx.Bucket(runsBucket).ForEach(func(_, value []byte) error {
+		var run Run
+		if err := json.Unmarshal(value, &run); err != nil {
+			return fmt.Errorf("failed to read run: %w", err)
+		}
+		runs = append(runs, run)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the runs: %w", err)
+	}
+	slices.SortFunc(runs, func(a, b Run) int {
+		return cmp.Or(a.StartTime.Compare(b.StartTime), cmp.Compare(a.RunUID, b.RunUID))
+	})
+	return runs, nil
+}
+
+// recordKey returns the key of a record in the bucket of its run. A resource
+// audited again in the same run, like in watch mode, replaces its previous result.
+func recordKey(record Record) []byte {
+	return []byte(string(record.Resource.UID) + "/" + record.Policy)
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestResource(kind, name, namespace string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind(kind)
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetUID(types.UID(name + "-uid"))
+	return resource
+}
+
+// newTestReport returns a report of the given run, with a result for each
+// policy, allowed or not.
+func newTestReport(runUID string, resource unstructured.Unstructured, results map[string]bool) report.Report {
+	var auditReport report.Report
+	if resource.GetNamespace() == "" {
+		auditReport = report.NewClusterPolicyReport(runUID, resource)
+	} else {
+		auditReport = report.NewPolicyReport(runUID, resource)
+	}
+	for policyName, allowed := range results {
+		policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: policyName}}
+		auditReport.AddResult(policy, &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed},
+		}, false, nil)
+	}
+	return auditReport
+}
+
+// recordRun records a scan run of a Pod in the default namespace and of a
+// cluster-wide Namespace.
+func recordRun(t *testing.T, store *Store, runUID string, podAllowed bool) {
+	t.Helper()
+
+	pod := newTestResource("Pod", "pod", "default")
+	namespace := newTestResource("Namespace", "default", "")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newTestReport(runUID, pod, map[string]bool{"a": podAllowed, "b": true})))
+	require.NoError(t, store.DeleteOldReports(t.Context(), runUID, "default"))
+	require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), newTestReport(runUID, namespace, map[string]bool{"a": false})))
+	require.NoError(t, store.DeleteOldClusterReports(t.Context(), runUID))
+}
+
+func TestStoreTrends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := NewStore(path, 10, slog.Default())
+	require.NoError(t, err)
+	recordRun(t, store, "run-1", true)
+	recordRun(t, store, "run-2", false)
+	// the results are only written when the scan of a namespace is completed
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newTestReport("run-3", newTestResource("Pod", "pod", "default"), map[string]bool{"a": true})))
+	require.NoError(t, store.Close())
+
+	store, err = OpenReadOnly(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	runs, err := store.Runs()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "run-1", runs[0].RunUID)
+	assert.Equal(t, "run-2", runs[1].RunUID)
+
+	trends, err := store.Trends(Query{GroupBy: GroupByNamespace})
+	require.NoError(t, err)
+	require.Len(t, trends, 2)
+	assert.Equal(t, ClusterKey, trends[0].Key)
+	assert.Equal(t, "default", trends[1].Key)
+	require.Len(t, trends[1].Points, 2)
+	assert.Equal(t, "run-1", trends[1].Points[0].RunUID)
+	assert.Equal(t, report.Summary{Pass: 2}, trends[1].Points[0].Summary)
+	assert.Equal(t, "run-2", trends[1].Points[1].RunUID)
+	assert.Equal(t, report.Summary{Pass: 1, Fail: 1}, trends[1].Points[1].Summary)
+
+	trends, err = store.Trends(Query{GroupBy: GroupByPolicy, Runs: 1})
+	require.NoError(t, err)
+	require.Len(t, trends, 2)
+	assert.Equal(t, "clusterwide-a", trends[0].Key)
+	require.Len(t, trends[0].Points, 1)
+	assert.Equal(t, "run-2", trends[0].Points[0].RunUID)
+	assert.Equal(t, report.Summary{Fail: 2}, trends[0].Points[0].Summary)
+	assert.Equal(t, "clusterwide-b", trends[1].Key)
+
+	trends, err = store.Trends(Query{GroupBy: GroupByResource, Policy: "clusterwide-a"})
+	require.NoError(t, err)
+	require.Len(t, trends, 2)
+	assert.Equal(t, "Namespace/default", trends[0].Key)
+	assert.Equal(t, "default/Pod/pod", trends[1].Key)
+	assert.Equal(t, report.Summary{Pass: 1}, trends[1].Points[0].Summary)
+	assert.Equal(t, report.Summary{Fail: 1}, trends[1].Points[1].Summary)
+
+	trends, err = store.Trends(Query{GroupBy: GroupByPolicy, Namespace: ClusterKey, Resource: "Namespace/default"})
+	require.NoError(t, err)
+	require.Len(t, trends, 1)
+	assert.Equal(t, "clusterwide-a", trends[0].Key)
+
+	_, err = store.Trends(Query{GroupBy: "kind"})
+	require.Error(t, err)
+}
+
+func TestStorePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := NewStore(path, 2, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	recordRun(t, store, "run-1", true)
+	recordRun(t, store, "run-2", true)
+	recordRun(t, store, "run-3", false)
+
+	runs, err := store.Runs()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "run-2", runs[0].RunUID)
+	assert.Equal(t, "run-3", runs[1].RunUID)
+	trends, err := store.Trends(Query{GroupBy: GroupByNamespace, Namespace: "default"})
+	require.NoError(t, err)
+	require.Len(t, trends, 1)
+	require.Len(t, trends[0].Points, 2)
+	assert.Equal(t, "run-2", trends[0].Points[0].RunUID)
+}
+
+func TestStoreReleasesTheDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := NewStore(path, 10, slog.Default())
+	require.NoError(t, err)
+	recordRun(t, store, "run-1", true)
+
+	// the history can be queried while the store, like the one of a scanner
+	// in watch mode, is still in use
+	readOnlyStore, err := OpenReadOnly(path)
+	require.NoError(t, err)
+	runs, err := readOnlyStore.Runs()
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.NoError(t, readOnlyStore.Close())
+
+	recordRun(t, store, "run-2", true)
+	runs, err = store.Runs()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+}
+
+func TestStoreReports(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "history.db"), 1, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	// the history doesn't return the reports of the previous scans
+	_, err = store.GetReport(t.Context(), newTestResource("Pod", "pod", "default"))
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+	_, err = store.GetClusterReport(t.Context(), newTestResource("Namespace", "default", ""))
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	require.Error(t, store.CreateOrPatchReport(t.Context(), "not a report"))
+
+	_, err = NewStore(filepath.Join(t.TempDir(), "history.db"), 0, slog.Default())
+	require.Error(t, err)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
@@ -24,6 +26,8 @@ type Client struct {
 	clientset kubernetes.Interface
 	// list of skipped namespaces from audit, by name. It includes kubewardenNamespace
 	skippedNs []string
+	// namespaceFilter selects the namespaces to audit
+	namespaceFilter NamespaceFilter
 	// pageSize is the number of resources to fetch when paginating
 	pageSize int64
 	// logger is used to log the messages
@@ -31,13 +35,15 @@ type Client struct {
 }
 
 // NewClient returns a new client.
-func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface, kubewardenNamespace string, skippedNs []string, pageSize int64, logger *slog.Logger) *Client {
+// The skipped namespaces are never audited, unless they are explicitly listed in the names of the namespaceFilter.
+func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface, kubewardenNamespace string, skippedNs []string, namespaceFilter NamespaceFilter, pageSize int64, logger *slog.Logger) *Client {
 	skippedNs = append(skippedNs, kubewardenNamespace)
 
 	return &Client{
 		dynamicClient,
 		clientset,
 		skippedNs,
+		namespaceFilter,
 		pageSize,
 		logger.With("component", "k8sclient"),
 	}
@@ -73,22 +79,87 @@ func (f *Client) listResources(ctx context.Context,
 	return list, nil
 }
 
-// GetAuditedNamespaces gets all namespaces besides the ones in skippedNs.
+// GetAuditedNamespaces gets the namespaces selected by the namespace filter,
+// besides the ones in skippedNs.
 func (f *Client) GetAuditedNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
-	// This function cannot be tested with fake client, as filtering is done server-side
+	namespaceList := &corev1.NamespaceList{}
+
+	if len(f.namespaceFilter.Names) > 0 {
+		for _, nsName := range f.namespaceFilter.Names {
+			namespace, err := f.clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				f.logger.WarnContext(ctx, "namespace not found, skipping", slog.String("ns", nsName))
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("can't get namespace %s: %w", nsName, err)
+			}
+			if f.namespaceFilter.matches(namespace) {
+				namespaceList.Items = append(namespaceList.Items, *namespace)
+			}
+		}
+		return namespaceList, nil
+	}
+
+	// the skipped namespaces and the label selector are filtered server-side,
+	// the name patterns client-side
 	skipNsFields := fields.Everything()
 	for _, nsName := range f.skippedNs {
 		skipNsFields = fields.AndSelectors(skipNsFields, fields.OneTermNotEqualSelector("metadata.name", nsName))
 		f.logger.DebugContext(ctx, "skipping ns", slog.String("ns", nsName))
 	}
+	opts := metav1.ListOptions{FieldSelector: skipNsFields.String()}
+	if !f.namespaceFilter.selectorEmpty() {
+		opts.LabelSelector = f.namespaceFilter.Selector.String()
+	}
 
-	namespaceList, err := f.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{FieldSelector: skipNsFields.String()})
+	listPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return f.clientset.CoreV1().Namespaces().List(ctx, opts)
+	}))
+	listPager.PageSize = f.pageSize
+	err := listPager.EachListItem(ctx, opts, func(obj runtime.Object) error {
+		namespace, ok := obj.(*corev1.Namespace)
+		if !ok {
+			return fmt.Errorf("unexpected object of type %T in the namespace list", obj)
+		}
+		if !f.IsNamespaceSkipped(namespace.Name) && f.namespaceFilter.matches(namespace) {
+			namespaceList.Items = append(namespaceList.Items, *namespace)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("can't list namespaces: %w", err)
 	}
 	return namespaceList, nil
 }
 
+// IsNamespaceSkipped returns true if the given namespace is skipped from audit,
+// according to its name.
+func (f *Client) IsNamespaceSkipped(nsName string) bool {
+	if len(f.namespaceFilter.Names) == 0 && slices.Contains(f.skippedNs, nsName) {
+		return true
+	}
+
+	return !f.namespaceFilter.matchesName(nsName)
+}
+
+// IsNamespaceAudited returns true if the given namespace is selected for audit.
+// Unlike IsNamespaceSkipped, it also checks the labels of the namespace.
+func (f *Client) IsNamespaceAudited(ctx context.Context, nsName string) (bool, error) {
+	if f.IsNamespaceSkipped(nsName) {
+		return false, nil
+	}
+	if f.namespaceFilter.selectorEmpty() {
+		return true, nil
+	}
+
+	namespace, err := f.GetNamespace(ctx, nsName)
+	if err != nil {
+		return false, err
+	}
+	return f.namespaceFilter.matches(namespace), nil
+}
+
 func (f *Client) GetNamespace(ctx context.Context, nsName string) (*corev1.Namespace, error) {
 	namespace, err := f.clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
 	if err != nil {
@@ -29,7 +29,7 @@ func TestGetResources(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 
 	logger := slog.Default()
-	k8sClient := NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := NewClient(dynamicClient, clientset, "kubewarden", nil, NamespaceFilter{}, pageSize, logger)
 
 	pager := k8sClient.GetResources(schema.GroupVersionResource{
 		Group:    "",
@@ -46,3 +46,98 @@ func TestGetResources(t *testing.T) {
 	assert.Len(t, unstructuredList.Items, pageSize+5)
 	assert.Equal(t, "PodList", unstructuredList.GetObjectKind().GroupVersionKind().Kind)
 }
+
+func TestGetAuditedNamespaces(t *testing.T) {
+	namespaces := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kubewarden"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a-prod", Labels: map[string]string{"team": "a", "env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a-dev", Labels: map[string]string{"team": "a", "env": "dev"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b-prod", Labels: map[string]string{"team": "b", "env": "prod"}}},
+	}
+
+	tests := []struct {
+		name               string
+		names              []string
+		selector           string
+		include            []string
+		exclude            []string
+		expectedNamespaces []string
+	}{
+		{
+			name:               "all namespaces",
+			expectedNamespaces: []string{"team-a-prod", "team-a-dev", "team-b-prod"},
+		},
+		{
+			name:               "names",
+			names:              []string{"kube-system", "team-a-dev", "missing"},
+			expectedNamespaces: []string{"kube-system", "team-a-dev"},
+		},
+		{
+			name:               "label selector",
+			selector:           "env=prod",
+			expectedNamespaces: []string{"team-a-prod", "team-b-prod"},
+		},
+		{
+			name:               "glob patterns",
+			include:            []string{"team-*"},
+			exclude:            []string{"*-dev"},
+			expectedNamespaces: []string{"team-a-prod", "team-b-prod"},
+		},
+		{
+			name:               "regex patterns",
+			include:            []string{"regex:team-(a|b)-prod"},
+			expectedNamespaces: []string{"team-a-prod", "team-b-prod"},
+		},
+		{
+			name:               "label selector and names",
+			names:              []string{"team-a-prod", "team-a-dev"},
+			selector:           "env=prod",
+			expectedNamespaces: []string{"team-a-prod"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(namespaces...)
+			dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme)
+
+			namespaceFilter, err := NewNamespaceFilter(test.names, test.selector, test.include, test.exclude)
+			require.NoError(t, err)
+			k8sClient := NewClient(dynamicClient, clientset, "kubewarden", []string{"kube-system"}, namespaceFilter, pageSize, slog.Default())
+
+			namespaceList, err := k8sClient.GetAuditedNamespaces(t.Context())
+			require.NoError(t, err)
+
+			var namespaceNames []string
+			for _, namespace := range namespaceList.Items {
+				namespaceNames = append(namespaceNames, namespace.Name)
+			}
+			assert.ElementsMatch(t, test.expectedNamespaces, namespaceNames)
+		})
+	}
+}
+
+func TestIsNamespaceAudited(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a-prod", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a-dev", Labels: map[string]string{"env": "dev"}}},
+	)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(scheme.Scheme)
+
+	namespaceFilter, err := NewNamespaceFilter(nil, "env=prod", []string{"team-*"}, nil)
+	require.NoError(t, err)
+	k8sClient := NewClient(dynamicClient, clientset, "kubewarden", nil, namespaceFilter, pageSize, slog.Default())
+
+	assert.True(t, k8sClient.IsNamespaceSkipped("kubewarden"))
+	assert.True(t, k8sClient.IsNamespaceSkipped("default"))
+	assert.False(t, k8sClient.IsNamespaceSkipped("team-a-dev"))
+
+	audited, err := k8sClient.IsNamespaceAudited(t.Context(), "team-a-prod")
+	require.NoError(t, err)
+	assert.True(t, audited)
+
+	audited, err = k8sClient.IsNamespaceAudited(t.Context(), "team-a-dev")
+	require.NoError(t, err)
+	assert.False(t, audited)
+}
@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// regexPatternPrefix marks the namespace patterns that are regular expressions
+// instead of shell patterns.
+const regexPatternPrefix = "regex:"
+
+// NamespaceFilter selects the namespaces to audit.
+type NamespaceFilter struct {
+	// Names lists the namespaces to audit. When empty, all the namespaces
+	// but the skipped ones are audited
+	Names []string
+	// Selector is the label selector the audited namespaces must match
+	Selector labels.Selector
+	// Include are the patterns the names of the audited namespaces must match.
+	// All the namespaces are included when empty
+	Include []NamePattern
+	// Exclude are the patterns of the names of the namespaces never audited
+	Exclude []NamePattern
+}
+
+// NewNamespaceFilter returns the filter selecting the given namespaces, the
+// ones matching the label selector and the include patterns, but not the
+// exclude patterns.
+func NewNamespaceFilter(names []string, selector string, include, exclude []string) (NamespaceFilter, error) {
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		return NamespaceFilter{}, fmt.Errorf("invalid namespace selector %q: %w", selector, err)
+	}
+	includePatterns, err := parseNamePatterns(include)
+	if err != nil {
+		return NamespaceFilter{}, err
+	}
+	excludePatterns, err := parseNamePatterns(exclude)
+	if err != nil {
+		return NamespaceFilter{}, err
+	}
+
+	return NamespaceFilter{
+		Names:    names,
+		Selector: labelSelector,
+		Include:  includePatterns,
+		Exclude:  excludePatterns,
+	}, nil
+}
+
+// SingleNamespace returns the only namespace selected by the filter, or an
+// empty string when the filter can select more than one namespace.
+func (n NamespaceFilter) SingleNamespace() string {
+	if len(n.Names) != 1 || !n.selectorEmpty() || len(n.Include) > 0 || len(n.Exclude) > 0 {
+		return ""
+	}
+
+	return n.Names[0]
+}
+
+// RestrictsScope returns true if the filter selects some namespaces only,
+// instead of excluding some of them.
+func (n NamespaceFilter) RestrictsScope() bool {
+	return len(n.Names) > 0 || !n.selectorEmpty() || len(n.Include) > 0
+}
+
+func (n NamespaceFilter) selectorEmpty() bool {
+	return n.Selector == nil || n.Selector.Empty()
+}
+
+// matchesName returns true if the name of the namespace is selected by the filter.
+func (n NamespaceFilter) matchesName(nsName string) bool {
+	if len(n.Names) > 0 && !slices.Contains(n.Names, nsName) {
+		return false
+	}
+	if len(n.Include) > 0 && !matchesAnyPattern(n.Include, nsName) {
+		return false
+	}
+
+	return !matchesAnyPattern(n.Exclude, nsName)
+}
+
+// matches returns true if the namespace is selected by the filter.
+func (n NamespaceFilter) matches(namespace *corev1.Namespace) bool {
+	if !n.matchesName(namespace.Name) {
+		return false
+	}
+
+	return n.selectorEmpty() || n.Selector.Matches(labels.Set(namespace.Labels))
+}
+
+// NamePattern matches the names of the namespaces. It's either a shell
+// pattern, like "team-*", or a regular expression prefixed by "regex:", like
+// "regex:team-(a|b)". Regular expressions must match the whole name.
+type NamePattern struct {
+	glob   string
+	regexp *regexp.Regexp
+}
+
+// ParseNamePattern parses a namespace name pattern.
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	if expression, found := strings.CutPrefix(pattern, regexPatternPrefix); found {
+		compiled, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return NamePattern{}, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+		return NamePattern{regexp: compiled}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return NamePattern{}, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+	}
+
+	return NamePattern{glob: pattern}, nil
+}
+
+// Match returns true if the given namespace name matches the pattern.
+func (p NamePattern) Match(nsName string) bool {
+	if p.regexp != nil {
+		return p.regexp.MatchString(nsName)
+	}
+	matched, err := path.Match(p.glob, nsName)
+
+	return err == nil && matched
+}
+
+func parseNamePatterns(patterns []string) ([]NamePattern, error) {
+	namePatterns := make([]NamePattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		namePattern, err := ParseNamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		namePatterns = append(namePatterns, namePattern)
+	}
+
+	return namePatterns, nil
+}
+
+func matchesAnyPattern(patterns []NamePattern, nsName string) bool {
+	return slices.ContainsFunc(patterns, func(pattern NamePattern) bool {
+		return pattern.Match(nsName)
+	})
+}
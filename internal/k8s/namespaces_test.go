@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNamePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"team-*", "team-a", true},
+		{"team-*", "other-team-a", false},
+		{"regex:team-(a|b)", "team-a", true},
+		{"regex:team-(a|b)", "team-ab", false},
+		{"regex:team-.*", "my-team-a", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.name, func(t *testing.T) {
+			pattern, err := ParseNamePattern(test.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, pattern.Match(test.name))
+		})
+	}
+
+	_, err := ParseNamePattern("regex:team-(a")
+	require.Error(t, err)
+	_, err = ParseNamePattern("team-[a")
+	require.Error(t, err)
+}
+
+func TestNamespaceFilterScope(t *testing.T) {
+	filter, err := NewNamespaceFilter([]string{"default"}, "", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "default", filter.SingleNamespace())
+	assert.True(t, filter.RestrictsScope())
+
+	filter, err = NewNamespaceFilter([]string{"default"}, "env=prod", nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, filter.SingleNamespace())
+	assert.True(t, filter.RestrictsScope())
+
+	filter, err = NewNamespaceFilter(nil, "", nil, []string{"*-dev"})
+	require.NoError(t, err)
+	assert.Empty(t, filter.SingleNamespace())
+	assert.False(t, filter.RestrictsScope())
+
+	_, err = NewNamespaceFilter(nil, "env in (prod", nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package manifests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Stdin is the path used to read the manifests from the standard input.
+const Stdin = "-"
+
+const decoderBufferSize = 4096
+
+// Load reads the Kubernetes resources defined in the given paths. A path can
+// be a file, a directory, whose YAML and JSON files are read recursively, or
+// Stdin. Files can contain multiple YAML documents and List resources, which
+// are expanded into their items.
+func Load(paths []string, stdin io.Reader) ([]unstructured.Unstructured, error) {
+	var resources []unstructured.Unstructured
+	for _, path := range paths {
+		if path == Stdin {
+			decoded, err := Decode(stdin, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, decoded...)
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return fmt.Errorf("cannot read %s: %w", file, err)
+			}
+			// the files given explicitly are read regardless of their extension
+			if entry.IsDir() || (file != path && !isManifestFile(file)) {
+				return nil
+			}
+			decoded, err := decodeFile(file)
+			if err != nil {
+				return err
+			}
+			resources = append(resources, decoded...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifests from %s: %w", path, err)
+		}
+	}
+
+	return resources, nil
+}
+
+// Decode reads the Kubernetes resources of a YAML or JSON stream. The source
+// identifies the stream in the errors.
+func Decode(reader io.Reader, source string) ([]unstructured.Unstructured, error) {
+	var resources []unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, decoderBufferSize)
+	for document := 1; ; document++ {
+		object := map[string]any{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resources, nil
+			}
+			return nil, fmt.Errorf("cannot decode document %d of %s: %w", document, source, err)
+		}
+		if len(object) == 0 {
+			// empty document, e.g. a trailing `---`
+			continue
+		}
+
+		resource := unstructured.Unstructured{Object: object}
+		if resource.GetAPIVersion() == "" || resource.GetKind() == "" {
+			return nil, fmt.Errorf("document %d of %s is not a Kubernetes resource: apiVersion and kind are required", document, source)
+		}
+		if !resource.IsList() {
+			resources = append(resources, resource)
+			continue
+		}
+
+		list, err := resource.ToList()
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode the list in document %d of %s: %w", document, source, err)
+		}
+		resources = append(resources, list.Items...)
+	}
+}
+
+func decodeFile(path string) ([]unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return Decode(file, path)
+}
+
+func isManifestFile(path string) bool {
+	return slices.Contains([]string{".yaml", ".yml", ".json"}, strings.ToLower(filepath.Ext(path)))
+}
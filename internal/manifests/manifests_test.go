@@ -0,0 +1,72 @@
+package manifests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multiDocument = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: staging
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: staging
+---
+`
+
+const list = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod1"}},
+    {"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod2"}}
+  ]
+}`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(multiDocument), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "pods.json"), []byte(list), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0o600))
+
+	stdin := strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n")
+	resources, err := Load([]string{dir, Stdin}, stdin)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, resource := range resources {
+		names = append(names, resource.GetKind()+"/"+resource.GetName())
+	}
+	assert.Equal(t, []string{"Namespace/staging", "Deployment/nginx", "Pod/pod1", "Pod/pod2", "ConfigMap/config"}, names)
+}
+
+func TestLoadFile(t *testing.T) {
+	// files given explicitly are read regardless of their extension
+	file := filepath.Join(t.TempDir(), "rendered.out")
+	require.NoError(t, os.WriteFile(file, []byte(multiDocument), 0o600))
+
+	resources, err := Load([]string{file}, nil)
+	require.NoError(t, err)
+	assert.Len(t, resources, 2)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(strings.NewReader("metadata:\n  name: nginx\n"), "test")
+	require.ErrorContains(t, err, "document 1 of test is not a Kubernetes resource")
+
+	_, err = Decode(strings.NewReader("apiVersion: v1\nkind: Pod\n---\n: invalid\n"), "test")
+	require.ErrorContains(t, err, "cannot decode document 2 of test")
+
+	_, err = Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace  = "kubewarden_audit_scanner"
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Status of a policy evaluation.
+const (
+	EvaluationPass  = "pass"
+	EvaluationFail  = "fail"
+	EvaluationError = "error"
+)
+
+// Operations of the report store.
+const (
+	StoreOperationWriteReport          = "write_report"
+	StoreOperationWriteClusterReport   = "write_cluster_report"
+	StoreOperationDeleteReports        = "delete_reports"
+	StoreOperationDeleteClusterReports = "delete_cluster_reports"
+	StoreOperationFlushReports         = "flush_reports"
+	StoreOperationFlushClusterReports  = "flush_cluster_reports"
+)
+
+// Metrics collects the Prometheus metrics of the audit scanner.
+// All the methods are no-op on a nil Metrics, so the code doesn't need to
+// check if the metrics are enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	evaluations        *prometheus.CounterVec
+	evaluationDuration *prometheus.HistogramVec
+	results            *prometheus.CounterVec
+	resourcesListed    *prometheus.CounterVec
+	listFailures       *prometheus.CounterVec
+	reportStoreErrors  *prometheus.CounterVec
+	runDuration        *prometheus.HistogramVec
+}
+
+// NewMetrics creates the metrics and registers them in a new registry,
+// together with the Go runtime and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		evaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "policy_evaluations_total",
+			Help:      "Number of evaluations sent to the PolicyServers, by policy, PolicyServer and status.",
+		}, []string{"policy", "policy_server", "status"}),
+		evaluationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "policy_evaluation_duration_seconds",
+			Help:      "Latency of the evaluations sent to the PolicyServers, by PolicyServer.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"policy_server"}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "results_total",
+			Help:      "Number of results written in the reports, by status.",
+		}, []string{"status"}),
+		resourcesListed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "resources_listed_total",
+			Help:      "Number of resources listed to be audited, by GroupVersionResource.",
+		}, []string{"resource"}),
+		listFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "list_failures_total",
+			Help:      "Number of failures listing the resources to be audited, by GroupVersionResource.",
+		}, []string{"resource"}),
+		reportStoreErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "report_store_errors_total",
+			Help:      "Number of failures writing or deleting reports, by operation.",
+		}, []string{"operation"}),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of the scan runs, by scope and outcome.",
+			// from 1 second to about 4.5 hours
+			Buckets: prometheus.ExponentialBuckets(1, 2, 15), //nolint:mnd // bucket layout
+		}, []string{"scope", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.evaluations,
+		m.evaluationDuration,
+		m.results,
+		m.resourcesListed,
+		m.listFailures,
+		m.reportStoreErrors,
+		m.runDuration,
+	)
+
+	return m
+}
+
+// Handler returns the HTTP handler exposing the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics on the given address until the context is canceled.
+func (m *Metrics) Serve(ctx context.Context, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics on %s: %w", address, err)
+	}
+	return nil
+}
+
+// RecordEvaluation records an evaluation sent to a PolicyServer.
+func (m *Metrics) RecordEvaluation(policy, policyServer, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.evaluations.WithLabelValues(policy, policyServer, status).Inc()
+	m.evaluationDuration.WithLabelValues(policyServer).Observe(duration.Seconds())
+}
+
+// RecordResults records the results of a report. The policies skipped or
+// errored for the whole namespace are not counted, since they are not results.
+func (m *Metrics) RecordResults(results []report.Result) {
+	if m == nil {
+		return
+	}
+	summary := report.SummarizeResults(results)
+	m.results.WithLabelValues("pass").Add(float64(summary.Pass))
+	m.results.WithLabelValues("fail").Add(float64(summary.Fail))
+	m.results.WithLabelValues("warn").Add(float64(summary.Warn))
+	m.results.WithLabelValues("error").Add(float64(summary.Error))
+	m.results.WithLabelValues("skip").Add(float64(summary.Skip))
+}
+
+// RecordResourceListed records a resource listed to be audited.
+func (m *Metrics) RecordResourceListed(resource string) {
+	if m == nil {
+		return
+	}
+	m.resourcesListed.WithLabelValues(resource).Inc()
+}
+
+// RecordListFailure records a failure listing the resources to be audited.
+func (m *Metrics) RecordListFailure(resource string) {
+	if m == nil {
+		return
+	}
+	m.listFailures.WithLabelValues(resource).Inc()
+}
+
+// RecordReportStoreError records a failure writing or deleting reports.
+func (m *Metrics) RecordReportStoreError(operation string) {
+	if m == nil {
+		return
+	}
+	m.reportStoreErrors.WithLabelValues(operation).Inc()
+}
+
+// RecordRun records the duration of a scan run.
+func (m *Metrics) RecordRun(scope, outcome string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.runDuration.WithLabelValues(scope, outcome).Observe(duration.Seconds())
+}
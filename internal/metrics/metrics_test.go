@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+
+	m.RecordEvaluation("clusterwide-policy", "default", EvaluationPass, 100*time.Millisecond)
+	m.RecordEvaluation("clusterwide-policy", "default", EvaluationFail, 200*time.Millisecond)
+	m.RecordResults([]report.Result{
+		{Policy: "clusterwide-policy", Status: report.StatusPass},
+		{Policy: "clusterwide-other-policy", Status: report.StatusPass},
+		{Policy: "clusterwide-failing-policy", Status: report.StatusFail},
+	})
+	m.RecordResourceListed("/v1, Resource=pods")
+	m.RecordListFailure("apps/v1, Resource=deployments")
+	m.RecordReportStoreError(StoreOperationWriteReport)
+	m.RecordRun("all", "completed", time.Minute)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `kubewarden_audit_scanner_policy_evaluations_total{policy="clusterwide-policy",policy_server="default",status="pass"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_policy_evaluations_total{policy="clusterwide-policy",policy_server="default",status="fail"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_policy_evaluation_duration_seconds_count{policy_server="default"} 2`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_results_total{status="pass"} 2`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_results_total{status="fail"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_resources_listed_total{resource="/v1, Resource=pods"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_list_failures_total{resource="apps/v1, Resource=deployments"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_report_store_errors_total{operation="write_report"} 1`)
+	assert.Contains(t, body, `kubewarden_audit_scanner_run_duration_seconds_count{outcome="completed",scope="all"} 1`)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+
+	// all the methods are no-op on nil metrics
+	m.RecordEvaluation("clusterwide-policy", "default", EvaluationPass, time.Second)
+	m.RecordResults([]report.Result{{Policy: "clusterwide-policy", Status: report.StatusPass}})
+	m.RecordResourceListed("/v1, Resource=pods")
+	m.RecordListFailure("/v1, Resource=pods")
+	m.RecordReportStoreError(StoreOperationWriteReport)
+	m.RecordRun("all", "completed", time.Minute)
+}
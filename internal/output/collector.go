@@ -0,0 +1,90 @@
+package output
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Collector collects the results of the reports of a scan run, to render them
+// once the run is completed.
+// It's safe for concurrent use. All the methods are no-op on a nil Collector,
+// so the code auditing the resources doesn't need to check if the results are
+// collected.
+type Collector struct {
+	mutex     sync.Mutex
+	resources []ResourceResults
+}
+
+// ResourceResults holds the results of an audited resource.
+type ResourceResults struct {
+	// Resource is the reference to the audited resource
+	Resource corev1.ObjectReference
+	Results  []report.Result
+}
+
+// NewCollector creates a new empty Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add collects the results of the given report.
+func (c *Collector) Add(auditReport report.Report) {
+	if c == nil {
+		return
+	}
+	resourceResults := ResourceResults{Results: auditReport.GetResults()}
+	if scope := auditReport.GetScope(); scope != nil {
+		resourceResults.Resource = *scope
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.resources = append(c.resources, resourceResults)
+}
+
+// Resources returns the collected results, sorted by resource and policy to
+// have a deterministic output.
+func (c *Collector) Resources() []ResourceResults {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	resources := make([]ResourceResults, 0, len(c.resources))
+	for _, resourceResults := range c.resources {
+		results := slices.Clone(resourceResults.Results)
+		slices.SortStableFunc(results, func(a, b report.Result) int {
+			return cmp.Compare(a.Policy, b.Policy)
+		})
+		resources = append(resources, ResourceResults{Resource: resourceResults.Resource, Results: results})
+	}
+	slices.SortStableFunc(resources, func(a, b ResourceResults) int {
+		return cmp.Or(
+			cmp.Compare(a.Resource.Namespace, b.Resource.Namespace),
+			cmp.Compare(a.Resource.Kind, b.Resource.Kind),
+			cmp.Compare(a.Resource.Name, b.Resource.Name),
+			cmp.Compare(a.Resource.UID, b.Resource.UID),
+		)
+	})
+	return resources
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the given collector.
+// The scanner adds the reports of the run to the collector found in the context.
+func NewContext(ctx context.Context, collector *Collector) context.Context {
+	return context.WithValue(ctx, contextKey{}, collector)
+}
+
+// FromContext returns the collector carried by ctx, or nil if there's none.
+func FromContext(ctx context.Context) *Collector {
+	collector, _ := ctx.Value(contextKey{}).(*Collector)
+	return collector
+}
@@ -0,0 +1,62 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestReport(name, namespace string, policyNames ...string) report.Report {
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetUID(types.UID(name + "-uid"))
+
+	policyReport := report.NewPolicyReport("run-uid", resource)
+	for _, policyName := range policyNames {
+		policy := &policiesv1.ClusterAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: policyName}}
+		policyReport.AddResult(policy, &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: true},
+		}, false, nil)
+	}
+	return policyReport
+}
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+	ctx := NewContext(t.Context(), collector)
+	FromContext(ctx).Add(newTestReport("pod2", "default", "policy-a"))
+	FromContext(ctx).Add(newTestReport("pod1", "kube-system", "policy-a"))
+	FromContext(ctx).Add(newTestReport("pod1", "default", "policy-b", "policy-a"))
+
+	resources := collector.Resources()
+	require.Len(t, resources, 3)
+	// sorted by namespace and name
+	assert.Equal(t, "default/Pod/pod1", resourceID(resources[0].Resource))
+	assert.Equal(t, "default/Pod/pod2", resourceID(resources[1].Resource))
+	assert.Equal(t, "kube-system/Pod/pod1", resourceID(resources[2].Resource))
+	assert.Equal(t, types.UID("pod1-uid"), resources[0].Resource.UID)
+	// the results are sorted by policy
+	require.Len(t, resources[0].Results, 2)
+	assert.Equal(t, "clusterwide-policy-a", resources[0].Results[0].Policy)
+	assert.Equal(t, "clusterwide-policy-b", resources[0].Results[1].Policy)
+	assert.Equal(t, report.StatusPass, resources[0].Results[0].Status)
+}
+
+func TestNilCollector(t *testing.T) {
+	collector := FromContext(t.Context())
+	require.Nil(t, collector)
+
+	// all the methods are no-op on a nil collector
+	collector.Add(newTestReport("pod", "default", "policy"))
+	assert.Empty(t, collector.Resources())
+}
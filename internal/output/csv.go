@@ -0,0 +1,42 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// csvHeader are the columns of the CSV output, one row for each result.
+var csvHeader = []string{"namespace", "kind", "name", "uid", "policy", "status", "severity", "category", "message"}
+
+// writeCSV renders the results as CSV, with a row for each result.
+func writeCSV(w io.Writer, document Document) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write the CSV header: %w", err)
+	}
+	for _, resourceResults := range document.Resources {
+		resource := resourceResults.Resource
+		for _, result := range resourceResults.Results {
+			record := []string{
+				resource.Namespace,
+				resource.Kind,
+				resource.Name,
+				string(resource.UID),
+				result.Policy,
+				result.Status,
+				result.Severity,
+				result.Category,
+				result.Message,
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write the CSV record of %s: %w", resourceID(resource), err)
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write the CSV output: %w", err)
+	}
+	return nil
+}
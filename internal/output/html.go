@@ -0,0 +1,67 @@
+package output
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlTemplate is a self-contained page, without external stylesheets or
+// scripts, so that it can be archived or attached to a CI run as is.
+var htmlTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"resourceID": resourceID,
+	"formatTime": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Kubewarden audit scan {{ .Summary.RunUID }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.pass { color: #1a7f37; }
+.fail { color: #cf222e; font-weight: bold; }
+.error { color: #9a6700; font-weight: bold; }
+.warn { color: #9a6700; }
+.skip { color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>Kubewarden audit scan</h1>
+<table>
+<tr><th>Run UID</th><td>{{ .Summary.RunUID }}</td></tr>
+<tr><th>Scope</th><td>{{ .Summary.Scope }}{{ with .Summary.Namespace }} ({{ . }}){{ end }}</td></tr>
+<tr><th>Outcome</th><td>{{ .Summary.Outcome }}{{ with .Summary.Error }}: {{ . }}{{ end }}</td></tr>
+<tr><th>Start time</th><td>{{ if not .Summary.StartTime.IsZero }}{{ formatTime .Summary.StartTime }}{{ end }}</td></tr>
+<tr><th>End time</th><td>{{ with .Summary.EndTime }}{{ formatTime . }}{{ end }}</td></tr>
+<tr><th>Namespaces audited</th><td>{{ .Summary.NamespacesAudited }}</td></tr>
+<tr><th>Resources audited</th><td>{{ .Summary.ResourcesAudited }}</td></tr>
+</table>
+<h2>Results</h2>
+<table>
+<tr><th class="pass">Pass</th><th class="fail">Fail</th><th class="warn">Warn</th><th class="error">Error</th><th class="skip">Skip</th></tr>
+<tr><td>{{ .Summary.Results.Pass }}</td><td>{{ .Summary.Results.Fail }}</td><td>{{ .Summary.Results.Warn }}</td><td>{{ .Summary.Results.Error }}</td><td>{{ .Summary.Results.Skip }}</td></tr>
+</table>
+<table>
+<tr><th>Resource</th><th>Policy</th><th>Status</th><th>Severity</th><th>Category</th><th>Message</th></tr>
+{{- range .Resources }}
+{{- $resource := resourceID .Resource }}
+{{- range .Results }}
+<tr><td>{{ $resource }}</td><td>{{ .Policy }}</td><td class="{{ .Status }}">{{ .Status }}</td><td>{{ .Severity }}</td><td>{{ .Category }}</td><td>{{ .Message }}</td></tr>
+{{- end }}
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// writeHTML renders the summary of the run and its results as an HTML page.
+func writeHTML(w io.Writer, document Document) error {
+	if err := htmlTemplate.Execute(w, document); err != nil {
+		return fmt.Errorf("failed to write the HTML summary: %w", err)
+	}
+	return nil
+}
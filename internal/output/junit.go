@@ -0,0 +1,107 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// writeJUnit renders the results as a JUnit XML report, with a test suite for
+// each policy and a test case for each resource evaluated by the policy.
+// The fail results are reported as failures, the error results as errors and
+// the skip results as skipped tests.
+func writeJUnit(w io.Writer, document Document) error {
+	suites := map[string]*junitTestSuite{}
+	testSuites := junitTestSuites{Name: toolName}
+	for _, resourceResults := range document.Resources {
+		id := resourceID(resourceResults.Resource)
+		for _, result := range resourceResults.Results {
+			suite, found := suites[result.Policy]
+			if !found {
+				suite = &junitTestSuite{Name: result.Policy}
+				suites[result.Policy] = suite
+			}
+			testCase := junitTestCase{Name: id, ClassName: result.Policy}
+			switch {
+			case result.Status == report.StatusFail:
+				testCase.Failure = &junitFailure{Message: resultMessage(id, result), Type: result.Severity, Text: result.Message}
+				suite.Failures++
+			case result.Status == report.StatusError:
+				testCase.Error = &junitFailure{Message: resultMessage(id, result), Type: result.Status, Text: result.Message}
+				suite.Errors++
+			case result.Status == report.StatusSkip:
+				testCase.Skipped = &junitSkipped{Message: result.Message}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+	}
+
+	policies := make([]string, 0, len(suites))
+	for policy := range suites {
+		policies = append(policies, policy)
+	}
+	slices.Sort(policies)
+	for _, policy := range policies {
+		suite := suites[policy]
+		testSuites.Tests += suite.Tests
+		testSuites.Failures += suite.Failures
+		testSuites.Errors += suite.Errors
+		testSuites.Skipped += suite.Skipped
+		testSuites.Suites = append(testSuites.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write the JUnit report: %w", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(testSuites); err != nil {
+		return fmt.Errorf("failed to write the JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write the JUnit report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package output renders the results of a scan run in formats consumed by other
+// tools: SARIF for code-scanning dashboards, JUnit XML for CI test reporting,
+// CSV for spreadsheets and a self-contained HTML summary. It also streams the
+// reports as newline-delimited JSON while the scan is running.
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Format is the format the results are rendered in.
+type Format string
+
+const (
+	FormatSARIF Format = "sarif"
+	FormatJUnit Format = "junit"
+	FormatCSV   Format = "csv"
+	FormatHTML  Format = "html"
+)
+
+// toolName is the name of the tool producing the results, as reported by the formats.
+const toolName = "kubewarden-audit-scanner"
+
+// SupportedFormats returns the formats the results can be rendered in.
+func SupportedFormats() []Format {
+	return []Format{FormatSARIF, FormatJUnit, FormatCSV, FormatHTML}
+}
+
+// ParseFormat parses the name of an output format.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatSARIF, FormatJUnit, FormatCSV, FormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q: supported values are %q", name, SupportedFormats())
+	}
+}
+
+// Document is the content rendered by the formats.
+type Document struct {
+	// Summary is the summary of the scan run
+	Summary summary.Data
+	// Resources are the results of the audited resources
+	Resources []ResourceResults
+}
+
+// Write renders the document in the given format.
+func Write(w io.Writer, format Format, document Document) error {
+	switch format {
+	case FormatSARIF:
+		return writeSARIF(w, document)
+	case FormatJUnit:
+		return writeJUnit(w, document)
+	case FormatCSV:
+		return writeCSV(w, document)
+	case FormatHTML:
+		return writeHTML(w, document)
+	default:
+		return fmt.Errorf("invalid output format %q", format)
+	}
+}
+
+// resourceID returns a human readable identifier of the resource:
+// namespace/Kind/name, or Kind/name for the cluster-wide resources.
+func resourceID(resource corev1.ObjectReference) string {
+	if resource.Namespace == "" {
+		return resource.Kind + "/" + resource.Name
+	}
+	return resource.Namespace + "/" + resource.Kind + "/" + resource.Name
+}
+
+// isFailed returns true if the result reports a policy violation or an
+// evaluation error.
+func isFailed(result report.Result) bool {
+	return result.Status == report.StatusFail || result.Status == report.StatusError
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newTestDocument() Document {
+	endTime := time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC)
+	return Document{
+		Summary: summary.Data{
+			RunUID:           "run-uid",
+			Scope:            summary.ScopeAll,
+			StartTime:        time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			EndTime:          &endTime,
+			Outcome:          summary.OutcomeCompleted,
+			ResourcesAudited: 2,
+			Results:          report.Summary{Pass: 1, Fail: 1, Error: 1},
+		},
+		Resources: []ResourceResults{
+			{
+				Resource: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod", UID: "pod-uid"},
+				Results: []report.Result{
+					{Policy: "policy-a", Status: report.StatusPass, Severity: report.SeverityLow, Category: "security"},
+					{Policy: "policy-b", Status: report.StatusFail, Severity: report.SeverityHigh, Category: "security", Message: "privileged <container>"},
+				},
+			},
+			{
+				Resource: corev1.ObjectReference{Kind: "Namespace", Name: "default", UID: "namespace-uid"},
+				Results: []report.Result{
+					{Policy: "policy-a", Status: report.StatusError, Message: "connection refused"},
+				},
+			},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range SupportedFormats() {
+		parsed, err := ParseFormat(string(format))
+		require.NoError(t, err)
+		assert.Equal(t, format, parsed)
+	}
+	_, err := ParseFormat("yaml")
+	require.Error(t, err)
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, FormatSARIF, newTestDocument()))
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &log))
+	assert.Equal(t, sarifVersion, log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, toolName, run.Tool.Driver.Name)
+	require.Len(t, run.Tool.Driver.Rules, 2)
+	assert.Equal(t, "policy-a", run.Tool.Driver.Rules[0].ID)
+	assert.Equal(t, "policy-b", run.Tool.Driver.Rules[1].ID)
+
+	// the passed results are omitted
+	require.Len(t, run.Results, 2)
+	assert.Equal(t, "policy-b", run.Results[0].RuleID)
+	assert.Equal(t, 1, run.Results[0].RuleIndex)
+	assert.Equal(t, sarifLevelError, run.Results[0].Level)
+	assert.Equal(t, "privileged <container>", run.Results[0].Message.Text)
+	assert.Equal(t, "default/Pod/pod", run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "default/Pod/pod", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "policy-a", run.Results[1].RuleID)
+	assert.Equal(t, 0, run.Results[1].RuleIndex)
+	assert.Equal(t, "Namespace/default", run.Results[1].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "Namespace/default", run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestSARIFLevel(t *testing.T) {
+	assert.Equal(t, sarifLevelError, sarifLevel(report.Result{Status: report.StatusFail, Severity: report.SeverityCritical}))
+	assert.Equal(t, sarifLevelWarning, sarifLevel(report.Result{Status: report.StatusFail, Severity: report.SeverityMedium}))
+	assert.Equal(t, sarifLevelNote, sarifLevel(report.Result{Status: report.StatusFail, Severity: report.SeverityInfo}))
+	assert.Equal(t, sarifLevelNote, sarifLevel(report.Result{Status: report.StatusFail}))
+	assert.Equal(t, sarifLevelError, sarifLevel(report.Result{Status: report.StatusError}))
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, FormatJUnit, newTestDocument()))
+
+	var testSuites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buffer.Bytes(), &testSuites))
+	assert.Equal(t, 3, testSuites.Tests)
+	assert.Equal(t, 1, testSuites.Failures)
+	assert.Equal(t, 1, testSuites.Errors)
+	require.Len(t, testSuites.Suites, 2)
+
+	suite := testSuites.Suites[0]
+	assert.Equal(t, "policy-a", suite.Name)
+	assert.Equal(t, 2, suite.Tests)
+	assert.Equal(t, 1, suite.Errors)
+	require.Len(t, suite.TestCases, 2)
+	assert.Equal(t, "default/Pod/pod", suite.TestCases[0].Name)
+	assert.Nil(t, suite.TestCases[0].Failure)
+	assert.Nil(t, suite.TestCases[0].Error)
+	require.NotNil(t, suite.TestCases[1].Error)
+	assert.Equal(t, "connection refused", suite.TestCases[1].Error.Message)
+
+	suite = testSuites.Suites[1]
+	assert.Equal(t, "policy-b", suite.Name)
+	assert.Equal(t, 1, suite.Failures)
+	require.NotNil(t, suite.TestCases[0].Failure)
+	assert.Equal(t, report.SeverityHigh, suite.TestCases[0].Failure.Type)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, FormatCSV, newTestDocument()))
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		csvHeader,
+		{"default", "Pod", "pod", "pod-uid", "policy-a", "pass", "low", "security", ""},
+		{"default", "Pod", "pod", "pod-uid", "policy-b", "fail", "high", "security", "privileged <container>"},
+		{"", "Namespace", "default", "namespace-uid", "policy-a", "error", "", "", "connection refused"},
+	}, records)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, FormatHTML, newTestDocument()))
+
+	page := buffer.String()
+	assert.Contains(t, page, "<title>Kubewarden audit scan run-uid</title>")
+	assert.Contains(t, page, "2024-01-01T10:05:00Z")
+	assert.Contains(t, page, `<td>default/Pod/pod</td><td>policy-b</td><td class="fail">fail</td>`)
+	// the messages are escaped
+	assert.Contains(t, page, "privileged &lt;container&gt;")
+	assert.NotContains(t, page, "<container>")
+	// the page doesn't load external resources
+	assert.NotContains(t, page, "<script")
+	assert.NotContains(t, page, "<link")
+}
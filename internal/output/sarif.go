@@ -0,0 +1,195 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifInformationURI is the documentation of the tool producing the results
+	sarifInformationURI = "https://docs.kubewarden.io/explanations/audit-scanner"
+)
+
+// Levels of the SARIF results.
+const (
+	sarifLevelError   = "error"
+	sarifLevelWarning = "warning"
+	sarifLevelNote    = "note"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool              sarifTool               `json:"tool"`
+	AutomationDetails *sarifAutomationDetails `json:"automationDetails,omitempty"`
+	Results           []sarifResult           `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	ShortDescription sarifMessage      `json:"shortDescription"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifAutomationDetails struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifLocation locates a result. The code-scanning dashboards require a
+// physical location, the resource is referenced by a synthetic URI.
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// writeSARIF renders the failed results as a SARIF log, with a rule for each
+// policy. The passed results are omitted, since code-scanning dashboards list
+// the problems found.
+func writeSARIF(w io.Writer, document Document) error {
+	ruleIndexes := make(map[string]int)
+	for _, resourceResults := range document.Resources {
+		for _, result := range resourceResults.Results {
+			if isFailed(result) {
+				ruleIndexes[result.Policy] = 0
+			}
+		}
+	}
+	ruleIDs := slices.Sorted(maps.Keys(ruleIndexes))
+
+	rules := make([]sarifRule, 0, len(ruleIDs))
+	results := []sarifResult{}
+	for i, ruleID := range ruleIDs {
+		ruleIndexes[ruleID] = i
+		rules = append(rules, sarifRule{
+			ID:               ruleID,
+			ShortDescription: sarifMessage{Text: "Kubewarden policy " + ruleID},
+		})
+	}
+	for _, resourceResults := range document.Resources {
+		id := resourceID(resourceResults.Resource)
+		for _, result := range resourceResults.Results {
+			if !isFailed(result) {
+				continue
+			}
+			ruleIndex := ruleIndexes[result.Policy]
+			if result.Category != "" {
+				rules[ruleIndex].Properties = map[string]string{"category": result.Category}
+			}
+			results = append(results, sarifResult{
+				RuleID:    result.Policy,
+				RuleIndex: ruleIndex,
+				Level:     sarifLevel(result),
+				Message:   sarifMessage{Text: resultMessage(id, result)},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: id},
+					},
+					LogicalLocations: []sarifLogicalLocation{{
+						Name:               resourceResults.Resource.Name,
+						FullyQualifiedName: id,
+						Kind:               "resource",
+					}},
+				}},
+				Properties: map[string]string{
+					"status":   result.Status,
+					"severity": result.Severity,
+				},
+			})
+		}
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           toolName,
+			InformationURI: sarifInformationURI,
+			Rules:          rules,
+		}},
+		Results: results,
+	}
+	if document.Summary.RunUID != "" {
+		run.AutomationDetails = &sarifAutomationDetails{ID: toolName + "/" + document.Summary.RunUID}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}}); err != nil {
+		return fmt.Errorf("failed to write the SARIF log: %w", err)
+	}
+	return nil
+}
+
+// sarifLevel maps a result to a SARIF level: the policy violations by their
+// severity, the evaluation errors as errors.
+func sarifLevel(result report.Result) string {
+	if result.Status == report.StatusError {
+		return sarifLevelError
+	}
+	switch result.Severity {
+	case report.SeverityCritical, report.SeverityHigh:
+		return sarifLevelError
+	case report.SeverityMedium:
+		return sarifLevelWarning
+	default:
+		return sarifLevelNote
+	}
+}
+
+// resultMessage returns the message of a failed result, describing the failure
+// when the policy didn't provide a message.
+func resultMessage(id string, result report.Result) string {
+	if result.Message != "" {
+		return result.Message
+	}
+	if result.Status == report.StatusError {
+		return fmt.Sprintf("policy %s could not evaluate %s", result.Policy, id)
+	}
+	return fmt.Sprintf("%s is rejected by policy %s", id, result.Policy)
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+)
+
+// Types of the records of the result stream.
+const (
+	RecordTypeReport  = "report"
+	RecordTypeChange  = "change"
+	RecordTypeSummary = "summary"
+)
+
+// Stream writes the reports of the audited resources as newline-delimited
+// JSON, one record per report and per change since the previous scan run,
+// followed by a summary record at the end of each scan run. The stream is
+// meant to be piped to other tools, so nothing else must be written to the
+// same writer.
+// It's safe for concurrent use. All the methods are no-op on a nil Stream, so
+// the callers don't need to check if the results are streamed.
+type Stream struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// Record is a line of the result stream. Only one of Report, Change and
+// Summary is set, according to the type of the record.
+type Record struct {
+	Type   string `json:"type"`
+	RunUID string `json:"runUID"`
+	// Report is the PolicyReport, ClusterPolicyReport, Report or ClusterReport
+	// of an audited resource
+	Report report.Report `json:"report,omitempty"`
+	// Change is a change of a result since the previous scan run
+	Change *summary.Change `json:"change,omitempty"`
+	// Summary is the summary of the scan run
+	Summary *summary.Data `json:"summary,omitempty"`
+}
+
+// NewStream creates a new Stream writing to w.
+func NewStream(w io.Writer) *Stream {
+	return &Stream{encoder: json.NewEncoder(w)}
+}
+
+// WriteReport writes a record with the given report of the scan run.
+func (s *Stream) WriteReport(runUID string, auditReport report.Report) error {
+	return s.write(Record{Type: RecordTypeReport, RunUID: runUID, Report: auditReport})
+}
+
+// WriteChange writes a record with the given change since the previous scan run.
+func (s *Stream) WriteChange(runUID string, change summary.Change) error {
+	return s.write(Record{Type: RecordTypeChange, RunUID: runUID, Change: &change})
+}
+
+// WriteSummary writes the record closing a scan run, with its summary.
+func (s *Stream) WriteSummary(data summary.Data) error {
+	return s.write(Record{Type: RecordTypeSummary, RunUID: data.RunUID, Summary: &data})
+}
+
+func (s *Stream) write(record Record) error {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write the %s record of run %s: %w", record.Type, record.RunUID, err)
+	}
+	return nil
+}
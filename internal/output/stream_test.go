@@ -0,0 +1,57 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	var buffer bytes.Buffer
+	stream := NewStream(&buffer)
+	require.NoError(t, stream.WriteReport("run-uid", newTestReport("pod", "default", "policy")))
+	require.NoError(t, stream.WriteReport("run-uid", newTestReport("pod", "kube-system", "policy")))
+	require.NoError(t, stream.WriteSummary(summary.Data{RunUID: "run-uid", Outcome: summary.OutcomeCompleted, ResourcesAudited: 2}))
+
+	type line struct {
+		Type    string         `json:"type"`
+		RunUID  string         `json:"runUID"`
+		Report  map[string]any `json:"report"`
+		Summary *summary.Data  `json:"summary"`
+	}
+	var lines []line
+	scanner := bufio.NewScanner(&buffer)
+	for scanner.Scan() {
+		var record line
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		lines = append(lines, record)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, RecordTypeReport, lines[0].Type)
+	assert.Equal(t, "run-uid", lines[0].RunUID)
+	assert.Nil(t, lines[0].Summary)
+	assert.Equal(t, "PolicyReport", lines[0].Report["kind"])
+	assert.Equal(t, "default", lines[0].Report["metadata"].(map[string]any)["namespace"])
+	assert.Equal(t, "kube-system", lines[1].Report["metadata"].(map[string]any)["namespace"])
+
+	assert.Equal(t, RecordTypeSummary, lines[2].Type)
+	assert.Nil(t, lines[2].Report)
+	require.NotNil(t, lines[2].Summary)
+	assert.Equal(t, summary.OutcomeCompleted, lines[2].Summary.Outcome)
+	assert.Equal(t, 2, lines[2].Summary.ResourcesAudited)
+}
+
+func TestNilStream(t *testing.T) {
+	var stream *Stream
+
+	// all the methods are no-op on a nil stream
+	require.NoError(t, stream.WriteReport("run-uid", newTestReport("pod", "default", "policy")))
+	require.NoError(t, stream.WriteSummary(summary.Data{RunUID: "run-uid"}))
+}
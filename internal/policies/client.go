@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/url"
 	"slices"
+	"sync"
 
 	"github.com/kubewarden/audit-scanner/internal/constants"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
@@ -16,6 +18,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -29,6 +32,17 @@ type Client struct {
 	// FQDN of the policy server to query. If not empty, it will query on port 3000.
 	// Useful for out-of-cluster debugging
 	policyServerURL string
+	// discovery is used to expand the wildcard rules of the policies. When nil,
+	// the wildcard rules are not audited
+	discovery discovery.DiscoveryInterface
+	// resourceFilter bounds the resources the wildcard rules are expanded to
+	resourceFilter ResourceFilter
+	// policyFilter selects the audited policies
+	policyFilter PolicyFilter
+	// unhealthyPolicyServers are the reasons why the PolicyServers found
+	// unhealthy by the health check are, by name
+	unhealthyPolicyServers map[string]string
+	unhealthyMutex         sync.RWMutex
 	// logger is used to log the messages
 	logger *slog.Logger
 }
@@ -43,16 +57,41 @@ type Policies struct {
 	SkippedNum int
 	// ErroredNum represents the number of errored policies. These policies may be misconfigured
 	ErroredNum int
+	// ErroredPolicies contains the unique names of the errored policies, sorted
+	ErroredPolicies []string
+	// SkippedPolicies contains the unique names of the skipped policies, sorted
+	SkippedPolicies []string
+	// SelectedPolicies contains the unique names of the policies selected by
+	// the policy filter, sorted, including the ones that are not auditable.
+	// It's nil when all the policies are selected
+	SelectedPolicies []string
+}
+
+// Selects returns true if the policy with the given unique name is selected
+// by the policy filter.
+func (p *Policies) Selects(policy string) bool {
+	if p.SelectedPolicies == nil {
+		return true
+	}
+	_, found := slices.BinarySearch(p.SelectedPolicies, policy)
+	return found
 }
 
 // Policy represents a policy and the URL of the policy server where it is running.
 type Policy struct {
 	policiesv1.Policy
 	PolicyServer *url.URL
+	// Operation is the operation of the admission requests simulated to audit
+	// the resources: CREATE, or UPDATE when the policy targets the resources
+	// only on UPDATE.
+	Operation admissionregistrationv1.OperationType
 }
 
 // NewClient returns a policy Client.
-func NewClient(client client.Client, kubewardenNamespace string, policyServerURL string, logger *slog.Logger) *Client {
+// The discovery client is used to expand the wildcard rules of the policies into
+// the resources allowed by the resourceFilter. It can be nil to skip the wildcard rules.
+// Only the policies selected by the policyFilter are audited.
+func NewClient(client client.Client, discovery discovery.DiscoveryInterface, resourceFilter ResourceFilter, policyFilter PolicyFilter, kubewardenNamespace string, policyServerURL string, logger *slog.Logger) *Client {
 	if policyServerURL != "" {
 		logger.Info(fmt.Sprintf("querying PolicyServers at %s for debugging purposes. Don't forget to start `kubectl port-forward` if needed", policyServerURL))
 	}
@@ -61,36 +100,51 @@ func NewClient(client client.Client, kubewardenNamespace string, policyServerURL
 		client:              client,
 		kubewardenNamespace: kubewardenNamespace,
 		policyServerURL:     policyServerURL,
+		discovery:           discovery,
+		resourceFilter:      resourceFilter,
+		policyFilter:        policyFilter,
 		logger:              logger.With("client", "policyclient"),
 	}
 }
 
+// AuditsPolicySubset returns true if only a subset of the policies is audited.
+func (f *Client) AuditsPolicySubset() bool {
+	return !f.policyFilter.IsEmpty()
+}
+
 // GetPoliciesByNamespace gets all the auditable policies for a given namespace.
 func (f *Client) GetPoliciesByNamespace(ctx context.Context, namespace *corev1.Namespace) (*Policies, error) {
-	var policies []policiesv1.Policy
+	var clusterPolicies []policiesv1.Policy
 
-	clusterAdmissionPolicies, err := f.findClusterAdmissionPoliciesByNamespace(ctx, namespace)
+	clusterAdmissionPolicies, err := f.listClusterAdmissionPolicies(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve ClusterAdmissionPolicies for namespace %q: %w", namespace, err)
 	}
 	for _, policy := range clusterAdmissionPolicies {
-		policies = append(policies, &policy)
+		clusterPolicies = append(clusterPolicies, &policy)
 	}
 
-	clusterAdmissionPolicyGroups, err := f.findClusterAdmissionPolicyGroupsByNamespace(ctx, namespace)
+	clusterAdmissionPolicyGroups, err := f.listClusterAdmissionPolicyGroups(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve ClusterAdmissionPolicyGroups for namespace %q: %w", namespace, err)
 	}
 	for _, policy := range clusterAdmissionPolicyGroups {
-		policies = append(policies, &policy)
+		clusterPolicies = append(clusterPolicies, &policy)
 	}
 
+	policies, err := findPoliciesByNamespace(clusterPolicies, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve cluster-wide policies for namespace %q: %w", namespace, err)
+	}
+
+	var namespacedPolicies []policiesv1.Policy
+
 	admissionPolicies, err := f.listAdmissionPolicies(ctx, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve AdmissionPolicies for namespace %q: %w", namespace, err)
 	}
 	for _, policy := range admissionPolicies {
-		policies = append(policies, &policy)
+		namespacedPolicies = append(namespacedPolicies, &policy)
 	}
 
 	admissionPolicyGroups, err := f.listAdmissionPolicyGroups(ctx, namespace)
@@ -98,10 +152,18 @@ func (f *Client) GetPoliciesByNamespace(ctx context.Context, namespace *corev1.N
 		return nil, fmt.Errorf("failed to retrieve AdmissionPolicyGroups for namespace %q: %w", namespace, err)
 	}
 	for _, policy := range admissionPolicyGroups {
-		policies = append(policies, &policy)
+		namespacedPolicies = append(namespacedPolicies, &policy)
 	}
 
-	return f.groupPoliciesByGVR(ctx, policies, true)
+	auditablePolicies, err := f.groupPoliciesByGVR(ctx, append(policies, namespacedPolicies...), true)
+	if err != nil {
+		return nil, err
+	}
+	// the cluster-wide policies that don't evaluate the resources of the
+	// namespace are selected too, so that their previous results are dropped
+	auditablePolicies.SelectedPolicies = f.selectedPolicies(append(clusterPolicies, namespacedPolicies...))
+
+	return auditablePolicies, nil
 }
 
 // GetClusterWidePolicies returns all the auditable cluster-wide policies.
@@ -124,20 +186,21 @@ func (f *Client) GetClusterWidePolicies(ctx context.Context) (*Policies, error)
 		policies = append(policies, &policy)
 	}
 
-	return f.groupPoliciesByGVR(ctx, policies, false)
-}
-
-// findClusterAdmissionPoliciesByNamespace returns all the ClusterAdmissionPolicies that evaluate resources in the given namespace.
-func (f *Client) findClusterAdmissionPoliciesByNamespace(ctx context.Context, namespace *corev1.Namespace) ([]policiesv1.ClusterAdmissionPolicy, error) {
-	clusterAdmissionPolicies, err := f.listClusterAdmissionPolicies(ctx)
+	auditablePolicies, err := f.groupPoliciesByGVR(ctx, policies, false)
 	if err != nil {
 		return nil, err
 	}
+	auditablePolicies.SelectedPolicies = f.selectedPolicies(policies)
+
+	return auditablePolicies, nil
+}
 
-	var result []policiesv1.ClusterAdmissionPolicy
+// findPoliciesByNamespace returns the cluster-wide policies that evaluate resources in the given namespace.
+func findPoliciesByNamespace(clusterPolicies []policiesv1.Policy, namespace *corev1.Namespace) ([]policiesv1.Policy, error) {
+	var result []policiesv1.Policy
 
-	for _, policy := range clusterAdmissionPolicies {
-		matches, err := policyMatchesNamespace(&policy, namespace)
+	for _, policy := range clusterPolicies {
+		matches, err := policyMatchesNamespace(policy, namespace)
 		if err != nil {
 			return nil, err
 		}
@@ -150,26 +213,22 @@ func (f *Client) findClusterAdmissionPoliciesByNamespace(ctx context.Context, na
 	return result, nil
 }
 
-// findClusterAdmissionPolicyGroupsByNamespace returns all the ClusterAdmissionPolicyGroups that evaluate resources in the given namespace.
-func (f *Client) findClusterAdmissionPolicyGroupsByNamespace(ctx context.Context, namespace *corev1.Namespace) ([]policiesv1.ClusterAdmissionPolicyGroup, error) {
-	clusterAdmissionPolicyGroups, err := f.listClusterAdmissionPolicyGroups(ctx)
-	if err != nil {
-		return nil, err
+// selectedPolicies returns the sorted unique names of the given policies that
+// are selected by the policy filter, or nil when all the policies are selected.
+func (f *Client) selectedPolicies(policies []policiesv1.Policy) []string {
+	if f.policyFilter.IsEmpty() {
+		return nil
 	}
 
-	var result []policiesv1.ClusterAdmissionPolicyGroup
-	for _, policy := range clusterAdmissionPolicyGroups {
-		matches, err := policyMatchesNamespace(&policy, namespace)
-		if err != nil {
-			return nil, err
-		}
-
-		if matches {
-			result = append(result, policy)
+	selected := []string{}
+	for _, policy := range policies {
+		if f.policyFilter.matches(policy) {
+			selected = append(selected, policy.GetUniqueName())
 		}
 	}
+	slices.Sort(selected)
 
-	return result, nil
+	return slices.Compact(selected)
 }
 
 // listClusterAdmissionPolicies returns all the ClusterAdmissionPolicies in the cluster.
@@ -236,7 +295,7 @@ func policyMatchesNamespace(policy policiesv1.Policy, namespace *corev1.Namespac
 
 // groupPoliciesByGVR groups policies by GVR.
 // If namespaced is true, it will skip cluster-wide resources, otherwise it will skip namespaced resources.
-// If the policy targets an unknown GVR or the policy server URL cannot be constructed, the policy will be counted as errored.
+// If the policy targets an unknown GVR, the policy server URL cannot be constructed or the policy server is unhealthy, the policy will be counted as errored.
 func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.Policy, namespaced bool) (*Policies, error) {
 	policiesByGVR := make(map[schema.GroupVersionResource][]*Policy)
 	auditablePolicies := map[string]struct{}{}
@@ -244,18 +303,31 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 	erroredPolicies := map[string]struct{}{}
 
 	for _, policy := range policies {
-		rules := filterWildcardRules(policy.GetRules())
+		if !f.policyFilter.matches(policy) {
+			f.logger.DebugContext(ctx, "the policy is not selected for the audit, ignoring...", slog.String("policy", policy.GetUniqueName()))
+
+			continue
+		}
+
+		rules, err := f.expandWildcardRules(ctx, policy.GetRules())
+		if err != nil {
+			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+			f.logger.ErrorContext(ctx, "failed to expand the wildcard rules, skipping as error...",
+				slog.String("error", err.Error()),
+				slog.String("policy", policy.GetUniqueName()))
+			continue
+		}
 		if len(rules) == 0 {
 			skippedPolicies[policy.GetUniqueName()] = struct{}{}
-			f.logger.DebugContext(ctx, "the policy targets only wildcard resources, skipping...", slog.String("policy", policy.GetUniqueName()))
+			f.logger.DebugContext(ctx, "the policy targets only wildcard resources that cannot be expanded, skipping...", slog.String("policy", policy.GetUniqueName()))
 
 			continue
 		}
 
-		rules = filterNonCreateOperations(rules)
+		rules = filterNonAuditableOperations(rules)
 		if len(rules) == 0 {
 			skippedPolicies[policy.GetUniqueName()] = struct{}{}
-			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
+			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE or UPDATE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
 
 			continue
 		}
@@ -292,6 +364,11 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 			continue
 		}
 
+		if f.runsOnUnhealthyPolicyServer(ctx, policy) {
+			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+			continue
+		}
+
 		url, err := f.getPolicyServerURLRunningPolicy(ctx, policy)
 		if err != nil {
 			erroredPolicies[policy.GetUniqueName()] = struct{}{}
@@ -306,13 +383,12 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		setTypeMeta(policy)
 
 		auditablePolicies[policy.GetUniqueName()] = struct{}{}
-		policy := &Policy{
-			Policy:       policy,
-			PolicyServer: url,
-		}
-
 		for _, gvr := range groupVersionResources {
-			addPolicyToMap(policiesByGVR, gvr, policy)
+			addPolicyToMap(policiesByGVR, gvr, &Policy{
+				Policy:       policy,
+				PolicyServer: url,
+				Operation:    getAuditOperation(rules, gvr),
+			})
 		}
 	}
 
@@ -321,6 +397,9 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		PolicyNum:     len(auditablePolicies),
 		SkippedNum:    len(skippedPolicies),
 		ErroredNum:    len(erroredPolicies),
+		// sorted to have a deterministic output
+		ErroredPolicies: slices.Sorted(maps.Keys(erroredPolicies)),
+		SkippedPolicies: slices.Sorted(maps.Keys(skippedPolicies)),
 	}, nil
 }
 
@@ -349,6 +428,28 @@ func getRuleGVRs(rule admissionregistrationv1.RuleWithOperations) []schema.Group
 	return gvrs
 }
 
+// GetTargetedGroupVersionResources returns the GroupVersionResources audited by the given policy.
+// Like when grouping the policies by GVR, the wildcard rules are expanded and
+// the rules not including an auditable operation are ignored. The policies not
+// selected by the policy filter target no resources.
+func (f *Client) GetTargetedGroupVersionResources(ctx context.Context, policy policiesv1.Policy) ([]schema.GroupVersionResource, error) {
+	if !f.policyFilter.matches(policy) {
+		return nil, nil
+	}
+
+	rules, err := f.expandWildcardRules(ctx, policy.GetRules())
+	if err != nil {
+		return nil, err
+	}
+
+	var groupVersionResources []schema.GroupVersionResource
+	for _, rule := range filterNonAuditableOperations(rules) {
+		groupVersionResources = append(groupVersionResources, getRuleGVRs(rule)...)
+	}
+
+	return groupVersionResources, nil
+}
+
 // getGroupVersionResources returns a list of GroupVersionResource from a list of policies.
 // if namespaced is true, it will skip cluster-wide resources, otherwise it will skip namespaced resources.
 func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWithOperations, namespaced bool) ([]schema.GroupVersionResource, error) {
@@ -357,7 +458,7 @@ func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWi
 	for _, rule := range rules {
 		gvrs := getRuleGVRs(rule)
 		for _, gvr := range gvrs {
-			isNamespaced, err := f.isNamespacedResource(gvr)
+			isNamespaced, err := f.IsNamespacedResource(gvr)
 			if err != nil {
 				return nil, err
 			}
@@ -377,8 +478,30 @@ func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWi
 	return groupVersionResources, nil
 }
 
-// isNamespacedResource checks if the given resource is namespaced or not.
-func (f *Client) isNamespacedResource(gvr schema.GroupVersionResource) (bool, error) {
+// GetGroupVersionResource returns the resource of the given kind, as known by
+// the RESTMapper.
+func (f *Client) GetGroupVersionResource(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	mapping, err := f.client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("failed to get REST mapping for GVK %s: %w", gvk.String(), err)
+	}
+
+	return mapping.Resource, nil
+}
+
+// GetGroupVersionKind returns the kind of the given resource, as known by the
+// RESTMapper.
+func (f *Client) GetGroupVersionKind(gvr schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	gvk, err := f.client.RESTMapper().KindFor(gvr)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("failed to get GVK for GVR %s: %w", gvr.String(), err)
+	}
+
+	return gvk, nil
+}
+
+// IsNamespacedResource checks if the given resource is namespaced or not.
+func (f *Client) IsNamespacedResource(gvr schema.GroupVersionResource) (bool, error) {
 	gvk, err := f.client.RESTMapper().KindFor(gvr)
 	if err != nil {
 		return false, fmt.Errorf("failed to get GVK for GVR %s: %w", gvr.String(), err)
@@ -446,31 +569,37 @@ func (f *Client) getServiceByInstanceLabel(ctx context.Context, instanceName str
 	return &serviceList.Items[0], nil
 }
 
-// filterWildcardRules filters out rules that contain a wildcard in the APIGroups, APIVersions or Resources fields.
-func filterWildcardRules(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
+// filterNonAuditableOperations filters out rules that do not contain a CREATE,
+// UPDATE or wildcard operation. Resources cannot be audited simulating the
+// other operations.
+func filterNonAuditableOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
 	filteredRules := []admissionregistrationv1.RuleWithOperations{}
 	for _, rule := range rules {
-		if slices.Contains(rule.APIGroups, "*") ||
-			slices.Contains(rule.APIVersions, "*") ||
-			slices.Contains(rule.Resources, "*") {
-			continue
+		if slices.Contains(rule.Operations, admissionregistrationv1.Create) ||
+			slices.Contains(rule.Operations, admissionregistrationv1.Update) ||
+			slices.Contains(rule.Operations, admissionregistrationv1.OperationAll) {
+			filteredRules = append(filteredRules, rule)
 		}
-		filteredRules = append(filteredRules, rule)
 	}
 
 	return filteredRules
 }
 
-// filterNonCreateOperations filters out rules that do not contain a CREATE operation.
-func filterNonCreateOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
-	filteredRules := []admissionregistrationv1.RuleWithOperations{}
+// getAuditOperation returns the operation simulated to audit the given
+// resource. It's CREATE if any of the rules targeting the resource matches
+// CREATE, either literally or with a wildcard, otherwise it's UPDATE.
+func getAuditOperation(rules []admissionregistrationv1.RuleWithOperations, gvr schema.GroupVersionResource) admissionregistrationv1.OperationType {
 	for _, rule := range rules {
-		if slices.Contains(rule.Operations, admissionregistrationv1.Create) {
-			filteredRules = append(filteredRules, rule)
+		if !slices.Contains(getRuleGVRs(rule), gvr) {
+			continue
+		}
+		if slices.Contains(rule.Operations, admissionregistrationv1.Create) ||
+			slices.Contains(rule.Operations, admissionregistrationv1.OperationAll) {
+			return admissionregistrationv1.Create
 		}
 	}
 
-	return filteredRules
+	return admissionregistrationv1.Update
 }
 
 // setTypeMeta sets TypeMeta.Kind and APIVersion fields.
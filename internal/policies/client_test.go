@@ -258,7 +258,7 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, nil, ResourceFilter{}, PolicyFilter{}, "kubewarden", "", logger)
 
 	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
 	require.NoError(t, err)
@@ -273,14 +273,17 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       admissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       admissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-group-test-admissionPolicyGroup1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 			},
 			{
@@ -291,20 +294,25 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       admissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 			},
 		},
-		PolicyNum:  4,
-		SkippedNum: 3,
-		ErroredNum: 1,
+		PolicyNum:       4,
+		SkippedNum:      3,
+		ErroredNum:      1,
+		ErroredPolicies: []string{"namespaced-test-admissionPolicy5"},
+		SkippedPolicies: []string{"clusterwide-clusterAdmissionPolicy3", "namespaced-test-admissionPolicy2", "namespaced-test-admissionPolicy4"},
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
@@ -399,7 +407,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		}).
 		Build()
 
-	// a ClusterAdmissionPolicy with no CREATE operation, it should be skipped
+	// a ClusterAdmissionPolicy with UPDATE and DELETE operations, it should be audited simulating UPDATE requests
 	clusterAdmissionPolicy6 := testutils.
 		NewClusterAdmissionPolicyFactory().
 		Name("clusterAdmissionPolicy6").
@@ -421,6 +429,28 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		}).
 		Build()
 
+	// a ClusterAdmissionPolicy with only DELETE and CONNECT operations, it should be skipped
+	clusterAdmissionPolicy8 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy8").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}, admissionregistrationv1.Delete, admissionregistrationv1.Connect).
+		Build()
+
+	// a ClusterAdmissionPolicy with a wildcard operation, it should be audited simulating CREATE requests
+	clusterAdmissionPolicy9 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy9").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}, admissionregistrationv1.OperationAll).
+		Build()
+
 	// a CLusterAdmissionPolicyGroup
 	clusterAdmissionPolicyGroup1 := testutils.
 		NewClusterAdmissionPolicyGroupFactory().
@@ -461,6 +491,8 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		clusterAdmissionPolicy5,
 		clusterAdmissionPolicy6,
 		clusterAdmissionPolicy7,
+		clusterAdmissionPolicy8,
+		clusterAdmissionPolicy9,
 		clusterAdmissionPolicyGroup1,
 		clusterAdmissionPolicyGroup2,
 		admissionPolicy1,
@@ -468,7 +500,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, nil, ResourceFilter{}, PolicyFilter{}, "kubewarden", "", logger)
 
 	policies, err := policiesClient.GetClusterWidePolicies(t.Context())
 	require.NoError(t, err)
@@ -483,25 +515,74 @@ func TestGetClusterWidePolicies(t *testing.T) {
 				{
 					Policy:       clusterAdmissionPolicy1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicy2,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy2"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicy3,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy3"},
+					Operation:    admissionregistrationv1.Create,
+				},
+				{
+					Policy:       clusterAdmissionPolicy6,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy6"},
+					Operation:    admissionregistrationv1.Update,
+				},
+				{
+					Policy:       clusterAdmissionPolicy9,
+					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy9"},
+					Operation:    admissionregistrationv1.Create,
 				},
 				{
 					Policy:       clusterAdmissionPolicyGroup1,
 					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Operation:    admissionregistrationv1.Create,
 				},
 			},
 		},
-		PolicyNum:  4,
-		SkippedNum: 2,
-		ErroredNum: 1,
+		PolicyNum:       6,
+		SkippedNum:      2,
+		ErroredNum:      1,
+		ErroredPolicies: []string{"clusterwide-policy8"},
+		SkippedPolicies: []string{"clusterwide-clusterAdmissionPolicy4", "clusterwide-clusterAdmissionPolicy8"},
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
 }
+
+func TestGetAuditOperation(t *testing.T) {
+	rules := []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods", "services"},
+			},
+		},
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+		},
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"deployments"},
+			},
+		},
+	}
+
+	assert.Equal(t, admissionregistrationv1.Create, getAuditOperation(rules, schema.GroupVersionResource{Version: "v1", Resource: "pods"}))
+	assert.Equal(t, admissionregistrationv1.Update, getAuditOperation(rules, schema.GroupVersionResource{Version: "v1", Resource: "services"}))
+	assert.Equal(t, admissionregistrationv1.Create, getAuditOperation(rules, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}))
+}
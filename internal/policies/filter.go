@@ -0,0 +1,53 @@
+package policies
+
+import (
+	"fmt"
+	"slices"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PolicyFilter selects the policies to audit, so that a subset of the policies
+// deployed in the cluster can be audited.
+type PolicyFilter struct {
+	// Names lists the policies to audit: the name of the cluster-wide
+	// policies, and namespace/name of the namespaced ones
+	Names []string
+	// Selector is the label selector the audited policies must match
+	Selector labels.Selector
+}
+
+// NewPolicyFilter returns the filter selecting the given policies and the ones
+// matching the label selector.
+func NewPolicyFilter(names []string, selector string) (PolicyFilter, error) {
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		return PolicyFilter{}, fmt.Errorf("invalid policy selector %q: %w", selector, err)
+	}
+
+	return PolicyFilter{
+		Names:    names,
+		Selector: labelSelector,
+	}, nil
+}
+
+// IsEmpty returns true if the filter selects all the policies.
+func (p PolicyFilter) IsEmpty() bool {
+	return len(p.Names) == 0 && (p.Selector == nil || p.Selector.Empty())
+}
+
+// matches returns true if the policy is selected by the filter.
+func (p PolicyFilter) matches(policy policiesv1.Policy) bool {
+	if len(p.Names) > 0 {
+		name := policy.GetName()
+		if policy.GetNamespace() != "" {
+			name = policy.GetNamespace() + "/" + name
+		}
+		if !slices.Contains(p.Names, name) {
+			return false
+		}
+	}
+
+	return p.Selector == nil || p.Selector.Matches(labels.Set(policy.GetLabels()))
+}
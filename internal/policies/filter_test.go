@@ -0,0 +1,187 @@
+package policies
+
+import (
+	"log/slog"
+	"maps"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyFilter(t *testing.T) {
+	clusterAdmissionPolicy := testutils.NewClusterAdmissionPolicyFactory().Name("cluster-policy").Build()
+	clusterAdmissionPolicy.SetLabels(map[string]string{"team": "payments"})
+	admissionPolicy := testutils.NewAdmissionPolicyFactory().Name("policy").Namespace("test").Build()
+
+	tests := []struct {
+		name            string
+		names           []string
+		selector        string
+		expectedMatches []bool
+	}{
+		{
+			name:            "no filter",
+			expectedMatches: []bool{true, true},
+		},
+		{
+			name:            "cluster-wide policy name",
+			names:           []string{"cluster-policy"},
+			expectedMatches: []bool{true, false},
+		},
+		{
+			name:            "namespaced policy name",
+			names:           []string{"test/policy"},
+			expectedMatches: []bool{false, true},
+		},
+		{
+			name:            "namespaced policy name without namespace",
+			names:           []string{"policy"},
+			expectedMatches: []bool{false, false},
+		},
+		{
+			name:            "label selector",
+			selector:        "team=payments",
+			expectedMatches: []bool{true, false},
+		},
+		{
+			name:            "names and label selector",
+			names:           []string{"test/policy"},
+			selector:        "team=payments",
+			expectedMatches: []bool{false, false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policyFilter, err := NewPolicyFilter(test.names, test.selector)
+			require.NoError(t, err)
+
+			assert.Equal(t, len(test.names) == 0 && test.selector == "", policyFilter.IsEmpty())
+			assert.Equal(t, test.expectedMatches, []bool{
+				policyFilter.matches(clusterAdmissionPolicy),
+				policyFilter.matches(admissionPolicy),
+			})
+		})
+	}
+}
+
+func TestNewPolicyFilterWithInvalidSelector(t *testing.T) {
+	_, err := NewPolicyFilter(nil, "team in (payments")
+	require.Error(t, err)
+}
+
+func TestGetPoliciesByNamespaceWithPolicyFilter(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	podsRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods"},
+	}
+
+	// a ClusterAdmissionPolicy selected by name
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(podsRule).
+		Build()
+
+	// a ClusterAdmissionPolicy not selected, it should be neither audited nor skipped
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(podsRule).
+		Build()
+
+	// a ClusterAdmissionPolicy selected by name, which doesn't evaluate the
+	// resources of the namespace
+	clusterAdmissionPolicy3 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy3").
+		NamespaceSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"env": "prod"},
+		}).
+		Rule(podsRule).
+		Build()
+
+	// an AdmissionPolicy selected by namespace/name
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy").
+		Namespace("test").
+		Rule(podsRule).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+		clusterAdmissionPolicy3,
+		admissionPolicy,
+	)
+	require.NoError(t, err)
+
+	policyFilter, err := NewPolicyFilter([]string{"clusterAdmissionPolicy1", "clusterAdmissionPolicy3", "test/admissionPolicy"}, "")
+	require.NoError(t, err)
+	policiesClient := NewClient(client, nil, ResourceFilter{}, policyFilter, "kubewarden", "", slog.Default())
+	assert.True(t, policiesClient.AuditsPolicySubset())
+
+	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
+	require.NoError(t, err)
+
+	var auditedPolicies []string
+	for pols := range maps.Values(policies.PoliciesByGVR) {
+		for _, policy := range pols {
+			auditedPolicies = append(auditedPolicies, policy.GetUniqueName())
+		}
+	}
+	assert.ElementsMatch(t, []string{clusterAdmissionPolicy1.GetUniqueName(), admissionPolicy.GetUniqueName()}, auditedPolicies)
+	assert.Equal(t, 2, policies.PolicyNum)
+	assert.Equal(t, 0, policies.SkippedNum)
+	assert.Equal(t, 0, policies.ErroredNum)
+	// the previous results of the selected policies are dropped, even when
+	// they don't evaluate the resources of the namespace
+	assert.True(t, policies.Selects(clusterAdmissionPolicy3.GetUniqueName()))
+	assert.True(t, policies.Selects(admissionPolicy.GetUniqueName()))
+	assert.False(t, policies.Selects(clusterAdmissionPolicy2.GetUniqueName()))
+
+	gvrs, err := policiesClient.GetTargetedGroupVersionResources(t.Context(), clusterAdmissionPolicy2)
+	require.NoError(t, err)
+	assert.Empty(t, gvrs)
+}
@@ -0,0 +1,170 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PolicyServerHealth is the outcome of the health check of a PolicyServer
+// running audited policies.
+type PolicyServerHealth struct {
+	Name string
+	// Reason explains why the PolicyServer is unhealthy, it's empty when it's healthy
+	Reason string
+	// Policies are the unique names of the audited policies running on the PolicyServer, sorted
+	Policies []string
+	// AuditURL is the audit endpoint of one of the policies, used to probe the
+	// PolicyServer. It's nil when the PolicyServer is unhealthy
+	AuditURL *url.URL
+}
+
+// Healthy returns true if the PolicyServer passed the health check.
+func (h PolicyServerHealth) Healthy() bool {
+	return h.Reason == ""
+}
+
+// CheckPolicyServers checks the health of the PolicyServers running the
+// audited policies: the PolicyServer must have reconciled its Deployment, and
+// the Deployment must have ready replicas. The PolicyServers are sorted by name.
+func (f *Client) CheckPolicyServers(ctx context.Context) ([]PolicyServerHealth, error) {
+	policiesByServer, err := f.getAuditedPoliciesByPolicyServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	healths := make([]PolicyServerHealth, 0, len(policiesByServer))
+	for _, name := range slices.Sorted(maps.Keys(policiesByServer)) {
+		serverPolicies := policiesByServer[name]
+		health := PolicyServerHealth{Name: name}
+		for _, policy := range serverPolicies {
+			health.Policies = append(health.Policies, policy.GetUniqueName())
+		}
+		slices.Sort(health.Policies)
+
+		health.Reason, err = f.checkPolicyServer(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if health.Healthy() {
+			health.AuditURL, err = f.getPolicyServerURLRunningPolicy(ctx, serverPolicies[0])
+			if err != nil {
+				health.Reason = fmt.Sprintf("cannot find the audit endpoint: %s", err)
+			}
+		}
+		healths = append(healths, health)
+	}
+	return healths, nil
+}
+
+// checkPolicyServer returns the reason why the given PolicyServer is
+// unhealthy, or an empty string if it's healthy. It returns an error only
+// when the health cannot be checked.
+func (f *Client) checkPolicyServer(ctx context.Context, name string) (string, error) {
+	var policyServer policiesv1.PolicyServer
+	if err := f.client.Get(ctx, client.ObjectKey{Name: name}, &policyServer); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return "the PolicyServer does not exist", nil
+		}
+		return "", fmt.Errorf("failed to get PolicyServer %q: %w", name, err)
+	}
+	condition := meta.FindStatusCondition(policyServer.Status.Conditions, string(policiesv1.PolicyServerDeploymentReconciled))
+	if condition != nil && condition.Status == metav1.ConditionFalse {
+		return fmt.Sprintf("the Deployment of the PolicyServer is not reconciled: %s", condition.Message), nil
+	}
+
+	var deployment appsv1.Deployment
+	if err := f.client.Get(ctx, client.ObjectKey{Name: policyServer.NameWithPrefix(), Namespace: f.kubewardenNamespace}, &deployment); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return fmt.Sprintf("the Deployment %q does not exist", policyServer.NameWithPrefix()), nil
+		}
+		return "", fmt.Errorf("failed to get the Deployment of PolicyServer %q: %w", name, err)
+	}
+	if deployment.Status.ReadyReplicas == 0 {
+		return fmt.Sprintf("the Deployment %q has no ready replicas", deployment.Name), nil
+	}
+	return "", nil
+}
+
+// getAuditedPoliciesByPolicyServer returns the policies that can be audited,
+// in all the namespaces, grouped by the name of their PolicyServer.
+func (f *Client) getAuditedPoliciesByPolicyServer(ctx context.Context) (map[string][]policiesv1.Policy, error) {
+	var policies []policiesv1.Policy
+
+	clusterAdmissionPolicies, err := f.listClusterAdmissionPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve ClusterAdmissionPolicies: %w", err)
+	}
+	for _, policy := range clusterAdmissionPolicies {
+		policies = append(policies, &policy)
+	}
+	clusterAdmissionPolicyGroups, err := f.listClusterAdmissionPolicyGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve ClusterAdmissionPolicyGroups: %w", err)
+	}
+	for _, policy := range clusterAdmissionPolicyGroups {
+		policies = append(policies, &policy)
+	}
+	// the policies of all the namespaces are listed with an empty namespace
+	allNamespaces := &corev1.Namespace{}
+	admissionPolicies, err := f.listAdmissionPolicies(ctx, allNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AdmissionPolicies: %w", err)
+	}
+	for _, policy := range admissionPolicies {
+		policies = append(policies, &policy)
+	}
+	admissionPolicyGroups, err := f.listAdmissionPolicyGroups(ctx, allNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AdmissionPolicyGroups: %w", err)
+	}
+	for _, policy := range admissionPolicyGroups {
+		policies = append(policies, &policy)
+	}
+
+	policiesByServer := make(map[string][]policiesv1.Policy)
+	for _, policy := range policies {
+		if !f.policyFilter.matches(policy) || !policy.GetBackgroundAudit() || policy.GetStatus().PolicyStatus != policiesv1.PolicyStatusActive {
+			continue
+		}
+		policiesByServer[policy.GetPolicyServer()] = append(policiesByServer[policy.GetPolicyServer()], policy)
+	}
+	return policiesByServer, nil
+}
+
+// SetUnhealthyPolicyServers sets the PolicyServers found unhealthy, by name,
+// with the reason. Their policies are counted as errored instead of being
+// audited, until the PolicyServers are set again.
+func (f *Client) SetUnhealthyPolicyServers(reasons map[string]string) {
+	f.unhealthyMutex.Lock()
+	defer f.unhealthyMutex.Unlock()
+
+	f.unhealthyPolicyServers = maps.Clone(reasons)
+}
+
+// runsOnUnhealthyPolicyServer returns true if the PolicyServer of the given
+// policy has been found unhealthy.
+func (f *Client) runsOnUnhealthyPolicyServer(ctx context.Context, policy policiesv1.Policy) bool {
+	f.unhealthyMutex.RLock()
+	defer f.unhealthyMutex.RUnlock()
+
+	reason, unhealthy := f.unhealthyPolicyServers[policy.GetPolicyServer()]
+	if unhealthy {
+		// the reason is logged once by the health check, not for every namespace
+		f.logger.DebugContext(ctx, "the PolicyServer of the policy is unhealthy, skipping as error...",
+			slog.String("policy", policy.GetUniqueName()),
+			slog.String("policy-server", policy.GetPolicyServer()),
+			slog.String("reason", reason))
+	}
+	return unhealthy
+}
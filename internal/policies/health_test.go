@@ -0,0 +1,102 @@
+package policies
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckPolicyServers(t *testing.T) {
+	newPolicyServer := func(name string, conditions ...metav1.Condition) *policiesv1.PolicyServer {
+		return &policiesv1.PolicyServer{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     policiesv1.PolicyServerStatus{Conditions: conditions},
+		}
+	}
+	newDeployment := func(name string, readyReplicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy-server-" + name, Namespace: "kubewarden"},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: readyReplicas},
+		}
+	}
+	newPolicy := func(name, policyServer string) *policiesv1.ClusterAdmissionPolicy {
+		return testutils.NewClusterAdmissionPolicyFactory().
+			Name(name).
+			PolicyServer(policyServer).
+			Rule(admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			}).
+			Build()
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:    map[string]string{"app.kubernetes.io/instance": "policy-server-default"},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 443}},
+		},
+	}
+	notReconciled := metav1.Condition{
+		Type:    string(policiesv1.PolicyServerDeploymentReconciled),
+		Status:  metav1.ConditionFalse,
+		Message: "invalid image",
+	}
+
+	client, err := testutils.NewFakeClient(
+		newPolicyServer("default"),
+		newDeployment("default", 1),
+		service,
+		newPolicyServer("scaled-down"),
+		newDeployment("scaled-down", 0),
+		newPolicyServer("not-reconciled", notReconciled),
+		newPolicy("policy2", "default"),
+		newPolicy("policy1", "default"),
+		newPolicy("scaled-down-policy", "scaled-down"),
+		newPolicy("not-reconciled-policy", "not-reconciled"),
+		newPolicy("missing-policy", "missing"),
+		testutils.NewClusterAdmissionPolicyFactory().Name("inactive-policy").PolicyServer("inactive").Status(policiesv1.PolicyStatusPending).Build(),
+	)
+	require.NoError(t, err)
+	policiesClient := NewClient(client, nil, ResourceFilter{}, PolicyFilter{}, "kubewarden", "", slog.Default())
+
+	healths, err := policiesClient.CheckPolicyServers(t.Context())
+	require.NoError(t, err)
+	// the PolicyServers of the policies not audited are not checked
+	require.Len(t, healths, 4)
+
+	assert.Equal(t, "default", healths[0].Name)
+	assert.True(t, healths[0].Healthy())
+	assert.Equal(t, []string{"clusterwide-policy1", "clusterwide-policy2"}, healths[0].Policies)
+	require.NotNil(t, healths[0].AuditURL)
+	assert.Contains(t, healths[0].AuditURL.String(), "https://policy-server-default.kubewarden.svc:443/audit/clusterwide-policy")
+
+	assert.Equal(t, "missing", healths[1].Name)
+	assert.Equal(t, "the PolicyServer does not exist", healths[1].Reason)
+	assert.Nil(t, healths[1].AuditURL)
+
+	assert.Equal(t, "not-reconciled", healths[2].Name)
+	assert.Equal(t, "the Deployment of the PolicyServer is not reconciled: invalid image", healths[2].Reason)
+
+	assert.Equal(t, "scaled-down", healths[3].Name)
+	assert.Equal(t, `the Deployment "policy-server-scaled-down" has no ready replicas`, healths[3].Reason)
+	assert.Equal(t, []string{"clusterwide-scaled-down-policy"}, healths[3].Policies)
+
+	// the policies of the unhealthy PolicyServers are errored
+	policiesClient.SetUnhealthyPolicyServers(map[string]string{"default": "unavailable"})
+	namespacePolicies, err := policiesClient.GetPoliciesByNamespace(t.Context(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+	require.NoError(t, err)
+	assert.Contains(t, namespacePolicies.ErroredPolicies, "clusterwide-policy1")
+	assert.Contains(t, namespacePolicies.ErroredPolicies, "clusterwide-policy2")
+}
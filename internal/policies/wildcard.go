@@ -0,0 +1,169 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+const listVerb = "list"
+
+// ResourceFilter bounds the resources the wildcard rules of the policies are
+// expanded to. The entries are resource names qualified by their API group,
+// like "deployments.apps" or "pods", and can be shell patterns like "*.apps".
+type ResourceFilter struct {
+	// Include lists the resources wildcard rules can be expanded to. All the resources are included when empty
+	Include []string
+	// Exclude lists the resources wildcard rules are never expanded to. It takes precedence over Include
+	Exclude []string
+}
+
+// Validate checks that all the entries of the filter are valid patterns.
+func (r ResourceFilter) Validate() error {
+	for _, pattern := range slices.Concat(r.Include, r.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid resource pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// IsEmpty returns true if the filter allows all the resources.
+func (r ResourceFilter) IsEmpty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0
+}
+
+// Allows returns true if a resource known by any of the given names is
+// allowed: none of its names matches Exclude, and one of them matches Include
+// when it's not empty.
+func (r ResourceFilter) Allows(names ...string) bool {
+	if slices.ContainsFunc(names, func(name string) bool { return matchesAny(r.Exclude, name) }) {
+		return false
+	}
+
+	return len(r.Include) == 0 || slices.ContainsFunc(names, func(name string) bool { return matchesAny(r.Include, name) })
+}
+
+func matchesAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, err := path.Match(pattern, name)
+		return err == nil && matched
+	})
+}
+
+// expandWildcardRules replaces the rules containing a wildcard in the
+// APIGroups, APIVersions or Resources fields with one rule for each of the
+// matching resources known by the discovery API. Only the resources
+// supporting the list verb are considered, subresources are ignored.
+// When APIVersions contains a wildcard, only the preferred version of each
+// group is considered, to not audit the same objects more than once.
+// Wildcard rules are dropped when no discovery client is configured.
+func (f *Client) expandWildcardRules(ctx context.Context, rules []admissionregistrationv1.RuleWithOperations) ([]admissionregistrationv1.RuleWithOperations, error) {
+	expandedRules := []admissionregistrationv1.RuleWithOperations{}
+	var groups []*metav1.APIGroup
+	var resourceLists []*metav1.APIResourceList
+	discovered := false
+
+	for _, rule := range rules {
+		if !isWildcardRule(rule) {
+			expandedRules = append(expandedRules, rule)
+			continue
+		}
+		if f.discovery == nil {
+			continue
+		}
+
+		if !discovered {
+			var err error
+			groups, resourceLists, err = discovery.ServerGroupsAndResources(f.discovery)
+			if err != nil {
+				if !discovery.IsGroupDiscoveryFailedError(err) {
+					return nil, fmt.Errorf("failed to discover the API resources: %w", err)
+				}
+				f.logger.WarnContext(ctx, "failed to discover some API groups, their resources are not targeted by wildcard rules",
+					slog.String("error", err.Error()))
+			}
+			discovered = true
+		}
+
+		for _, gvr := range f.discoverRuleGVRs(rule, groups, resourceLists) {
+			expandedRules = append(expandedRules, admissionregistrationv1.RuleWithOperations{
+				Operations: rule.Operations,
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{gvr.Group},
+					APIVersions: []string{gvr.Version},
+					Resources:   []string{gvr.Resource},
+					Scope:       rule.Scope,
+				},
+			})
+		}
+	}
+
+	return expandedRules, nil
+}
+
+// discoverRuleGVRs returns the discovered resources matching the given wildcard rule.
+func (f *Client) discoverRuleGVRs(rule admissionregistrationv1.RuleWithOperations, groups []*metav1.APIGroup, resourceLists []*metav1.APIResourceList) []schema.GroupVersionResource {
+	preferredVersions := map[string]string{}
+	for _, group := range groups {
+		preferredVersions[group.Name] = group.PreferredVersion.Version
+	}
+
+	gvrs := []schema.GroupVersionResource{}
+	for _, resourceList := range resourceLists {
+		groupVersion, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		if !matchesRuleField(rule.APIGroups, groupVersion.Group) {
+			continue
+		}
+		if slices.Contains(rule.APIVersions, "*") {
+			if preferredVersions[groupVersion.Group] != groupVersion.Version {
+				continue
+			}
+		} else if !slices.Contains(rule.APIVersions, groupVersion.Version) {
+			continue
+		}
+
+		for _, resource := range resourceList.APIResources {
+			if strings.Contains(resource.Name, "/") || !slices.Contains(resource.Verbs, listVerb) {
+				continue
+			}
+			if !matchesRuleField(rule.Resources, resource.Name) {
+				continue
+			}
+			gvr := groupVersion.WithResource(resource.Name)
+			if !f.resourceFilter.Allows(gvr.GroupResource().String()) {
+				continue
+			}
+			gvrs = append(gvrs, gvr)
+		}
+	}
+
+	return gvrs
+}
+
+// isWildcardRule returns true if the rule contains a wildcard in the APIGroups, APIVersions or Resources fields.
+func isWildcardRule(rule admissionregistrationv1.RuleWithOperations) bool {
+	return slices.ContainsFunc(rule.APIGroups, isWildcard) ||
+		slices.ContainsFunc(rule.APIVersions, isWildcard) ||
+		slices.ContainsFunc(rule.Resources, isWildcard)
+}
+
+func isWildcard(value string) bool {
+	return strings.Contains(value, "*")
+}
+
+func matchesRuleField(values []string, value string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, value)
+}
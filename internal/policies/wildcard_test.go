@@ -0,0 +1,276 @@
+package policies
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newFakeDiscovery() *fakediscovery.FakeDiscovery {
+	listVerbs := metav1.Verbs{"get", "list", "watch", "create", "update", "delete"}
+
+	return &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: listVerbs},
+						{Name: "pods/log", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get"}},
+						{Name: "namespaces", Namespaced: false, Kind: "Namespace", Verbs: listVerbs},
+						{Name: "bindings", Namespaced: true, Kind: "Binding", Verbs: metav1.Verbs{"create"}},
+						{Name: "events", Namespaced: true, Kind: "Event", Verbs: listVerbs},
+					},
+				},
+				{
+					// the first version of a group is the preferred one
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: listVerbs},
+						{Name: "deployments/scale", Namespaced: true, Kind: "Scale", Verbs: metav1.Verbs{"get", "update"}},
+					},
+				},
+				{
+					GroupVersion: "apps/v1beta1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: listVerbs},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExpandWildcardRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		rule           admissionregistrationv1.Rule
+		resourceFilter ResourceFilter
+		expectedGVRs   []schema.GroupVersionResource
+	}{
+		{
+			name: "all resources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "", Version: "v1", Resource: "pods"},
+				{Group: "", Version: "v1", Resource: "namespaces"},
+				{Group: "", Version: "v1", Resource: "events"},
+				{Group: "apps", Version: "v1", Resource: "deployments"},
+			},
+		},
+		{
+			name: "all resources of a version",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"v1beta1"},
+				Resources:   []string{"*"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "apps", Version: "v1beta1", Resource: "deployments"},
+			},
+		},
+		{
+			name: "resource in any group",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"deployments"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "apps", Version: "v1", Resource: "deployments"},
+			},
+		},
+		{
+			name: "subresources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*/scale"},
+			},
+			expectedGVRs: []schema.GroupVersionResource{},
+		},
+		{
+			name: "excluded resources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+			resourceFilter: ResourceFilter{Exclude: []string{"events", "*.apps"}},
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "", Version: "v1", Resource: "pods"},
+				{Group: "", Version: "v1", Resource: "namespaces"},
+			},
+		},
+		{
+			name: "included resources",
+			rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+			resourceFilter: ResourceFilter{Include: []string{"pods", "*.apps"}, Exclude: []string{"pods"}},
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "apps", Version: "v1", Resource: "deployments"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := testutils.NewFakeClient()
+			require.NoError(t, err)
+			policiesClient := NewClient(client, newFakeDiscovery(), test.resourceFilter, PolicyFilter{}, "kubewarden", "", slog.Default())
+
+			rules, err := policiesClient.expandWildcardRules(t.Context(), []admissionregistrationv1.RuleWithOperations{
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Update},
+					Rule:       test.rule,
+				},
+			})
+			require.NoError(t, err)
+
+			gvrs := []schema.GroupVersionResource{}
+			for _, rule := range rules {
+				assert.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Update}, rule.Operations)
+				gvrs = append(gvrs, getRuleGVRs(rule)...)
+			}
+			assert.ElementsMatch(t, test.expectedGVRs, gvrs)
+		})
+	}
+}
+
+func TestExpandWildcardRulesWithoutDiscovery(t *testing.T) {
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	policiesClient := NewClient(client, nil, ResourceFilter{}, PolicyFilter{}, "kubewarden", "", slog.Default())
+
+	rule := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		},
+	}
+	wildcardRule := admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{"*"},
+			APIVersions: []string{"*"},
+			Resources:   []string{"*"},
+		},
+	}
+
+	rules, err := policiesClient.expandWildcardRules(t.Context(), []admissionregistrationv1.RuleWithOperations{rule, wildcardRule})
+	require.NoError(t, err)
+	assert.Equal(t, []admissionregistrationv1.RuleWithOperations{rule}, rules)
+}
+
+func TestGetPoliciesByNamespaceWithWildcardRules(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting all the resources
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"*"},
+			APIVersions: []string{"*"},
+			Resources:   []string{"*"},
+		}).
+		Build()
+
+	// an AdmissionPolicy targeting only subresources, it should be skipped
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy").
+		Namespace("test").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"*"},
+			APIVersions: []string{"*"},
+			Resources:   []string{"*/scale"},
+		}).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+		admissionPolicy,
+	)
+	require.NoError(t, err)
+
+	resourceFilter := ResourceFilter{Exclude: []string{"events"}}
+	policiesClient := NewClient(client, newFakeDiscovery(), resourceFilter, PolicyFilter{}, "kubewarden", "", slog.Default())
+
+	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
+	require.NoError(t, err)
+
+	// namespaces are cluster-wide resources, so they are not audited within the namespace
+	assert.ElementsMatch(t, []schema.GroupVersionResource{
+		{Group: "", Version: "v1", Resource: "pods"},
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+	}, slices.Collect(maps.Keys(policies.PoliciesByGVR)))
+	assert.Equal(t, 1, policies.PolicyNum)
+	assert.Equal(t, 1, policies.SkippedNum)
+	assert.Equal(t, 0, policies.ErroredNum)
+
+	gvrs, err := policiesClient.GetTargetedGroupVersionResources(t.Context(), clusterAdmissionPolicy)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []schema.GroupVersionResource{
+		{Group: "", Version: "v1", Resource: "pods"},
+		{Group: "", Version: "v1", Resource: "namespaces"},
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+	}, gvrs)
+}
+
+func TestResourceFilterValidate(t *testing.T) {
+	require.NoError(t, ResourceFilter{Include: []string{"*.apps", "pods"}, Exclude: []string{"events"}}.Validate())
+	require.Error(t, ResourceFilter{Exclude: []string{"[events"}}.Validate())
+}
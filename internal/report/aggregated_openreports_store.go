@@ -0,0 +1,788 @@
+package report
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// aggregatedReportName is the name of the aggregated reports, followed by
+	// the number of the shard
+	aggregatedReportName = "kubewarden-audit"
+	// defaultMaxAggregatedReportSize is the size of the results of an aggregated
+	// report above which they are split into a new shard. It leaves room under
+	// the 1.5 MiB limit of the objects stored by the API server.
+	defaultMaxAggregatedReportSize = 1024 * 1024
+)
+
+// AggregatedOpenReportStore is a store writing one OpenReports Report per
+// namespace and one ClusterReport for all the cluster-wide resources, instead
+// of one report per resource. Each result references the audited resource.
+// The reports are split into numbered shards when they grow too large.
+//
+// The results of the audited resources are buffered, and written when the scan
+// of the namespace is completed, by DeleteOldReports, or when the reports are
+// flushed. A flush only rewrites the shards holding the flushed resources.
+type AggregatedOpenReportStore struct {
+	// client is a controller-runtime client that knows about the OpenReports CRDs
+	client client.Client
+	// logger is used to log the messages
+	logger *slog.Logger
+	// maxReportSize is the size of the results of a shard above which a new shard is started
+	maxReportSize int
+	// writeMutexes serialize the writes of the reports of each namespace,
+	// which read and update the stored reports. The namespaces are written
+	// in parallel
+	writeMutexes map[string]*sync.Mutex
+	// mutex protects writeMutexes, buffered, flushed and stored
+	mutex sync.Mutex
+	// buffered holds the results of the audited resources by namespace. The
+	// cluster-wide resources are under the empty namespace
+	buffered map[string]map[types.UID]aggregatedEntry
+	// flushed holds the resources whose results have been written by a flush,
+	// by namespace, with the UID of their scan run. Their results are no longer
+	// buffered, the scan run keeps them when it replaces the previous results
+	flushed map[string]map[types.UID]string
+	// stored caches the results of the stored reports by namespace, to get
+	// the results of the previous scans
+	stored map[string]map[types.UID]aggregatedEntry
+}
+
+// aggregatedEntry holds the results of a resource.
+type aggregatedEntry struct {
+	runUID  string
+	scope   *corev1.ObjectReference
+	results []openreports.ReportResult
+}
+
+// aggregatedShard is a stored aggregated report.
+type aggregatedShard struct {
+	index   int
+	results []openreports.ReportResult
+	// runUID is the UID of the scan run that last wrote the shard
+	runUID string
+	// changed is true when the results of the shard have to be written
+	changed bool
+}
+
+// NewAggregatedOpenReportStore creates a new AggregatedOpenReportStore.
+func NewAggregatedOpenReportStore(client client.Client, logger *slog.Logger) Store {
+	return &AggregatedOpenReportStore{
+		client:        client,
+		logger:        logger.With("component", "aggregatedreportstore"),
+		maxReportSize: defaultMaxAggregatedReportSize,
+		writeMutexes:  map[string]*sync.Mutex{},
+		buffered:      map[string]map[types.UID]aggregatedEntry{},
+		flushed:       map[string]map[types.UID]string{},
+		stored:        map[string]map[types.UID]aggregatedEntry{},
+	}
+}
+
+// GetReport returns the results of the given resource stored in the aggregated
+// Report of its namespace.
+func (s *AggregatedOpenReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	entry, err := s.getStoredEntry(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenReport{report: &openreports.Report{Scope: entry.scope, Results: entry.results}}, nil
+}
+
+// CreateOrPatchReport buffers the results of the given OpenReport, until the
+// aggregated Report of the namespace is written.
+func (s *AggregatedOpenReportStore) CreateOrPatchReport(_ context.Context, obj any) error {
+	openReport, ok := obj.(*OpenReport)
+	if !ok {
+		return fmt.Errorf("expected *OpenReport, got %T", obj)
+	}
+	s.buffer(openReport.report.GetNamespace(), openReport.report.ObjectMeta, openReport.report.Scope, openReport.report.Results)
+
+	return nil
+}
+
+// FlushReports merges the buffered results of the namespace into its aggregated
+// Report.
+func (s *AggregatedOpenReportStore) FlushReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.flush(ctx, scanRunID, namespace)
+}
+
+// DeleteOldReports writes the aggregated Report of the namespace with the
+// results of the current scan run, replacing the results of the previous runs,
+// and deletes the reports that do not belong to the current scan run.
+func (s *AggregatedOpenReportStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	if err := s.replace(ctx, scanRunID, namespace); err != nil {
+		return err
+	}
+
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+	if err := s.client.DeleteAllOf(ctx, &openreports.Report{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
+		LabelSelector: labelSelector,
+		Namespace:     namespace,
+	}}); err != nil {
+		return fmt.Errorf("failed to delete Reports: %w", err)
+	}
+	return nil
+}
+
+// GetClusterReport returns the results of the given cluster-wide resource
+// stored in the aggregated ClusterReport.
+func (s *AggregatedOpenReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	entry, err := s.getStoredEntry(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenClusterReport{report: &openreports.ClusterReport{Scope: entry.scope, Results: entry.results}}, nil
+}
+
+// CreateOrPatchClusterReport buffers the results of the given
+// OpenClusterReport, until the aggregated ClusterReport is written.
+func (s *AggregatedOpenReportStore) CreateOrPatchClusterReport(_ context.Context, obj any) error {
+	openReport, ok := obj.(*OpenClusterReport)
+	if !ok {
+		return fmt.Errorf("expected *OpenClusterReport, got %T", obj)
+	}
+	s.buffer("", openReport.report.ObjectMeta, openReport.report.Scope, openReport.report.Results)
+
+	return nil
+}
+
+// FlushClusterReports merges the buffered results of the cluster-wide
+// resources into the aggregated ClusterReport.
+func (s *AggregatedOpenReportStore) FlushClusterReports(ctx context.Context, scanRunID string) error {
+	return s.flush(ctx, scanRunID, "")
+}
+
+// DeleteOldClusterReports writes the aggregated ClusterReport with the results
+// of the current scan run, replacing the results of the previous runs, and
+// deletes the cluster reports that do not belong to the current scan run.
+func (s *AggregatedOpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	if err := s.replace(ctx, scanRunID, ""); err != nil {
+		return err
+	}
+
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+	if err := s.client.DeleteAllOf(ctx, &openreports.ClusterReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
+		LabelSelector: labelSelector,
+	}}); err != nil {
+		return fmt.Errorf("failed to delete ClusterReports: %w", err)
+	}
+	return nil
+}
+
+// ListStaleResources returns the resources of the aggregated Report of the
+// namespace whose results have not been buffered by the given scan run. It must
+// be called before DeleteOldReports replaces the stored results.
+func (s *AggregatedOpenReportStore) ListStaleResources(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	return s.listStale(ctx, scanRunID, namespace)
+}
+
+// ListStaleClusterResources is like ListStaleResources, for the aggregated
+// ClusterReport.
+func (s *AggregatedOpenReportStore) ListStaleClusterResources(ctx context.Context, scanRunID string) ([]corev1.ObjectReference, error) {
+	return s.listStale(ctx, scanRunID, "")
+}
+
+func (s *AggregatedOpenReportStore) listStale(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	entries, err := s.loadEntries(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var resources []corev1.ObjectReference
+	for uid, entry := range entries {
+		if buffered, found := s.buffered[namespace][uid]; found && buffered.runUID == scanRunID {
+			continue
+		}
+		resources = append(resources, *entry.scope)
+	}
+	slices.SortFunc(resources, func(a, b corev1.ObjectReference) int {
+		return cmp.Compare(a.UID, b.UID)
+	})
+	return resources, nil
+}
+
+// lockNamespace locks the writes of the reports of the namespace, and returns
+// the function unlocking them.
+func (s *AggregatedOpenReportStore) lockNamespace(namespace string) func() {
+	s.mutex.Lock()
+	writeMutex, found := s.writeMutexes[namespace]
+	if !found {
+		writeMutex = &sync.Mutex{}
+		s.writeMutexes[namespace] = writeMutex
+	}
+	s.mutex.Unlock()
+
+	writeMutex.Lock()
+	return writeMutex.Unlock
+}
+
+func (s *AggregatedOpenReportStore) buffer(namespace string, objMeta metav1.ObjectMeta, scope *corev1.ObjectReference, results []openreports.ReportResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.buffered[namespace] == nil {
+		s.buffered[namespace] = map[types.UID]aggregatedEntry{}
+	}
+	s.buffered[namespace][scope.UID] = aggregatedEntry{
+		runUID:  objMeta.Labels[auditConstants.AuditScannerRunUIDLabel],
+		scope:   scope,
+		results: results,
+	}
+}
+
+// flush writes the results buffered by the given scan run into the aggregated
+// reports of the namespace, rewriting only the shards holding the flushed
+// resources, and clears them from the buffer.
+func (s *AggregatedOpenReportStore) flush(ctx context.Context, scanRunID, namespace string) error {
+	unlock := s.lockNamespace(namespace)
+	defer unlock()
+
+	s.mutex.Lock()
+	entries := map[types.UID]aggregatedEntry{}
+	for uid, entry := range s.buffered[namespace] {
+		if entry.runUID == scanRunID {
+			entries[uid] = entry
+			delete(s.buffered[namespace], uid)
+		}
+	}
+	flushed := s.flushed[namespace]
+	if flushed == nil {
+		flushed = map[types.UID]string{}
+		s.flushed[namespace] = flushed
+	}
+	// the resources flushed by the previous scan runs are no longer needed
+	maps.DeleteFunc(flushed, func(_ types.UID, runUID string) bool { return runUID != scanRunID })
+	for uid := range entries {
+		flushed[uid] = scanRunID
+	}
+	delete(s.stored, namespace)
+	s.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.updateShards(ctx, scanRunID, namespace, entries); err != nil {
+		// buffer the results again, unless newer ones have been buffered meanwhile
+		s.mutex.Lock()
+		if s.buffered[namespace] == nil {
+			s.buffered[namespace] = map[types.UID]aggregatedEntry{}
+		}
+		for uid, entry := range entries {
+			if _, found := s.buffered[namespace][uid]; !found {
+				s.buffered[namespace][uid] = entry
+			}
+		}
+		s.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// replace writes the aggregated reports of the namespace with the results
+// of the given scan run only, the buffered ones and the ones already flushed,
+// and clears the buffered results.
+func (s *AggregatedOpenReportStore) replace(ctx context.Context, scanRunID, namespace string) error {
+	unlock := s.lockNamespace(namespace)
+	defer unlock()
+
+	s.mutex.Lock()
+	entries := map[types.UID]aggregatedEntry{}
+	for uid, entry := range s.buffered[namespace] {
+		if entry.runUID == scanRunID {
+			entries[uid] = entry
+		}
+	}
+	var flushed []types.UID
+	for uid, runUID := range s.flushed[namespace] {
+		if _, found := entries[uid]; !found && runUID == scanRunID {
+			flushed = append(flushed, uid)
+		}
+	}
+	delete(s.buffered, namespace)
+	delete(s.flushed, namespace)
+	delete(s.stored, namespace)
+	s.mutex.Unlock()
+
+	if len(flushed) > 0 {
+		stored, err := s.loadEntries(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		for _, uid := range flushed {
+			if entry, found := stored[uid]; found {
+				entries[uid] = entry
+			}
+		}
+	}
+
+	return s.writeReports(ctx, scanRunID, namespace, entries)
+}
+
+// ListReports returns the results of the resources of the namespace stored in
+// its aggregated Report, by resource UID.
+func (s *AggregatedOpenReportStore) ListReports(ctx context.Context, namespace string) (map[types.UID]Report, error) {
+	entries, err := s.getStoredEntries(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[types.UID]Report, len(entries))
+	for uid, entry := range entries {
+		reports[uid] = &OpenReport{report: &openreports.Report{Scope: entry.scope, Results: entry.results}}
+	}
+	return reports, nil
+}
+
+// ListClusterReports returns the results of the cluster-wide resources stored
+// in the aggregated ClusterReport, by resource UID.
+func (s *AggregatedOpenReportStore) ListClusterReports(ctx context.Context) (map[types.UID]Report, error) {
+	entries, err := s.getStoredEntries(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[types.UID]Report, len(entries))
+	for uid, entry := range entries {
+		reports[uid] = &OpenClusterReport{report: &openreports.ClusterReport{Scope: entry.scope, Results: entry.results}}
+	}
+	return reports, nil
+}
+
+// getStoredEntry returns the stored results of the given resource.
+func (s *AggregatedOpenReportStore) getStoredEntry(ctx context.Context, resource unstructured.Unstructured) (aggregatedEntry, error) {
+	entries, err := s.getStoredEntries(ctx, resource.GetNamespace())
+	if err != nil {
+		return aggregatedEntry{}, err
+	}
+
+	entry, found := entries[resource.GetUID()]
+	if !found {
+		return aggregatedEntry{}, fmt.Errorf("%w: aggregated report results of %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+	}
+	return entry, nil
+}
+
+// getStoredEntries returns the stored results of the namespace. The stored
+// reports of a namespace are read once, and cached until they are written.
+func (s *AggregatedOpenReportStore) getStoredEntries(ctx context.Context, namespace string) (map[types.UID]aggregatedEntry, error) {
+	s.mutex.Lock()
+	entries, found := s.stored[namespace]
+	s.mutex.Unlock()
+	if found {
+		return entries, nil
+	}
+
+	entries, err := s.loadEntries(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.stored[namespace] = entries
+	s.mutex.Unlock()
+	return entries, nil
+}
+
+// loadEntries reads the results of the aggregated reports of the namespace,
+// or of the aggregated cluster reports when the namespace is empty.
+func (s *AggregatedOpenReportStore) loadEntries(ctx context.Context, namespace string) (map[types.UID]aggregatedEntry, error) {
+	shards, err := s.loadShards(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[types.UID]aggregatedEntry{}
+	for _, shard := range shards {
+		addEntries(entries, shard.runUID, shard.results)
+	}
+	return entries, nil
+}
+
+// loadShards reads the aggregated reports of the namespace, or the aggregated
+// cluster reports when the namespace is empty, sorted by shard number.
+func (s *AggregatedOpenReportStore) loadShards(ctx context.Context, namespace string) ([]*aggregatedShard, error) {
+	listOptions := []client.ListOption{client.MatchingLabels{labelAggregatedReport: valueTypeTrue}}
+	var shards []*aggregatedShard
+
+	if namespace == "" {
+		reportList := &openreports.ClusterReportList{}
+		if err := s.client.List(ctx, reportList, listOptions...); err != nil {
+			return nil, fmt.Errorf("failed to list aggregated cluster reports: %w", err)
+		}
+		for _, report := range reportList.Items {
+			shards = appendShard(shards, report.ObjectMeta, report.Results)
+		}
+	} else {
+		reportList := &openreports.ReportList{}
+		if err := s.client.List(ctx, reportList, append(listOptions, client.InNamespace(namespace))...); err != nil {
+			return nil, fmt.Errorf("failed to list aggregated reports of namespace %s: %w", namespace, err)
+		}
+		for _, report := range reportList.Items {
+			shards = appendShard(shards, report.ObjectMeta, report.Results)
+		}
+	}
+
+	slices.SortFunc(shards, func(a, b *aggregatedShard) int {
+		return cmp.Compare(a.index, b.index)
+	})
+	return shards, nil
+}
+
+// appendShard appends the given aggregated report to the shards, unless its
+// name doesn't carry a shard number.
+func appendShard(shards []*aggregatedShard, objMeta metav1.ObjectMeta, results []openreports.ReportResult) []*aggregatedShard {
+	index, err := strconv.Atoi(strings.TrimPrefix(objMeta.Name, aggregatedReportName+"-"))
+	if err != nil {
+		return shards
+	}
+	return append(shards, &aggregatedShard{
+		index:   index,
+		results: results,
+		runUID:  objMeta.Labels[auditConstants.AuditScannerRunUIDLabel],
+	})
+}
+
+// addEntries groups the results of an aggregated report by resource. The results
+// of a resource can be split across several shards.
+func addEntries(entries map[types.UID]aggregatedEntry, runUID string, results []openreports.ReportResult) {
+	for _, result := range results {
+		for _, subject := range result.Subjects {
+			entry, found := entries[subject.UID]
+			if !found {
+				entry = aggregatedEntry{runUID: runUID, scope: subject.DeepCopy()}
+			}
+			entry.results = append(entry.results, *result.DeepCopy())
+			entries[subject.UID] = entry
+		}
+	}
+}
+
+// writeReports writes the given results into the aggregated reports of the
+// namespace, split into shards, and deletes the shards that are no longer
+// needed.
+func (s *AggregatedOpenReportStore) writeReports(ctx context.Context, scanRunID, namespace string, entries map[types.UID]aggregatedEntry) error {
+	shards := shardResults(entries, s.maxReportSize)
+	written := map[string]struct{}{}
+
+	for index, results := range shards {
+		objMeta := getAggregatedReportObjectMeta(scanRunID, namespace, index)
+		if err := s.writeShard(ctx, objMeta, results); err != nil {
+			return err
+		}
+		written[objMeta.Name] = struct{}{}
+	}
+
+	return s.deleteUnwrittenShards(ctx, namespace, written)
+}
+
+// updateShards replaces the results of the given resources in the stored
+// aggregated reports of the namespace, and writes only the shards that
+// changed. The resources not stored yet are added to the last shard, or to a
+// new one when it's full.
+func (s *AggregatedOpenReportStore) updateShards(ctx context.Context, scanRunID, namespace string, entries map[types.UID]aggregatedEntry) error {
+	shards, err := s.loadShards(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	for _, uid := range slices.Sorted(maps.Keys(entries)) {
+		shards = placeEntry(shards, entries[uid], s.maxReportSize)
+	}
+
+	for _, shard := range shards {
+		if !shard.changed {
+			continue
+		}
+		objMeta := getAggregatedReportObjectMeta(scanRunID, namespace, shard.index)
+		if len(shard.results) == 0 {
+			if err := s.deleteShard(ctx, objMeta); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.writeShard(ctx, objMeta, shard.results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeEntry replaces the results of the resource of the entry in the shards.
+// The new results take the place of the old ones in the first shard that held
+// the resource.
+func placeEntry(shards []*aggregatedShard, entry aggregatedEntry, maxSize int) []*aggregatedShard {
+	isEntryResult := func(result openreports.ReportResult) bool {
+		return slices.ContainsFunc(result.Subjects, func(subject corev1.ObjectReference) bool {
+			return subject.UID == entry.scope.UID
+		})
+	}
+
+	var target *aggregatedShard
+	position := 0
+	for _, shard := range shards {
+		index := slices.IndexFunc(shard.results, isEntryResult)
+		if index < 0 {
+			continue
+		}
+		if target == nil {
+			target = shard
+			position = index
+		}
+		shard.results = slices.DeleteFunc(shard.results, isEntryResult)
+		shard.changed = true
+	}
+
+	results := entryResults(entry)
+	if target == nil {
+		if len(results) == 0 {
+			return shards
+		}
+		if len(shards) > 0 && resultsSize(shards[len(shards)-1].results, maxSize)+resultsSize(results, maxSize) <= maxSize {
+			target = shards[len(shards)-1]
+		} else {
+			index := 0
+			if len(shards) > 0 {
+				index = shards[len(shards)-1].index + 1
+			}
+			target = &aggregatedShard{index: index}
+			shards = append(shards, target)
+		}
+		position = len(target.results)
+	}
+	target.results = slices.Insert(target.results, position, results...)
+	target.changed = true
+
+	return shards
+}
+
+// writeShard creates or patches the aggregated report, or cluster report when
+// the namespace is empty, with the given results.
+func (s *AggregatedOpenReportStore) writeShard(ctx context.Context, objMeta metav1.ObjectMeta, results []openreports.ReportResult) error {
+	summary := getAggregatedReportSummary(results)
+	if objMeta.Namespace == "" {
+		return s.createOrPatchClusterShard(ctx, objMeta, summary, results)
+	}
+	return s.createOrPatchShard(ctx, objMeta, summary, results)
+}
+
+// deleteShard deletes the aggregated report, or cluster report when the
+// namespace is empty.
+func (s *AggregatedOpenReportStore) deleteShard(ctx context.Context, objMeta metav1.ObjectMeta) error {
+	var shard client.Object = &openreports.Report{ObjectMeta: metav1.ObjectMeta{Name: objMeta.Name, Namespace: objMeta.Namespace}}
+	if objMeta.Namespace == "" {
+		shard = &openreports.ClusterReport{ObjectMeta: metav1.ObjectMeta{Name: objMeta.Name}}
+	}
+	if err := s.client.Delete(ctx, shard); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete aggregated report %s: %w", objMeta.Name, err)
+	}
+	s.logger.DebugContext(ctx, "aggregated report shard deleted",
+		slog.String("report-name", objMeta.Name),
+		slog.String("report-namespace", objMeta.Namespace))
+	return nil
+}
+
+func (s *AggregatedOpenReportStore) createOrPatchShard(ctx context.Context, objMeta metav1.ObjectMeta, summary openreports.ReportSummary, results []openreports.ReportResult) error {
+	report := &openreports.Report{ObjectMeta: metav1.ObjectMeta{
+		Name:      objMeta.Name,
+		Namespace: objMeta.Namespace,
+	}}
+
+	operation, err := controllerutil.CreateOrPatch(ctx, s.client, report, func() error {
+		report.ObjectMeta.Labels = objMeta.Labels
+		report.Scope = nil
+		report.Summary = summary
+		report.Results = results
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create or patch aggregated report %s/%s: %w", objMeta.Namespace, objMeta.Name, err)
+	}
+
+	s.logger.DebugContext(ctx, fmt.Sprintf("aggregated Report %s", operation),
+		slog.String("report-name", objMeta.Name),
+		slog.String("report-namespace", objMeta.Namespace),
+		slog.Int("results", len(results)))
+	return nil
+}
+
+func (s *AggregatedOpenReportStore) createOrPatchClusterShard(ctx context.Context, objMeta metav1.ObjectMeta, summary openreports.ReportSummary, results []openreports.ReportResult) error {
+	report := &openreports.ClusterReport{ObjectMeta: metav1.ObjectMeta{
+		Name: objMeta.Name,
+	}}
+
+	operation, err := controllerutil.CreateOrPatch(ctx, s.client, report, func() error {
+		report.ObjectMeta.Labels = objMeta.Labels
+		report.Scope = nil
+		report.Summary = summary
+		report.Results = results
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create or patch aggregated cluster report %s: %w", objMeta.Name, err)
+	}
+
+	s.logger.DebugContext(ctx, fmt.Sprintf("aggregated ClusterReport %s", operation),
+		slog.String("report-name", objMeta.Name),
+		slog.Int("results", len(results)))
+	return nil
+}
+
+// deleteUnwrittenShards deletes the aggregated reports of the namespace that
+// have not been written, because fewer shards are needed.
+func (s *AggregatedOpenReportStore) deleteUnwrittenShards(ctx context.Context, namespace string, written map[string]struct{}) error {
+	listOptions := []client.ListOption{client.MatchingLabels{labelAggregatedReport: valueTypeTrue}}
+	var shards []client.Object
+
+	if namespace == "" {
+		reportList := &openreports.ClusterReportList{}
+		if err := s.client.List(ctx, reportList, listOptions...); err != nil {
+			return fmt.Errorf("failed to list aggregated cluster reports: %w", err)
+		}
+		for i := range reportList.Items {
+			shards = append(shards, &reportList.Items[i])
+		}
+	} else {
+		reportList := &openreports.ReportList{}
+		if err := s.client.List(ctx, reportList, append(listOptions, client.InNamespace(namespace))...); err != nil {
+			return fmt.Errorf("failed to list aggregated reports of namespace %s: %w", namespace, err)
+		}
+		for i := range reportList.Items {
+			shards = append(shards, &reportList.Items[i])
+		}
+	}
+
+	for _, shard := range shards {
+		if _, found := written[shard.GetName()]; found {
+			continue
+		}
+		if err := s.client.Delete(ctx, shard); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete aggregated report %s: %w", shard.GetName(), err)
+		}
+		s.logger.DebugContext(ctx, "aggregated report shard deleted",
+			slog.String("report-name", shard.GetName()),
+			slog.String("report-namespace", namespace))
+	}
+	return nil
+}
+
+// shardResults returns the results of the given resources, referencing the
+// resources, split into shards whose results don't exceed maxSize once encoded
+// in JSON. The results are sorted by resource, to keep the shards stable across
+// the scans.
+func shardResults(entries map[types.UID]aggregatedEntry, maxSize int) [][]openreports.ReportResult {
+	sortedEntries := slices.SortedFunc(maps.Values(entries), func(a, b aggregatedEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.scope.APIVersion, b.scope.APIVersion),
+			cmp.Compare(a.scope.Kind, b.scope.Kind),
+			cmp.Compare(a.scope.Name, b.scope.Name),
+			cmp.Compare(a.scope.UID, b.scope.UID),
+		)
+	})
+
+	var shards [][]openreports.ReportResult
+	var shard []openreports.ReportResult
+	shardSize := 0
+	for _, entry := range sortedEntries {
+		for _, result := range entryResults(entry) {
+			resultSize := resultsSize([]openreports.ReportResult{result}, maxSize)
+			if len(shard) > 0 && shardSize+resultSize > maxSize {
+				shards = append(shards, shard)
+				shard = nil
+				shardSize = 0
+			}
+			shard = append(shard, result)
+			shardSize += resultSize
+		}
+	}
+	if len(shard) > 0 {
+		shards = append(shards, shard)
+	}
+
+	return shards
+}
+
+// entryResults returns the results of the entry, referencing its resource.
+func entryResults(entry aggregatedEntry) []openreports.ReportResult {
+	results := make([]openreports.ReportResult, 0, len(entry.results))
+	for _, result := range entry.results {
+		result = *result.DeepCopy()
+		result.Subjects = []corev1.ObjectReference{*entry.scope}
+		results = append(results, result)
+	}
+	return results
+}
+
+// resultsSize returns the size of the given results once encoded in JSON. A
+// result that cannot be encoded counts as maxSize.
+func resultsSize(results []openreports.ReportResult, maxSize int) int {
+	size := 0
+	for _, result := range results {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			size += maxSize
+			continue
+		}
+		size += len(encoded)
+	}
+	return size
+}
+
+func getAggregatedReportObjectMeta(runUID, namespace string, shard int) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      aggregatedReportName + "-" + strconv.Itoa(shard),
+		Namespace: namespace,
+		Labels: map[string]string{
+			labelAppManagedBy:                      labelApp,
+			labelPolicyReportVersion:               labelPolicyReportVersionValue,
+			labelAggregatedReport:                  valueTypeTrue,
+			auditConstants.AuditScannerRunUIDLabel: runUID,
+		},
+	}
+}
+
+// getAggregatedReportSummary counts the results of an aggregated report by status.
+func getAggregatedReportSummary(results []openreports.ReportResult) openreports.ReportSummary {
+	summary := openreports.ReportSummary{}
+	for _, result := range results {
+		switch result.Result {
+		case StatusFail:
+			summary.Fail++
+		case StatusError:
+			summary.Error++
+		case StatusPass:
+			summary.Pass++
+		case StatusWarn:
+			summary.Warn++
+		case StatusSkip:
+			summary.Skip++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,313 @@
+package report
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	testutils "github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newAggregatedTestResource(name, namespace string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetUID(types.UID(name + "-uid"))
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetAPIVersion("v1")
+	if namespace == "" {
+		resource.SetKind("Namespace")
+	} else {
+		resource.SetKind("Pod")
+	}
+	resource.SetResourceVersion("1")
+	return resource
+}
+
+func newAggregatedTestPolicy(name string) *policiesv1.ClusterAdmissionPolicy {
+	return &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:  types.UID(name + "-uid"),
+			Name: name,
+		},
+	}
+}
+
+func newAggregatedTestReport(runUID string, resource unstructured.Unstructured, allowed bool, policyNames ...string) *OpenReport {
+	openReport := NewOpenReport(runUID, resource)
+	for _, policyName := range policyNames {
+		openReport.AddResult(newAggregatedTestPolicy(policyName), &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed},
+		}, false, nil)
+	}
+	return openReport
+}
+
+func listAggregatedReports(t *testing.T, fakeClient client.Client, namespace string) []openreports.Report {
+	t.Helper()
+
+	reportList := &openreports.ReportList{}
+	err := fakeClient.List(t.Context(), reportList, client.InNamespace(namespace), client.MatchingLabels{labelAggregatedReport: valueTypeTrue})
+	require.NoError(t, err)
+	return reportList.Items
+}
+
+func TestAggregatedStoreDeleteOldReports(t *testing.T) {
+	oldReport := testutils.NewPolicyReportFactory().
+		Name("old-report").Namespace("default").RunUID("old-uid").WithAppLabel().BuildOpenReports()
+	fakeClient, err := testutils.NewFakeClient(oldReport)
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+
+	pod1 := newAggregatedTestResource("pod1", "default")
+	pod2 := newAggregatedTestResource("pod2", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("new-uid", pod2, false, "policy1")))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("new-uid", pod1, true, "policy1", "policy2")))
+	// the results buffered by another scan run are not written
+	pod3 := newAggregatedTestResource("pod3", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("other-uid", pod3, true, "policy1")))
+
+	// nothing is written until the scan of the namespace is completed
+	assert.Empty(t, listAggregatedReports(t, fakeClient, "default"))
+
+	err = store.DeleteOldReports(t.Context(), "new-uid", "default")
+	require.NoError(t, err)
+
+	reports := listAggregatedReports(t, fakeClient, "default")
+	require.Len(t, reports, 1)
+	aggregatedReport := reports[0]
+	assert.Equal(t, "kubewarden-audit-0", aggregatedReport.Name)
+	assert.Equal(t, "new-uid", aggregatedReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+	assert.Nil(t, aggregatedReport.Scope)
+	assert.Equal(t, 2, aggregatedReport.Summary.Pass)
+	assert.Equal(t, 1, aggregatedReport.Summary.Fail)
+	require.Len(t, aggregatedReport.Results, 3)
+	// the results are sorted by resource
+	for i, expectedResource := range []string{"pod1", "pod1", "pod2"} {
+		require.Len(t, aggregatedReport.Results[i].Subjects, 1)
+		assert.Equal(t, expectedResource, aggregatedReport.Results[i].Subjects[0].Name)
+		assert.Equal(t, "Pod", aggregatedReport.Results[i].Subjects[0].Kind)
+	}
+
+	// the per-resource report of the previous scan is deleted
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "old-report", Namespace: "default"}, &openreports.Report{})
+	require.Error(t, err)
+
+	// the results of the previous scan are returned by resource
+	previousReport, err := store.GetReport(t.Context(), pod1)
+	require.NoError(t, err)
+	assert.Len(t, previousReport.GetResults(), 2)
+	assert.Equal(t, pod1.GetUID(), previousReport.(*OpenReport).report.Scope.UID)
+	_, err = store.GetReport(t.Context(), pod3)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	// the resources not audited again by the next scan are stale
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("next-uid", pod1, true, "policy1")))
+	staleResources, err := store.(StaleLister).ListStaleResources(t.Context(), "next-uid", "default")
+	require.NoError(t, err)
+	require.Len(t, staleResources, 1)
+	assert.Equal(t, pod2.GetUID(), staleResources[0].UID)
+}
+
+func TestAggregatedStoreShards(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+	// a single result per shard
+	store.(*AggregatedOpenReportStore).maxReportSize = 1
+
+	pods := []unstructured.Unstructured{
+		newAggregatedTestResource("pod1", "default"),
+		newAggregatedTestResource("pod2", "default"),
+		newAggregatedTestResource("pod3", "default"),
+	}
+	for _, pod := range pods {
+		require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod, true, "policy")))
+	}
+	require.NoError(t, store.DeleteOldReports(t.Context(), "uid", "default"))
+
+	reports := listAggregatedReports(t, fakeClient, "default")
+	require.Len(t, reports, 3)
+	names := []string{}
+	for _, aggregatedReport := range reports {
+		names = append(names, aggregatedReport.Name)
+		assert.Len(t, aggregatedReport.Results, 1)
+		assert.Equal(t, 1, aggregatedReport.Summary.Pass)
+	}
+	assert.ElementsMatch(t, []string{"kubewarden-audit-0", "kubewarden-audit-1", "kubewarden-audit-2"}, names)
+
+	// the shards that are no longer needed are deleted
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("new-uid", pods[0], true, "policy")))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "new-uid", "default"))
+
+	reports = listAggregatedReports(t, fakeClient, "default")
+	require.Len(t, reports, 1)
+	assert.Equal(t, "kubewarden-audit-0", reports[0].Name)
+	assert.Equal(t, "pod1", reports[0].Results[0].Subjects[0].Name)
+}
+
+func TestAggregatedStoreFlushReports(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+
+	pod1 := newAggregatedTestResource("pod1", "default")
+	pod2 := newAggregatedTestResource("pod2", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod1, true, "policy")))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod2, true, "policy")))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "uid", "default"))
+
+	// a single resource is audited again, the results of the other ones are kept
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod1, false, "policy")))
+	require.NoError(t, store.FlushReports(t.Context(), "uid", "default"))
+
+	reports := listAggregatedReports(t, fakeClient, "default")
+	require.Len(t, reports, 1)
+	require.Len(t, reports[0].Results, 2)
+	assert.Equal(t, "pod1", reports[0].Results[0].Subjects[0].Name)
+	assert.Equal(t, openreports.Result(StatusFail), reports[0].Results[0].Result)
+	assert.Equal(t, "pod2", reports[0].Results[1].Subjects[0].Name)
+	assert.Equal(t, openreports.Result(StatusPass), reports[0].Results[1].Result)
+	assert.Equal(t, 1, reports[0].Summary.Pass)
+	assert.Equal(t, 1, reports[0].Summary.Fail)
+}
+
+func TestAggregatedStoreFlushReportsShards(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+	aggregatedStore := store.(*AggregatedOpenReportStore)
+	// a single result per shard
+	aggregatedStore.maxReportSize = 1
+
+	pods := []unstructured.Unstructured{
+		newAggregatedTestResource("pod1", "default"),
+		newAggregatedTestResource("pod2", "default"),
+		newAggregatedTestResource("pod3", "default"),
+	}
+	for _, pod := range pods {
+		require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod, true, "policy")))
+	}
+	require.NoError(t, store.DeleteOldReports(t.Context(), "uid", "default"))
+
+	shardRunUIDs := func() map[string]string {
+		runUIDs := map[string]string{}
+		for _, aggregatedReport := range listAggregatedReports(t, fakeClient, "default") {
+			runUIDs[aggregatedReport.Name] = aggregatedReport.Labels[auditConstants.AuditScannerRunUIDLabel]
+		}
+		return runUIDs
+	}
+
+	// only the shard holding the flushed resource is rewritten, and the
+	// flushed results are no longer buffered
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("watch-uid", pods[1], false, "policy")))
+	require.NoError(t, store.FlushReports(t.Context(), "watch-uid", "default"))
+	assert.Equal(t, map[string]string{
+		"kubewarden-audit-0": "uid",
+		"kubewarden-audit-1": "watch-uid",
+		"kubewarden-audit-2": "uid",
+	}, shardRunUIDs())
+	assert.Empty(t, aggregatedStore.buffered["default"])
+
+	// a new resource goes to a new shard when the last one is full
+	pod4 := newAggregatedTestResource("pod4", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("watch-uid", pod4, true, "policy")))
+	require.NoError(t, store.FlushReports(t.Context(), "watch-uid", "default"))
+	assert.Equal(t, map[string]string{
+		"kubewarden-audit-0": "uid",
+		"kubewarden-audit-1": "watch-uid",
+		"kubewarden-audit-2": "uid",
+		"kubewarden-audit-3": "watch-uid",
+	}, shardRunUIDs())
+
+	// the results flushed while a full scan is running are kept when it completes
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("next-uid", pods[0], true, "policy")))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("next-uid", pods[2], false, "policy")))
+	require.NoError(t, store.FlushReports(t.Context(), "next-uid", "default"))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("next-uid", pods[1], true, "policy")))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "next-uid", "default"))
+
+	reports := listAggregatedReports(t, fakeClient, "default")
+	require.Len(t, reports, 3)
+	results := map[string]openreports.Result{}
+	for _, aggregatedReport := range reports {
+		assert.Equal(t, "next-uid", aggregatedReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+		for _, result := range aggregatedReport.Results {
+			results[result.Subjects[0].Name] = result.Result
+		}
+	}
+	assert.Equal(t, map[string]openreports.Result{
+		"pod1": openreports.Result(StatusPass),
+		"pod2": openreports.Result(StatusPass),
+		"pod3": openreports.Result(StatusFail),
+	}, results)
+}
+
+func TestAggregatedStoreClusterReports(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+
+	namespace1 := newAggregatedTestResource("namespace1", "")
+	namespace2 := newAggregatedTestResource("namespace2", "")
+	for _, namespace := range []unstructured.Unstructured{namespace1, namespace2} {
+		clusterReport := NewClusterOpenReport("uid", namespace)
+		clusterReport.AddResult(newAggregatedTestPolicy("policy"), &admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: true},
+		}, false, nil)
+		require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), clusterReport))
+	}
+	require.NoError(t, store.DeleteOldClusterReports(t.Context(), "uid"))
+
+	clusterReport := &openreports.ClusterReport{}
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-audit-0"}, clusterReport)
+	require.NoError(t, err)
+	assert.Equal(t, 2, clusterReport.Summary.Pass)
+	require.Len(t, clusterReport.Results, 2)
+	assert.Equal(t, "namespace1", clusterReport.Results[0].Subjects[0].Name)
+	assert.Equal(t, "namespace2", clusterReport.Results[1].Subjects[0].Name)
+
+	previousReport, err := store.GetClusterReport(t.Context(), namespace2)
+	require.NoError(t, err)
+	assert.Len(t, previousReport.GetResults(), 1)
+
+	previousReports, err := store.(ReportLister).ListClusterReports(t.Context())
+	require.NoError(t, err)
+	require.Len(t, previousReports, 2)
+	assert.Equal(t, namespace1.GetUID(), previousReports[namespace1.GetUID()].GetScope().UID)
+}
+
+func TestAggregatedStoreWritesNamespacesInParallel(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewAggregatedOpenReportStore(fakeClient, slog.Default())
+	aggregatedStore := store.(*AggregatedOpenReportStore)
+
+	// the reports of another namespace are being written
+	unlock := aggregatedStore.lockNamespace("other")
+	defer unlock()
+
+	pod := newAggregatedTestResource("pod", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod, true, "policy")))
+	done := make(chan error)
+	go func() {
+		done <- store.FlushReports(t.Context(), "uid", "default")
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "the reports of the namespace are not written while another namespace is locked")
+	}
+	assert.Len(t, listAggregatedReports(t, fakeClient, "default"), 1)
+}
@@ -8,22 +8,31 @@ const (
 	propertyPolicyNamespace       = "policy-namespace"
 )
 
+const (
+	// PropertyEvaluationAttempts is the result property holding the number of
+	// requests sent to the PolicyServer to evaluate the policy.
+	PropertyEvaluationAttempts = "evaluation-attempts"
+	// PropertyOperation is the result property holding the operation of the
+	// admission request simulated to evaluate the policy.
+	PropertyOperation = "operation"
+)
+
 const (
 	// Status specifies state of a policy result.
-	statusPass  = "pass"
-	statusFail  = "fail"
-	statusWarn  = "warn"
-	statusError = "error"
-	statusSkip  = "skip"
+	StatusPass  = "pass"
+	StatusFail  = "fail"
+	StatusWarn  = "warn"
+	StatusError = "error"
+	StatusSkip  = "skip"
 )
 
 const (
 	// Severity specifies severity of a policy result.
-	severityCritical = "critical"
-	severityHigh     = "high"
-	severityMedium   = "medium"
-	severityLow      = "low"
-	severityInfo     = "info"
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
 )
 
 const (
@@ -39,9 +48,18 @@ const (
 	labelApp                      = "kubewarden"
 	labelPolicyReportVersion      = "kubewarden.io/policyreport-version"
 	labelPolicyReportVersionValue = "v2"
+	labelAggregatedReport         = "kubewarden.io/aggregated-report"
 )
 
 const (
 	OpenReportsKind  = "openreports"
 	PolicyReportKind = "policyreport"
 )
+
+const (
+	// AggregationResource writes one report per audited resource.
+	AggregationResource = "resource"
+	// AggregationNamespace writes one report per namespace, and one cluster
+	// report for the cluster-wide resources.
+	AggregationNamespace = "namespace"
+)
@@ -0,0 +1,431 @@
+package report
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+	"sigs.k8s.io/yaml"
+)
+
+// Formats of the files written by the FileStore.
+const (
+	FileFormatYAML = "yaml"
+	FileFormatJSON = "json"
+)
+
+const (
+	// fileIndexName is the name of the index file, without extension
+	fileIndexName = "index"
+	// clusterReportsDir is the directory of the reports of the cluster-wide
+	// resources. It can't clash with a namespace, since namespace names can't
+	// start with an underscore.
+	clusterReportsDir = "_cluster"
+	fileMode          = 0o600
+	dirMode           = 0o750
+)
+
+// FileStore is a store writing each report as a file, in a directory tree laid
+// out by namespace, kind and name of the audited resource:
+//
+//	<dir>/<namespace>/<Kind>[.<group>]/<name>.<format>
+//	<dir>/_cluster/<Kind>[.<group>]/<name>.<format>
+//
+// An index file at the root of the directory lists all the reports. The
+// reports are written as soon as they are created or patched, the index when
+// the scan of a namespace, or of the cluster-wide resources, is completed.
+// It's meant for air-gapped audits and for archiving the results, it works
+// with any kind of report.
+type FileStore struct {
+	// dir is the root directory of the reports
+	dir string
+	// format is the format of the files, either yaml or json
+	format string
+	// logger is used to log the messages
+	logger *slog.Logger
+	// mutex protects index
+	mutex sync.Mutex
+	// indexMutex serializes the writes of the index file
+	indexMutex sync.Mutex
+	// index holds the stored reports by path, relative to dir
+	index map[string]fileIndexEntry
+}
+
+// fileIndexEntry describes a report listed by the index file.
+type fileIndexEntry struct {
+	// Path is the path of the report file, relative to the index file
+	Path       string `json:"path"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	RunUID     string `json:"runUID"`
+	// Resource is the audited resource
+	Resource corev1.ObjectReference `json:"resource"`
+	Summary  Summary                `json:"summary"`
+}
+
+// fileIndex is the content of the index file.
+type fileIndex struct {
+	Reports []fileIndexEntry `json:"reports"`
+}
+
+// NewFileStore creates a new FileStore writing the reports to the given
+// directory, in the given format. The reports already found in the directory,
+// written by the previous scans, are added to the index.
+func NewFileStore(dir, format string, logger *slog.Logger) (*FileStore, error) {
+	if format != FileFormatYAML && format != FileFormatJSON {
+		return nil, fmt.Errorf("invalid report file format '%s': supported values are '%s' and '%s'", format, FileFormatYAML, FileFormatJSON)
+	}
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return nil, fmt.Errorf("failed to create the report directory %s: %w", dir, err)
+	}
+
+	store := &FileStore{
+		dir:    dir,
+		format: format,
+		logger: logger.With("component", "filestore"),
+		index:  map[string]fileIndexEntry{},
+	}
+	if err := store.loadIndex(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// GetReport returns the report file of the given namespaced resource.
+func (s *FileStore) GetReport(_ context.Context, resource unstructured.Unstructured) (Report, error) {
+	return s.read(resource)
+}
+
+// CreateOrPatchReport writes the file of the given report.
+func (s *FileStore) CreateOrPatchReport(ctx context.Context, obj any) error {
+	return s.write(ctx, obj)
+}
+
+// FlushReports writes the index, the reports are written as soon as they are
+// created or patched.
+func (s *FileStore) FlushReports(_ context.Context, _, _ string) error {
+	return s.writeIndex()
+}
+
+// DeleteOldReports deletes the report files of the namespace that do not
+// belong to the given scan run, and writes the index.
+func (s *FileStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.deleteOld(ctx, scanRunID, namespace)
+}
+
+// GetClusterReport returns the report file of the given cluster-wide resource.
+func (s *FileStore) GetClusterReport(_ context.Context, resource unstructured.Unstructured) (Report, error) {
+	return s.read(resource)
+}
+
+// CreateOrPatchClusterReport writes the file of the given cluster report.
+func (s *FileStore) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
+	return s.write(ctx, obj)
+}
+
+// FlushClusterReports writes the index, like FlushReports.
+func (s *FileStore) FlushClusterReports(_ context.Context, _ string) error {
+	return s.writeIndex()
+}
+
+// DeleteOldClusterReports deletes the report files of the cluster-wide
+// resources that do not belong to the given scan run, and writes the index.
+func (s *FileStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	return s.deleteOld(ctx, scanRunID, "")
+}
+
+func (s *FileStore) read(resource unstructured.Unstructured) (Report, error) {
+	path := s.reportPath(&corev1.ObjectReference{
+		APIVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Namespace:  resource.GetNamespace(),
+		Name:       resource.GetName(),
+	})
+
+	s.mutex.Lock()
+	entry, found := s.index[path]
+	s.mutex.Unlock()
+	// a resource with the same name may have been recreated since the report was written
+	if !found || entry.Resource.UID != resource.GetUID() {
+		return nil, fmt.Errorf("%w: report file %s", auditConstants.ErrResourceNotFound, path)
+	}
+
+	auditReport, _, err := s.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return auditReport, nil
+}
+
+func (s *FileStore) write(ctx context.Context, obj any) error {
+	auditReport, ok := obj.(Report)
+	if !ok {
+		return fmt.Errorf("expected Report, got %T", obj)
+	}
+	scope := auditReport.GetScope()
+	if scope == nil {
+		return fmt.Errorf("cannot write report without scope %T", obj)
+	}
+
+	data, err := json.Marshal(auditReport)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the report of %s/%s: %w", scope.Namespace, scope.Name, err)
+	}
+	metadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return fmt.Errorf("failed to read the metadata of the report of %s/%s: %w", scope.Namespace, scope.Name, err)
+	}
+	path := s.reportPath(scope)
+	if err := s.writeFile(path, data); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.index[path] = newFileIndexEntry(path, metadata, *scope, auditReport.GetSummary())
+	s.mutex.Unlock()
+
+	s.logger.DebugContext(ctx, fmt.Sprintf("%s written", metadata.Kind),
+		slog.String("path", path),
+		slog.String("resource-name", scope.Name),
+		slog.String("resource-namespace", scope.Namespace),
+		slog.String("resource-version", scope.ResourceVersion))
+	return nil
+}
+
+// ListStaleResources returns the resources of the report files of the namespace
+// written by the previous scan runs.
+func (s *FileStore) ListStaleResources(_ context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	return s.listStale(scanRunID, namespace), nil
+}
+
+// ListStaleClusterResources is like ListStaleResources, for the cluster report files.
+func (s *FileStore) ListStaleClusterResources(_ context.Context, scanRunID string) ([]corev1.ObjectReference, error) {
+	return s.listStale(scanRunID, ""), nil
+}
+
+func (s *FileStore) listStale(scanRunID, namespace string) []corev1.ObjectReference {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var resources []corev1.ObjectReference
+	for _, entry := range s.index {
+		if entry.Resource.Namespace == namespace && entry.RunUID != scanRunID {
+			resources = append(resources, entry.Resource)
+		}
+	}
+	slices.SortFunc(resources, func(a, b corev1.ObjectReference) int {
+		return cmp.Compare(a.UID, b.UID)
+	})
+	return resources
+}
+
+func (s *FileStore) deleteOld(ctx context.Context, scanRunID, namespace string) error {
+	s.mutex.Lock()
+	var oldPaths []string
+	for path, entry := range s.index {
+		if entry.Resource.Namespace == namespace && entry.RunUID != scanRunID {
+			oldPaths = append(oldPaths, path)
+			delete(s.index, path)
+		}
+	}
+	s.mutex.Unlock()
+
+	s.logger.DebugContext(ctx, "Deleting old report files",
+		slog.String("namespace", namespace),
+		slog.Int("count", len(oldPaths)))
+	var errs []error
+	for _, path := range oldPaths {
+		if err := os.Remove(filepath.Join(s.dir, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to delete report file %s: %w", path, err))
+		}
+	}
+	if err := s.writeIndex(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// reportPath returns the path of the report file of the given resource,
+// relative to the root directory.
+func (s *FileStore) reportPath(resource *corev1.ObjectReference) string {
+	namespace := resource.Namespace
+	if namespace == "" {
+		namespace = clusterReportsDir
+	}
+	// the group tells apart the kinds with the same name, like Event and Event.events.k8s.io
+	kind := resource.Kind
+	if group := schema.FromAPIVersionAndKind(resource.APIVersion, resource.Kind).Group; group != "" {
+		kind += "." + group
+	}
+	return filepath.Join(namespace, kind, resource.Name+"."+s.format)
+}
+
+// writeFile writes the given JSON content to the file at the given path,
+// relative to the root directory, in the format of the store. The file is
+// replaced atomically, so it's never read half written.
+func (s *FileStore) writeFile(path string, data []byte) error {
+	if s.format == FileFormatYAML {
+		var err error
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s to YAML: %w", path, err)
+		}
+	}
+
+	fullPath := filepath.Join(s.dir, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), dirMode); err != nil {
+		return fmt.Errorf("failed to create the directory of %s: %w", path, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(file.Name()) //nolint:errcheck // the file doesn't exist anymore once renamed
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(file.Name(), fileMode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(file.Name(), fullPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// readFile reads the report file at the given path, relative to the root
+// directory. It returns the report and its metadata.
+func (s *FileStore) readFile(path string) (Report, *metav1.PartialObjectMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read report file %s: %w", path, err)
+	}
+	if s.format == FileFormatYAML {
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert report file %s to JSON: %w", path, err)
+		}
+	}
+	metadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, nil, fmt.Errorf("failed to read the metadata of report file %s: %w", path, err)
+	}
+
+	var auditReport Report
+	switch metadata.Kind {
+	case "PolicyReport":
+		policyReport := &wgpolicy.PolicyReport{}
+		err = json.Unmarshal(data, policyReport)
+		auditReport = &PolicyReport{report: policyReport}
+	case "ClusterPolicyReport":
+		clusterPolicyReport := &wgpolicy.ClusterPolicyReport{}
+		err = json.Unmarshal(data, clusterPolicyReport)
+		auditReport = &ClusterPolicyReport{report: clusterPolicyReport}
+	case "Report":
+		openReport := &openreports.Report{}
+		err = json.Unmarshal(data, openReport)
+		auditReport = &OpenReport{report: openReport}
+	case "ClusterReport":
+		clusterReport := &openreports.ClusterReport{}
+		err = json.Unmarshal(data, clusterReport)
+		auditReport = &OpenClusterReport{report: clusterReport}
+	default:
+		return nil, nil, fmt.Errorf("unknown kind '%s' of report file %s", metadata.Kind, path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read report file %s: %w", path, err)
+	}
+	return auditReport, metadata, nil
+}
+
+// loadIndex adds the report files found in the root directory to the index.
+// The files that cannot be read are skipped.
+func (s *FileStore) loadIndex() error {
+	indexPath := fileIndexName + "." + s.format
+	err := filepath.WalkDir(s.dir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(fullPath) != "."+s.format {
+			return nil
+		}
+		path, err := filepath.Rel(s.dir, fullPath)
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		if path == indexPath {
+			return nil
+		}
+
+		auditReport, metadata, err := s.readFile(path)
+		if err != nil {
+			s.logger.Warn("cannot read report file, skipping...", slog.String("error", err.Error()))
+			return nil
+		}
+		if scope := auditReport.GetScope(); scope != nil {
+			s.index[path] = newFileIndexEntry(path, metadata, *scope, auditReport.GetSummary())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the report directory %s: %w", s.dir, err)
+	}
+	return nil
+}
+
+// writeIndex writes the index file, listing the reports sorted by path.
+func (s *FileStore) writeIndex() error {
+	// the index is written by a single goroutine at a time, so an older
+	// version never replaces a newer one
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+
+	s.mutex.Lock()
+	index := fileIndex{Reports: slices.Collect(maps.Values(s.index))}
+	s.mutex.Unlock()
+	slices.SortFunc(index.Reports, func(a, b fileIndexEntry) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the report index: %w", err)
+	}
+	return s.writeFile(fileIndexName+"."+s.format, data)
+}
+
+func newFileIndexEntry(path string, metadata *metav1.PartialObjectMetadata, resource corev1.ObjectReference, summary Summary) fileIndexEntry {
+	return fileIndexEntry{
+		Path:       filepath.ToSlash(path),
+		APIVersion: metadata.APIVersion,
+		Kind:       metadata.Kind,
+		Name:       metadata.Name,
+		Namespace:  metadata.Namespace,
+		RunUID:     metadata.Labels[auditConstants.AuditScannerRunUIDLabel],
+		Resource:   resource,
+		Summary:    summary,
+	}
+}
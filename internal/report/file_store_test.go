@@ -0,0 +1,140 @@
+package report
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+	"sigs.k8s.io/yaml"
+)
+
+func newFileStoreTestResource(apiVersion, kind, name, namespace string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion(apiVersion)
+	resource.SetKind(kind)
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetUID(types.UID(name + "-uid"))
+	resource.SetResourceVersion("1")
+	return resource
+}
+
+func readFileIndex(t *testing.T, path string) fileIndex {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var index fileIndex
+	require.NoError(t, yaml.Unmarshal(data, &index))
+	return index
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, FileFormatYAML, slog.Default())
+	require.NoError(t, err)
+
+	pod := newFileStoreTestResource("v1", "Pod", "pod", "default")
+	deployment := newFileStoreTestResource("apps/v1", "Deployment", "deployment", "default")
+	namespace := newFileStoreTestResource("v1", "Namespace", "default", "")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newAggregatedTestReport("uid", pod, false, "policy")))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewPolicyReport("uid", deployment)))
+	clusterReport := NewClusterPolicyReport("uid", namespace)
+	require.NoError(t, store.CreateOrPatchClusterReport(t.Context(), clusterReport))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "uid", "default"))
+	require.NoError(t, store.DeleteOldClusterReports(t.Context(), "uid"))
+
+	// the reports are laid out by namespace, kind and name
+	data, err := os.ReadFile(filepath.Join(dir, "default", "Pod", "pod.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "kind: Report\n")
+	data, err = os.ReadFile(filepath.Join(dir, "default", "Deployment.apps", "deployment.yaml"))
+	require.NoError(t, err)
+	policyReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, yaml.Unmarshal(data, policyReport))
+	assert.Equal(t, "PolicyReport", policyReport.Kind)
+	assert.Equal(t, "deployment", policyReport.Scope.Name)
+	require.FileExists(t, filepath.Join(dir, clusterReportsDir, "Namespace", "default.yaml"))
+
+	index := readFileIndex(t, filepath.Join(dir, "index.yaml"))
+	require.Len(t, index.Reports, 3)
+	assert.Equal(t, "_cluster/Namespace/default.yaml", index.Reports[0].Path)
+	assert.Equal(t, "ClusterPolicyReport", index.Reports[0].Kind)
+	assert.Equal(t, "default/Deployment.apps/deployment.yaml", index.Reports[1].Path)
+	assert.Equal(t, "default/Pod/pod.yaml", index.Reports[2].Path)
+	assert.Equal(t, "Report", index.Reports[2].Kind)
+	assert.Equal(t, "uid", index.Reports[2].RunUID)
+	assert.Equal(t, types.UID("pod-uid"), index.Reports[2].Resource.UID)
+	assert.Equal(t, Summary{Fail: 1}, index.Reports[2].Summary)
+
+	// the reports are read back
+	previousReport, err := store.GetReport(t.Context(), pod)
+	require.NoError(t, err)
+	assert.IsType(t, &OpenReport{}, previousReport)
+	assert.Len(t, previousReport.GetResults(), 1)
+	previousReport, err = store.GetClusterReport(t.Context(), namespace)
+	require.NoError(t, err)
+	assert.Equal(t, namespace.GetUID(), previousReport.GetScope().UID)
+
+	// a resource recreated with the same name has no report
+	recreatedPod := newFileStoreTestResource("v1", "Pod", "pod", "default")
+	recreatedPod.SetUID("new-uid")
+	_, err = store.GetReport(t.Context(), recreatedPod)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+}
+
+func TestFileStoreDeleteOldReports(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, FileFormatJSON, slog.Default())
+	require.NoError(t, err)
+
+	pod1 := newFileStoreTestResource("v1", "Pod", "pod1", "default")
+	pod2 := newFileStoreTestResource("v1", "Pod", "pod2", "default")
+	otherPod := newFileStoreTestResource("v1", "Pod", "pod", "other")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewOpenReport("old-uid", pod1)))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewOpenReport("old-uid", pod2)))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewOpenReport("old-uid", otherPod)))
+	require.NoError(t, store.FlushReports(t.Context(), "old-uid", "default"))
+
+	// the reports written by a previous scan are loaded
+	store, err = NewFileStore(dir, FileFormatJSON, slog.Default())
+	require.NoError(t, err)
+	_, err = store.GetReport(t.Context(), pod2)
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewOpenReport("new-uid", pod1)))
+	// the resources that have not been audited again are stale
+	staleResources, err := store.ListStaleResources(t.Context(), "new-uid", "default")
+	require.NoError(t, err)
+	require.Len(t, staleResources, 1)
+	assert.Equal(t, pod2.GetUID(), staleResources[0].UID)
+	require.NoError(t, store.DeleteOldReports(t.Context(), "new-uid", "default"))
+
+	require.FileExists(t, filepath.Join(dir, "default", "Pod", "pod1.json"))
+	require.NoFileExists(t, filepath.Join(dir, "default", "Pod", "pod2.json"))
+	// the reports of the other namespaces are kept
+	require.FileExists(t, filepath.Join(dir, "other", "Pod", "pod.json"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	var index fileIndex
+	require.NoError(t, json.Unmarshal(data, &index))
+	require.Len(t, index.Reports, 2)
+	assert.Equal(t, "default/Pod/pod1.json", index.Reports[0].Path)
+	assert.Equal(t, "new-uid", index.Reports[0].RunUID)
+	assert.Equal(t, "other/Pod/pod.json", index.Reports[1].Path)
+	assert.Equal(t, "old-uid", index.Reports[1].RunUID)
+}
+
+func TestNewFileStoreWithInvalidFormat(t *testing.T) {
+	_, err := NewFileStore(t.TempDir(), "xml", slog.Default())
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MultiStore writes the reports to several stores, like the cluster and a
+// directory. The reports are read from the first store, the primary one.
+// A failure of a store doesn't prevent the other ones from being written, the
+// errors of all the stores are returned.
+type MultiStore struct {
+	stores []Store
+}
+
+// NewMultiStore creates a new MultiStore reading the reports from the
+// primary store, and writing them to all the stores.
+func NewMultiStore(primary Store, others ...Store) *MultiStore {
+	return &MultiStore{stores: append([]Store{primary}, others...)}
+}
+
+// GetReport returns the report of the given resource stored by the primary store.
+//
+//nolint:wrapcheck // the stores already wrap the errors with context
+func (s *MultiStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	return s.stores[0].GetReport(ctx, resource)
+}
+
+// CreateOrPatchReport creates or patches the report in all the stores.
+func (s *MultiStore) CreateOrPatchReport(ctx context.Context, report any) error {
+	return s.forEach(func(store Store) error {
+		return store.CreateOrPatchReport(ctx, report)
+	})
+}
+
+// FlushReports flushes the reports of the namespace in all the stores.
+func (s *MultiStore) FlushReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.forEach(func(store Store) error {
+		return store.FlushReports(ctx, scanRunID, namespace)
+	})
+}
+
+// DeleteOldReports deletes the old reports of the namespace from all the stores.
+func (s *MultiStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.forEach(func(store Store) error {
+		return store.DeleteOldReports(ctx, scanRunID, namespace)
+	})
+}
+
+// GetClusterReport returns the report of the given cluster-wide resource
+// stored by the primary store.
+//
+//nolint:wrapcheck // the stores already wrap the errors with context
+func (s *MultiStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	return s.stores[0].GetClusterReport(ctx, resource)
+}
+
+// CreateOrPatchClusterReport creates or patches the cluster report in all the stores.
+func (s *MultiStore) CreateOrPatchClusterReport(ctx context.Context, report any) error {
+	return s.forEach(func(store Store) error {
+		return store.CreateOrPatchClusterReport(ctx, report)
+	})
+}
+
+// FlushClusterReports flushes the cluster reports in all the stores.
+func (s *MultiStore) FlushClusterReports(ctx context.Context, scanRunID string) error {
+	return s.forEach(func(store Store) error {
+		return store.FlushClusterReports(ctx, scanRunID)
+	})
+}
+
+// DeleteOldClusterReports deletes the old cluster reports from all the stores.
+func (s *MultiStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	return s.forEach(func(store Store) error {
+		return store.DeleteOldClusterReports(ctx, scanRunID)
+	})
+}
+
+// ListStaleResources returns the stale resources of the namespace listed by
+// the primary store. It returns an error wrapping errors.ErrUnsupported when
+// the primary store cannot list them.
+func (s *MultiStore) ListStaleResources(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	lister, ok := s.stores[0].(StaleLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the primary store cannot list the stale reports", errors.ErrUnsupported)
+	}
+	return lister.ListStaleResources(ctx, scanRunID, namespace) //nolint:wrapcheck // the stores already wrap the errors with context
+}
+
+// ListStaleClusterResources is like ListStaleResources, for the cluster reports.
+func (s *MultiStore) ListStaleClusterResources(ctx context.Context, scanRunID string) ([]corev1.ObjectReference, error) {
+	lister, ok := s.stores[0].(StaleLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the primary store cannot list the stale cluster reports", errors.ErrUnsupported)
+	}
+	return lister.ListStaleClusterResources(ctx, scanRunID) //nolint:wrapcheck // the stores already wrap the errors with context
+}
+
+// ListReports returns the reports of the namespace listed by the primary
+// store. It returns an error wrapping errors.ErrUnsupported when the primary
+// store cannot list them.
+func (s *MultiStore) ListReports(ctx context.Context, namespace string) (map[types.UID]Report, error) {
+	lister, ok := s.stores[0].(ReportLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the primary store cannot list the reports", errors.ErrUnsupported)
+	}
+	return lister.ListReports(ctx, namespace) //nolint:wrapcheck // the stores already wrap the errors with context
+}
+
+// ListClusterReports is like ListReports, for the cluster reports.
+func (s *MultiStore) ListClusterReports(ctx context.Context) (map[types.UID]Report, error) {
+	lister, ok := s.stores[0].(ReportLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the primary store cannot list the cluster reports", errors.ErrUnsupported)
+	}
+	return lister.ListClusterReports(ctx) //nolint:wrapcheck // the stores already wrap the errors with context
+}
+
+func (s *MultiStore) forEach(operation func(store Store) error) error {
+	var errs []error
+	for _, store := range s.stores {
+		if err := operation(store); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	testutils "github.com/kubewarden/audit-scanner/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+// nonListingStore hides the StaleLister and ReportLister implementations of the store.
+type nonListingStore struct {
+	Store
+}
+
+func TestMultiStore(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	fileStore, err := NewFileStore(dir, FileFormatYAML, slog.Default())
+	require.NoError(t, err)
+	store := NewMultiStore(NewPolicyReportStore(fakeClient, slog.Default()), fileStore)
+
+	pod := newFileStoreTestResource("v1", "Pod", "pod", "default")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), NewPolicyReport("uid", pod)))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "uid", "default"))
+
+	// the report is written to all the stores
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "default"}, &wgpolicy.PolicyReport{})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, "default", "Pod", "pod.yaml"))
+	require.FileExists(t, filepath.Join(dir, "index.yaml"))
+
+	// the stale resources are listed by the primary store
+	staleResources, err := store.ListStaleResources(t.Context(), "new-uid", "default")
+	require.NoError(t, err)
+	require.Len(t, staleResources, 1)
+	assert.Equal(t, pod.GetUID(), staleResources[0].UID)
+	staleResources, err = store.ListStaleResources(t.Context(), "uid", "default")
+	require.NoError(t, err)
+	assert.Empty(t, staleResources)
+	_, err = NewMultiStore(nonListingStore{fileStore}).ListStaleResources(t.Context(), "uid", "default")
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	// the reports are listed by the primary store
+	reports, err := store.ListReports(t.Context(), "default")
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, pod.GetUID(), reports[pod.GetUID()].GetScope().UID)
+	_, err = NewMultiStore(nonListingStore{fileStore}).ListReports(t.Context(), "default")
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	// the report is read from the primary store
+	previousReport, err := store.GetReport(t.Context(), pod)
+	require.NoError(t, err)
+	assert.Equal(t, pod.GetUID(), previousReport.GetScope().UID)
+	require.NoError(t, fakeClient.DeleteAllOf(t.Context(), &wgpolicy.PolicyReport{}))
+	_, err = store.GetReport(t.Context(), pod)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	// the errors of the stores are returned
+	require.Error(t, store.CreateOrPatchReport(t.Context(), "not a report"))
+}
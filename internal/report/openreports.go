@@ -1,11 +1,14 @@
 package report
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -41,15 +44,51 @@ func (r *OpenReport) AddResult(
 	policy policiesv1.Policy,
 	admissionReview *admissionv1.AdmissionReview,
 	errored bool,
+	properties map[string]string,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
+	result := newReportResult(policy, admissionReview, errored, properties, now)
+	r.appendResult(result)
+}
+
+func (r *OpenReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*OpenReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if !canReuseResult(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			return false
+		}
+		r.appendResult(*result.DeepCopy())
+		return true
+	}
+	return false
+}
+
+func (r *OpenReport) MergeResults(previous Report, audited func(policy string) bool) {
+	previousReport, ok := previous.(*OpenReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if audited(result.Policy) {
+			continue
+		}
+		r.appendResult(*result.DeepCopy())
+	}
+}
+
+func (r *OpenReport) appendResult(result openreports.ReportResult) {
 	switch result.Result {
-	case statusFail:
+	case StatusFail:
 		r.report.Summary.Fail++
-	case statusError:
+	case StatusError:
 		r.report.Summary.Error++
-	case statusPass:
+	case StatusPass:
 		r.report.Summary.Pass++
 	}
 	r.report.Results = append(r.report.Results, result)
@@ -63,19 +102,84 @@ func (r *OpenReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
 
+func (r *OpenReport) GetResults() []Result {
+	return newResultsFromOpenReport(r.report.Results)
+}
+
+func (r *OpenReport) GetScope() *corev1.ObjectReference {
+	return r.report.Scope
+}
+
+// MarshalJSON marshals the underlying Report resource, with its apiVersion and kind.
+func (r *OpenReport) MarshalJSON() ([]byte, error) {
+	resource := r.report.DeepCopy()
+	resource.SetGroupVersionKind(openreports.SchemeGroupVersion.WithKind("Report"))
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Report %s: %w", resource.GetName(), err)
+	}
+	return data, nil
+}
+
+func (r *OpenReport) GetSummary() Summary {
+	return Summary{
+		Pass:  r.report.Summary.Pass,
+		Fail:  r.report.Summary.Fail,
+		Warn:  r.report.Summary.Warn,
+		Error: r.report.Summary.Error,
+		Skip:  r.report.Summary.Skip,
+	}
+}
+
 func (r *OpenClusterReport) AddResult(
 	policy policiesv1.Policy,
 	admissionReview *admissionv1.AdmissionReview,
 	errored bool,
+	properties map[string]string,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
+	result := newReportResult(policy, admissionReview, errored, properties, now)
+	r.appendResult(result)
+}
+
+func (r *OpenClusterReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*OpenClusterReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if !canReuseResult(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			return false
+		}
+		r.appendResult(*result.DeepCopy())
+		return true
+	}
+	return false
+}
+
+func (r *OpenClusterReport) MergeResults(previous Report, audited func(policy string) bool) {
+	previousReport, ok := previous.(*OpenClusterReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if audited(result.Policy) {
+			continue
+		}
+		r.appendResult(*result.DeepCopy())
+	}
+}
+
+func (r *OpenClusterReport) appendResult(result openreports.ReportResult) {
 	switch result.Result {
-	case statusFail:
+	case StatusFail:
 		r.report.Summary.Fail++
-	case statusError:
+	case StatusError:
 		r.report.Summary.Error++
-	case statusPass:
+	case StatusPass:
 		r.report.Summary.Pass++
 	}
 	r.report.Results = append(r.report.Results, result)
@@ -89,6 +193,35 @@ func (r *OpenClusterReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
 
+func (r *OpenClusterReport) GetResults() []Result {
+	return newResultsFromOpenReport(r.report.Results)
+}
+
+func (r *OpenClusterReport) GetScope() *corev1.ObjectReference {
+	return r.report.Scope
+}
+
+// MarshalJSON marshals the underlying ClusterReport resource, with its apiVersion and kind.
+func (r *OpenClusterReport) MarshalJSON() ([]byte, error) {
+	resource := r.report.DeepCopy()
+	resource.SetGroupVersionKind(openreports.SchemeGroupVersion.WithKind("ClusterReport"))
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ClusterReport %s: %w", resource.GetName(), err)
+	}
+	return data, nil
+}
+
+func (r *OpenClusterReport) GetSummary() Summary {
+	return Summary{
+		Pass:  r.report.Summary.Pass,
+		Fail:  r.report.Summary.Fail,
+		Warn:  r.report.Summary.Warn,
+		Error: r.report.Summary.Error,
+		Skip:  r.report.Summary.Skip,
+	}
+}
+
 // NewClusterOpenReport creates a new ClusterPolicyReport from a given resource.
 func NewClusterOpenReport(runUID string, resource unstructured.Unstructured) *OpenClusterReport {
 	return &OpenClusterReport{
@@ -106,7 +239,7 @@ func NewClusterOpenReport(runUID string, resource unstructured.Unstructured) *Op
 	}
 }
 
-func newReportResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, timestamp metav1.Timestamp) openreports.ReportResult {
+func newReportResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, properties map[string]string, timestamp metav1.Timestamp) openreports.ReportResult {
 	category, message := getCategoryAndMessage(policy, admissionReview)
 
 	return openreports.ReportResult{
@@ -120,6 +253,21 @@ func newReportResult(policy policiesv1.Policy, admissionReview *admissionv1.Admi
 		ResourceSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, properties),
+	}
+}
+
+func newResultsFromOpenReport(reportResults []openreports.ReportResult) []Result {
+	results := make([]Result, 0, len(reportResults))
+	for _, result := range reportResults {
+		results = append(results, Result{
+			Policy:     result.Policy,
+			Status:     string(result.Result),
+			Severity:   string(result.Severity),
+			Category:   result.Category,
+			Message:    result.Description,
+			Properties: result.Properties,
+		})
 	}
+	return results
 }
@@ -7,8 +7,12 @@ import (
 
 	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -29,6 +33,20 @@ func NewOpenReportStore(client client.Client, logger *slog.Logger) Store {
 	}
 }
 
+// GetReport returns the OpenReports Report of the given resource.
+func (s *OpenReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	policyReport := &openreports.Report{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: string(resource.GetUID()), Namespace: resource.GetNamespace()}, policyReport)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: policy report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+		}
+		return nil, fmt.Errorf("failed to get policy report %s: %w", resource.GetUID(), err)
+	}
+
+	return &OpenReport{report: policyReport}, nil
+}
+
 // CreateOrPatchReport creates or patches a OpenReports Report.
 func (s *OpenReportStore) CreateOrPatchReport(ctx context.Context, obj any) error {
 	openReport, ok := obj.(*OpenReport)
@@ -64,6 +82,11 @@ func (s *OpenReportStore) CreateOrPatchReport(ctx context.Context, obj any) erro
 	return nil
 }
 
+// FlushReports is a no-op, the reports are written as soon as they are created or patched.
+func (s *OpenReportStore) FlushReports(_ context.Context, _, _ string) error {
+	return nil
+}
+
 // DeleteOldReports deletes all the OpenReports Reports that do not belong to the current scan run.
 func (s *OpenReportStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
@@ -81,6 +104,20 @@ func (s *OpenReportStore) DeleteOldReports(ctx context.Context, scanRunID, names
 	return nil
 }
 
+// GetClusterReport returns the OpenReports ClusterReport of the given cluster-wide resource.
+func (s *OpenReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	clusterPolicyReport := &openreports.ClusterReport{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: string(resource.GetUID())}, clusterPolicyReport)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: cluster policy report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+		}
+		return nil, fmt.Errorf("failed to get cluster policy report %s: %w", resource.GetUID(), err)
+	}
+
+	return &OpenClusterReport{report: clusterPolicyReport}, nil
+}
+
 // CreateOrPatchClusterReport creates or patches a OpenReports ClusterReport.
 func (s *OpenReportStore) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
 	openReport, ok := obj.(*OpenClusterReport)
@@ -115,6 +152,11 @@ func (s *OpenReportStore) CreateOrPatchClusterReport(ctx context.Context, obj an
 	return nil
 }
 
+// FlushClusterReports is a no-op, the reports are written as soon as they are created or patched.
+func (s *OpenReportStore) FlushClusterReports(_ context.Context, _ string) error {
+	return nil
+}
+
 // DeleteOldClusterReports deletes all the OpenReports ClusterReports that do not belong to the current scan run.
 func (s *OpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
@@ -130,3 +172,69 @@ func (s *OpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID
 	}
 	return nil
 }
+
+// ListStaleResources returns the resources of the OpenReports Reports of the
+// namespace that do not belong to the current scan run.
+func (s *OpenReportStore) ListStaleResources(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	listOptions, err := staleListOptions(scanRunID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	reportList := &openreports.ReportList{}
+	if err := s.client.List(ctx, reportList, listOptions); err != nil {
+		return nil, fmt.Errorf("failed to list PolicyReports: %w", err)
+	}
+	var resources []corev1.ObjectReference
+	for _, openReport := range reportList.Items {
+		if openReport.Scope != nil {
+			resources = append(resources, *openReport.Scope)
+		}
+	}
+	return resources, nil
+}
+
+// ListStaleClusterResources returns the resources of the OpenReports
+// ClusterReports that do not belong to the current scan run.
+func (s *OpenReportStore) ListStaleClusterResources(ctx context.Context, scanRunID string) ([]corev1.ObjectReference, error) {
+	listOptions, err := staleListOptions(scanRunID, "")
+	if err != nil {
+		return nil, err
+	}
+	reportList := &openreports.ClusterReportList{}
+	if err := s.client.List(ctx, reportList, listOptions); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPolicyReports: %w", err)
+	}
+	var resources []corev1.ObjectReference
+	for _, openReport := range reportList.Items {
+		if openReport.Scope != nil {
+			resources = append(resources, *openReport.Scope)
+		}
+	}
+	return resources, nil
+}
+
+// ListReports returns the OpenReports Reports of the namespace, by resource UID.
+func (s *OpenReportStore) ListReports(ctx context.Context, namespace string) (map[types.UID]Report, error) {
+	reportList := &openreports.ReportList{}
+	if err := s.client.List(ctx, reportList, reportListOptions(namespace)...); err != nil {
+		return nil, fmt.Errorf("failed to list PolicyReports of namespace %s: %w", namespace, err)
+	}
+	reports := make(map[types.UID]Report, len(reportList.Items))
+	for i := range reportList.Items {
+		reports[types.UID(reportList.Items[i].Name)] = &OpenReport{report: &reportList.Items[i]}
+	}
+	return reports, nil
+}
+
+// ListClusterReports returns the OpenReports ClusterReports, by resource UID.
+func (s *OpenReportStore) ListClusterReports(ctx context.Context) (map[types.UID]Report, error) {
+	reportList := &openreports.ClusterReportList{}
+	if err := s.client.List(ctx, reportList, reportListOptions("")...); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPolicyReports: %w", err)
+	}
+	reports := make(map[types.UID]Report, len(reportList.Items))
+	for i := range reportList.Items {
+		reports[types.UID(reportList.Items[i].Name)] = &OpenClusterReport{report: &reportList.Items[i]}
+	}
+	return reports, nil
+}
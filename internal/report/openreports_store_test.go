@@ -83,7 +83,7 @@ func TestPatchReport(t *testing.T) {
 			Result:  &metav1.Status{Message: "The request was allowed"},
 		},
 	}
-	newPolicyReport.AddResult(policy, admissionReview, false)
+	newPolicyReport.AddResult(policy, admissionReview, false, nil)
 	err = store.CreateOrPatchReport(t.Context(), newPolicyReport)
 	require.NoError(t, err)
 
@@ -98,6 +98,44 @@ func TestPatchReport(t *testing.T) {
 	require.Equal(t, newPolicyReport.report.Results, storedPolicyReport.Results)
 }
 
+func TestGetReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewOpenReportStore(fakeClient, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetReport(t.Context(), resource)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	policyReport := NewOpenReport("runUID", resource)
+	err = store.CreateOrPatchReport(t.Context(), policyReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetReport(t.Context(), resource)
+	require.NoError(t, err)
+	storedPolicyReport, ok := storedReport.(*OpenReport)
+	require.True(t, ok)
+	require.Equal(t, policyReport.report.Scope, storedPolicyReport.report.Scope)
+	require.Equal(t, policyReport.report.Results, storedPolicyReport.report.Results)
+
+	// the reports of the namespace are listed by resource UID
+	storedReports, err := store.(ReportLister).ListReports(t.Context(), "namespace")
+	require.NoError(t, err)
+	require.Len(t, storedReports, 1)
+	require.Equal(t, policyReport.report.Scope, storedReports["uid"].(*OpenReport).report.Scope)
+	storedReports, err = store.(ReportLister).ListReports(t.Context(), "other")
+	require.NoError(t, err)
+	require.Empty(t, storedReports)
+}
+
 func TestCreateClusterReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
@@ -160,7 +198,7 @@ func TestPatchClusterReport(t *testing.T) {
 			Result:  &metav1.Status{Message: "The request was allowed"},
 		},
 	}
-	newClusterPolicyReport.AddResult(policy, admissionReview, false)
+	newClusterPolicyReport.AddResult(policy, admissionReview, false, nil)
 	err = store.CreateOrPatchClusterReport(t.Context(), newClusterPolicyReport)
 	require.NoError(t, err)
 
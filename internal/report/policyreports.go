@@ -1,10 +1,13 @@
 package report
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
@@ -56,15 +59,51 @@ func (r *PolicyReport) AddResult(
 	policy policiesv1.Policy,
 	admissionReview *admissionv1.AdmissionReview,
 	errored bool,
+	properties map[string]string,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
+	result := newPolicyReportResult(policy, admissionReview, errored, properties, now)
+	r.appendResult(result)
+}
+
+func (r *PolicyReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*PolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if !canReuseResult(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			return false
+		}
+		r.appendResult(result.DeepCopy())
+		return true
+	}
+	return false
+}
+
+func (r *PolicyReport) MergeResults(previous Report, audited func(policy string) bool) {
+	previousReport, ok := previous.(*PolicyReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if audited(result.Policy) {
+			continue
+		}
+		r.appendResult(result.DeepCopy())
+	}
+}
+
+func (r *PolicyReport) appendResult(result *wgpolicy.PolicyReportResult) {
 	switch result.Result {
-	case statusFail:
+	case StatusFail:
 		r.report.Summary.Fail++
-	case statusError:
+	case StatusError:
 		r.report.Summary.Error++
-	case statusPass:
+	case StatusPass:
 		r.report.Summary.Pass++
 	}
 	r.report.Results = append(r.report.Results, result)
@@ -78,6 +117,35 @@ func (r *PolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
 
+func (r *PolicyReport) GetResults() []Result {
+	return newResultsFromPolicyReport(r.report.Results)
+}
+
+func (r *PolicyReport) GetScope() *corev1.ObjectReference {
+	return r.report.Scope
+}
+
+// MarshalJSON marshals the underlying PolicyReport resource, with its apiVersion and kind.
+func (r *PolicyReport) MarshalJSON() ([]byte, error) {
+	resource := r.report.DeepCopy()
+	resource.SetGroupVersionKind(wgpolicy.SchemeGroupVersion.WithKind("PolicyReport"))
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal PolicyReport %s: %w", resource.GetName(), err)
+	}
+	return data, nil
+}
+
+func (r *PolicyReport) GetSummary() Summary {
+	return Summary{
+		Pass:  r.report.Summary.Pass,
+		Fail:  r.report.Summary.Fail,
+		Warn:  r.report.Summary.Warn,
+		Error: r.report.Summary.Error,
+		Skip:  r.report.Summary.Skip,
+	}
+}
+
 // NewClusterPolicyReport creates a new ClusterPolicyReport from a given resource.
 // Deprecated: use NewClusterReport instead. wgpolicy.ClusterPolicyReport is deprecated in favor of openreports.ClusterReport.
 func NewClusterPolicyReport(runUID string, resource unstructured.Unstructured) *ClusterPolicyReport {
@@ -100,15 +168,51 @@ func (r *ClusterPolicyReport) AddResult(
 	policy policiesv1.Policy,
 	admissionReview *admissionv1.AdmissionReview,
 	errored bool,
+	properties map[string]string,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
+	result := newPolicyReportResult(policy, admissionReview, errored, properties, now)
+	r.appendResult(result)
+}
+
+func (r *ClusterPolicyReport) ReuseResult(previous Report, policy policiesv1.Policy) bool {
+	previousReport, ok := previous.(*ClusterPolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	for _, result := range previousReport.report.Results {
+		if result.Policy != policy.GetUniqueName() {
+			continue
+		}
+		if !canReuseResult(previousReport.report.Scope, r.report.Scope, string(result.Result), result.Properties, policy) {
+			return false
+		}
+		r.appendResult(result.DeepCopy())
+		return true
+	}
+	return false
+}
+
+func (r *ClusterPolicyReport) MergeResults(previous Report, audited func(policy string) bool) {
+	previousReport, ok := previous.(*ClusterPolicyReport)
+	if !ok || previousReport == nil {
+		return
+	}
+	for _, result := range previousReport.report.Results {
+		if audited(result.Policy) {
+			continue
+		}
+		r.appendResult(result.DeepCopy())
+	}
+}
+
+func (r *ClusterPolicyReport) appendResult(result *wgpolicy.PolicyReportResult) {
 	switch result.Result {
-	case statusFail:
+	case StatusFail:
 		r.report.Summary.Fail++
-	case statusError:
+	case StatusError:
 		r.report.Summary.Error++
-	case statusPass:
+	case StatusPass:
 		r.report.Summary.Pass++
 	}
 	r.report.Results = append(r.report.Results, result)
@@ -122,7 +226,36 @@ func (r *ClusterPolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
 
-func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, timestamp metav1.Timestamp) *wgpolicy.PolicyReportResult {
+func (r *ClusterPolicyReport) GetResults() []Result {
+	return newResultsFromPolicyReport(r.report.Results)
+}
+
+func (r *ClusterPolicyReport) GetScope() *corev1.ObjectReference {
+	return r.report.Scope
+}
+
+// MarshalJSON marshals the underlying ClusterPolicyReport resource, with its apiVersion and kind.
+func (r *ClusterPolicyReport) MarshalJSON() ([]byte, error) {
+	resource := r.report.DeepCopy()
+	resource.SetGroupVersionKind(wgpolicy.SchemeGroupVersion.WithKind("ClusterPolicyReport"))
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ClusterPolicyReport %s: %w", resource.GetName(), err)
+	}
+	return data, nil
+}
+
+func (r *ClusterPolicyReport) GetSummary() Summary {
+	return Summary{
+		Pass:  r.report.Summary.Pass,
+		Fail:  r.report.Summary.Fail,
+		Warn:  r.report.Summary.Warn,
+		Error: r.report.Summary.Error,
+		Skip:  r.report.Summary.Skip,
+	}
+}
+
+func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, properties map[string]string, timestamp metav1.Timestamp) *wgpolicy.PolicyReportResult {
 	category, message := getCategoryAndMessage(policy, admissionReview)
 
 	return &wgpolicy.PolicyReportResult{
@@ -136,6 +269,21 @@ func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv
 		SubjectSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, properties),
+	}
+}
+
+func newResultsFromPolicyReport(policyReportResults []*wgpolicy.PolicyReportResult) []Result {
+	results := make([]Result, 0, len(policyReportResults))
+	for _, result := range policyReportResults {
+		results = append(results, Result{
+			Policy:     result.Policy,
+			Status:     string(result.Result),
+			Severity:   string(result.Severity),
+			Category:   result.Category,
+			Message:    result.Description,
+			Properties: result.Properties,
+		})
 	}
+	return results
 }
@@ -6,8 +6,12 @@ import (
 	"log/slog"
 
 	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
@@ -30,6 +34,20 @@ func NewPolicyReportStore(client client.Client, logger *slog.Logger) *PolicyRepo
 	}
 }
 
+// GetReport returns the PolicyReport of the given resource.
+func (s *PolicyReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	policyReport := &wgpolicy.PolicyReport{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: string(resource.GetUID()), Namespace: resource.GetNamespace()}, policyReport)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: policy report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+		}
+		return nil, fmt.Errorf("failed to get policy report %s: %w", resource.GetUID(), err)
+	}
+
+	return &PolicyReport{report: policyReport}, nil
+}
+
 // CreateOrPatchReport creates or patches a PolicyReport.
 func (s *PolicyReportStore) CreateOrPatchReport(ctx context.Context, obj any) error {
 	report, ok := obj.(*PolicyReport)
@@ -65,6 +83,11 @@ func (s *PolicyReportStore) CreateOrPatchReport(ctx context.Context, obj any) er
 	return nil
 }
 
+// FlushReports is a no-op, the reports are written as soon as they are created or patched.
+func (s *PolicyReportStore) FlushReports(_ context.Context, _, _ string) error {
+	return nil
+}
+
 // DeleteOldReports deletes old PolicyReports that do not match the given scanRunID.
 func (s *PolicyReportStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
@@ -82,6 +105,20 @@ func (s *PolicyReportStore) DeleteOldReports(ctx context.Context, scanRunID, nam
 	return nil
 }
 
+// GetClusterReport returns the ClusterPolicyReport of the given cluster-wide resource.
+func (s *PolicyReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	clusterPolicyReport := &wgpolicy.ClusterPolicyReport{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: string(resource.GetUID())}, clusterPolicyReport)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: cluster policy report %s", auditConstants.ErrResourceNotFound, resource.GetUID())
+		}
+		return nil, fmt.Errorf("failed to get cluster policy report %s: %w", resource.GetUID(), err)
+	}
+
+	return &ClusterPolicyReport{report: clusterPolicyReport}, nil
+}
+
 // CreateOrPatchClusterReport creates or patches a ClusterPolicyReport.
 func (s *PolicyReportStore) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
 	report, ok := obj.(*ClusterPolicyReport)
@@ -116,6 +153,11 @@ func (s *PolicyReportStore) CreateOrPatchClusterReport(ctx context.Context, obj
 	return nil
 }
 
+// FlushClusterReports is a no-op, the reports are written as soon as they are created or patched.
+func (s *PolicyReportStore) FlushClusterReports(_ context.Context, _ string) error {
+	return nil
+}
+
 // DeleteOldClusterReports deletes old ClusterPolicyReports that do not belong to the current scan run.
 func (s *PolicyReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
@@ -131,3 +173,69 @@ func (s *PolicyReportStore) DeleteOldClusterReports(ctx context.Context, scanRun
 	}
 	return nil
 }
+
+// ListStaleResources returns the resources of the PolicyReports of the namespace
+// that do not match the given scanRunID.
+func (s *PolicyReportStore) ListStaleResources(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error) {
+	listOptions, err := staleListOptions(scanRunID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	reportList := &wgpolicy.PolicyReportList{}
+	if err := s.client.List(ctx, reportList, listOptions); err != nil {
+		return nil, fmt.Errorf("failed to list PolicyReports: %w", err)
+	}
+	var resources []corev1.ObjectReference
+	for _, policyReport := range reportList.Items {
+		if policyReport.Scope != nil {
+			resources = append(resources, *policyReport.Scope)
+		}
+	}
+	return resources, nil
+}
+
+// ListStaleClusterResources returns the resources of the ClusterPolicyReports
+// that do not belong to the current scan run.
+func (s *PolicyReportStore) ListStaleClusterResources(ctx context.Context, scanRunID string) ([]corev1.ObjectReference, error) {
+	listOptions, err := staleListOptions(scanRunID, "")
+	if err != nil {
+		return nil, err
+	}
+	reportList := &wgpolicy.ClusterPolicyReportList{}
+	if err := s.client.List(ctx, reportList, listOptions); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPolicyReports: %w", err)
+	}
+	var resources []corev1.ObjectReference
+	for _, clusterPolicyReport := range reportList.Items {
+		if clusterPolicyReport.Scope != nil {
+			resources = append(resources, *clusterPolicyReport.Scope)
+		}
+	}
+	return resources, nil
+}
+
+// ListReports returns the PolicyReports of the namespace, by resource UID.
+func (s *PolicyReportStore) ListReports(ctx context.Context, namespace string) (map[types.UID]Report, error) {
+	reportList := &wgpolicy.PolicyReportList{}
+	if err := s.client.List(ctx, reportList, reportListOptions(namespace)...); err != nil {
+		return nil, fmt.Errorf("failed to list PolicyReports of namespace %s: %w", namespace, err)
+	}
+	reports := make(map[types.UID]Report, len(reportList.Items))
+	for i := range reportList.Items {
+		reports[types.UID(reportList.Items[i].Name)] = &PolicyReport{report: &reportList.Items[i]}
+	}
+	return reports, nil
+}
+
+// ListClusterReports returns the ClusterPolicyReports, by resource UID.
+func (s *PolicyReportStore) ListClusterReports(ctx context.Context) (map[types.UID]Report, error) {
+	reportList := &wgpolicy.ClusterPolicyReportList{}
+	if err := s.client.List(ctx, reportList, reportListOptions("")...); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterPolicyReports: %w", err)
+	}
+	reports := make(map[types.UID]Report, len(reportList.Items))
+	for i := range reportList.Items {
+		reports[types.UID(reportList.Items[i].Name)] = &ClusterPolicyReport{report: &reportList.Items[i]}
+	}
+	return reports, nil
+}
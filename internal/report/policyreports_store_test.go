@@ -83,7 +83,7 @@ func TestPatchPolicyReport(t *testing.T) {
 			Result:  &metav1.Status{Message: "The request was allowed"},
 		},
 	}
-	newPolicyReport.AddResult(policy, admissionReview, false)
+	newPolicyReport.AddResult(policy, admissionReview, false, nil)
 	err = store.CreateOrPatchReport(t.Context(), newPolicyReport)
 	require.NoError(t, err)
 
@@ -160,7 +160,7 @@ func TestPatchClusterPolicyReport(t *testing.T) {
 			Result:  &metav1.Status{Message: "The request was allowed"},
 		},
 	}
-	newClusterPolicyReport.AddResult(policy, admissionReview, false)
+	newClusterPolicyReport.AddResult(policy, admissionReview, false, nil)
 	err = store.CreateOrPatchClusterReport(t.Context(), newClusterPolicyReport)
 	require.NoError(t, err)
 
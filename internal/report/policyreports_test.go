@@ -1,12 +1,15 @@
 package report
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/kubewarden/audit-scanner/internal/constants"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -50,6 +53,33 @@ func TestNewPolicyReport(t *testing.T) {
 	assert.Empty(t, policyReport.report.Results)
 }
 
+func TestMarshalPolicyReport(t *testing.T) {
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("test-pod")
+
+	data, err := json.Marshal(NewPolicyReport("runUID", resource))
+	require.NoError(t, err)
+
+	policyReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, json.Unmarshal(data, policyReport))
+	assert.Equal(t, "wgpolicyk8s.io/v1alpha2", policyReport.APIVersion)
+	assert.Equal(t, "PolicyReport", policyReport.Kind)
+	assert.Equal(t, "uid", policyReport.Name)
+	assert.Equal(t, "namespace", policyReport.Namespace)
+	assert.Equal(t, "test-pod", policyReport.Scope.Name)
+
+	data, err = json.Marshal(NewClusterOpenReport("runUID", resource))
+	require.NoError(t, err)
+	clusterReport := &openreports.ClusterReport{}
+	require.NoError(t, json.Unmarshal(data, clusterReport))
+	assert.Equal(t, "openreports.io/v1alpha1", clusterReport.APIVersion)
+	assert.Equal(t, "ClusterReport", clusterReport.Kind)
+}
+
 func TestAddResultToPolicyReport(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -104,7 +134,7 @@ func TestAddResultToPolicyReport(t *testing.T) {
 			policy := &policiesv1.AdmissionPolicy{}
 			policyReport := NewPolicyReport("runUID", unstructured.Unstructured{})
 
-			policyReport.AddResult(policy, test.admissionReview, test.errored)
+			policyReport.AddResult(policy, test.admissionReview, test.errored, nil)
 
 			assert.Len(t, policyReport.report.Results, 1)
 
@@ -163,13 +193,172 @@ func TestAddResultToClusterPolicyReport(t *testing.T) {
 	}
 
 	clusterPolicyReport := NewClusterPolicyReport("runUID", unstructured.Unstructured{})
-	clusterPolicyReport.AddResult(policy, admissionReview, false)
+	clusterPolicyReport.AddResult(policy, admissionReview, false, map[string]string{PropertyEvaluationAttempts: "2"})
 
 	assert.Len(t, clusterPolicyReport.report.Results, 1)
 	assert.Equal(t, 0, clusterPolicyReport.report.Summary.Pass)
 	assert.Equal(t, 1, clusterPolicyReport.report.Summary.Fail)
 	assert.Equal(t, 0, clusterPolicyReport.report.Summary.Warn)
 	assert.Equal(t, 0, clusterPolicyReport.report.Summary.Error)
+	assert.Equal(t, "2", clusterPolicyReport.report.Results[0].Properties[PropertyEvaluationAttempts])
+	assert.Equal(t, valueTypeTrue, clusterPolicyReport.report.Results[0].Properties[typeValidating])
+
+	results := clusterPolicyReport.GetResults()
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusFail, results[0].Status)
+	assert.Equal(t, "The request was rejected", results[0].Message)
+}
+
+func TestReuseResultFromPolicyReport(t *testing.T) {
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             "policy-uid",
+			ResourceVersion: "1",
+			Name:            "policy-name",
+		},
+	}
+	allowed := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+
+	tests := []struct {
+		name                  string
+		resourceVersion       string
+		policyResourceVersion string
+		policyContextAware    bool
+		previousErrored       bool
+		expectedReused        bool
+		expectedPass          int
+	}{
+		{
+			name:                  "Resource and policy did not change",
+			resourceVersion:       "12345",
+			policyResourceVersion: "1",
+			expectedReused:        true,
+			expectedPass:          1,
+		},
+		{
+			name:                  "Resource changed",
+			resourceVersion:       "45678",
+			policyResourceVersion: "1",
+			expectedReused:        false,
+		},
+		{
+			name:                  "Policy changed",
+			resourceVersion:       "12345",
+			policyResourceVersion: "2",
+			expectedReused:        false,
+		},
+		{
+			name:                  "Previous result errored",
+			resourceVersion:       "12345",
+			policyResourceVersion: "1",
+			previousErrored:       true,
+			expectedReused:        false,
+		},
+		{
+			name:                  "Context aware policy",
+			resourceVersion:       "12345",
+			policyResourceVersion: "1",
+			policyContextAware:    true,
+			expectedReused:        false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource := unstructured.Unstructured{}
+			resource.SetUID("uid")
+			resource.SetNamespace("namespace")
+			resource.SetResourceVersion("12345")
+
+			previousPolicy := policy.DeepCopy()
+			if test.policyContextAware {
+				previousPolicy.Spec.ContextAwareResources = []policiesv1.ContextAwareResource{{APIVersion: "v1", Kind: "Pod"}}
+			}
+			previousReport := NewPolicyReport("previousRunUID", resource)
+			previousReport.AddResult(previousPolicy, allowed, test.previousErrored, nil)
+
+			currentPolicy := previousPolicy.DeepCopy()
+			currentPolicy.SetResourceVersion(test.policyResourceVersion)
+			resource.SetResourceVersion(test.resourceVersion)
+			policyReport := NewPolicyReport("runUID", resource)
+
+			reused := policyReport.ReuseResult(previousReport, currentPolicy)
+
+			assert.Equal(t, test.expectedReused, reused)
+			assert.Equal(t, test.expectedPass, policyReport.report.Summary.Pass)
+			if test.expectedReused {
+				assert.Equal(t, previousReport.report.Results, policyReport.report.Results)
+			} else {
+				assert.Empty(t, policyReport.report.Results)
+			}
+		})
+	}
+}
+
+func TestMergeResultsIntoPolicyReport(t *testing.T) {
+	auditedPolicy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:  "audited-policy-uid",
+			Name: "audited-policy",
+		},
+	}
+	otherPolicy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:  "other-policy-uid",
+			Name: "other-policy",
+		},
+	}
+	// the policy is audited, but it no longer evaluates the resource
+	unmatchedPolicy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:  "unmatched-policy-uid",
+			Name: "unmatched-policy",
+		},
+	}
+	audited := func(policy string) bool {
+		return policy != otherPolicy.GetUniqueName()
+	}
+	allowed := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+		},
+	}
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetNamespace("namespace")
+
+	previousReport := NewPolicyReport("previousRunUID", resource)
+	previousReport.AddResult(auditedPolicy, rejected, false, nil)
+	previousReport.AddResult(otherPolicy, allowed, false, nil)
+	previousReport.AddResult(unmatchedPolicy, rejected, false, nil)
+
+	policyReport := NewPolicyReport("runUID", resource)
+	policyReport.AddResult(auditedPolicy, allowed, false, nil)
+	policyReport.MergeResults(previousReport, audited)
+
+	require.Len(t, policyReport.report.Results, 2)
+	// the results of the audited policies are not replaced by the previous ones,
+	// even when the policy no longer evaluates the resource
+	assert.Equal(t, auditedPolicy.GetUniqueName(), policyReport.report.Results[0].Policy)
+	assert.Equal(t, wgpolicy.PolicyResult(StatusPass), policyReport.report.Results[0].Result)
+	assert.Equal(t, previousReport.report.Results[1], policyReport.report.Results[1])
+	assert.Equal(t, 2, policyReport.report.Summary.Pass)
+	assert.Equal(t, 0, policyReport.report.Summary.Fail)
+
+	// reports of a different kind are ignored
+	clusterReport := NewClusterPolicyReport("runUID", resource)
+	clusterReport.MergeResults(previousReport, audited)
+	assert.Empty(t, clusterReport.report.Results)
 }
 
 func TestNewPolicyReportResult(t *testing.T) {
@@ -190,7 +379,7 @@ func TestNewPolicyReportResult(t *testing.T) {
 					ResourceVersion: "1",
 					Name:            "policy-name",
 					Annotations: map[string]string{
-						policiesv1.AnnotationSeverity: severityLow,
+						policiesv1.AnnotationSeverity: SeverityLow,
 					},
 				},
 				Spec: policiesv1.ClusterAdmissionPolicySpec{
@@ -209,8 +398,8 @@ func TestNewPolicyReportResult(t *testing.T) {
 			expectedResult: &wgpolicy.PolicyReportResult{
 				Source:          policyReportSource,
 				Policy:          "clusterwide-policy-name",
-				Severity:        severityLow,
-				Result:          statusPass,
+				Severity:        SeverityLow,
+				Result:          StatusPass,
 				Timestamp:       now,
 				Scored:          true,
 				SubjectSelector: &metav1.LabelSelector{},
@@ -232,7 +421,7 @@ func TestNewPolicyReportResult(t *testing.T) {
 					Name:            "policy-name",
 					Namespace:       "policy-namespace",
 					Annotations: map[string]string{
-						policiesv1.AnnotationSeverity: severityCritical,
+						policiesv1.AnnotationSeverity: SeverityCritical,
 					},
 				},
 				Spec: policiesv1.AdmissionPolicySpec{
@@ -251,8 +440,8 @@ func TestNewPolicyReportResult(t *testing.T) {
 			expectedResult: &wgpolicy.PolicyReportResult{
 				Source:          policyReportSource,
 				Policy:          "namespaced-policy-namespace-policy-name",
-				Severity:        severityCritical,
-				Result:          statusFail,
+				Severity:        SeverityCritical,
+				Result:          StatusFail,
 				Timestamp:       now,
 				Scored:          true,
 				SubjectSelector: &metav1.LabelSelector{},
@@ -275,7 +464,7 @@ func TestNewPolicyReportResult(t *testing.T) {
 					Name:            "policy-name",
 					Namespace:       "policy-namespace",
 					Annotations: map[string]string{
-						policiesv1.AnnotationSeverity: severityInfo,
+						policiesv1.AnnotationSeverity: SeverityInfo,
 					},
 				},
 				Spec: policiesv1.AdmissionPolicySpec{
@@ -298,8 +487,8 @@ func TestNewPolicyReportResult(t *testing.T) {
 			expectedResult: &wgpolicy.PolicyReportResult{
 				Source:          policyReportSource,
 				Policy:          "namespaced-policy-namespace-policy-name",
-				Severity:        severityInfo,
-				Result:          statusError,
+				Severity:        SeverityInfo,
+				Result:          StatusError,
 				Timestamp:       now,
 				Scored:          true,
 				SubjectSelector: &metav1.LabelSelector{},
@@ -317,7 +506,7 @@ func TestNewPolicyReportResult(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result := newPolicyReportResult(test.policy, test.admissionReview, test.errored, now)
+			result := newPolicyReportResult(test.policy, test.admissionReview, test.errored, nil, now)
 			assert.Equal(t, test.expectedResult, result)
 		})
 	}
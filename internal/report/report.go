@@ -1,6 +1,8 @@
 package report
 
 import (
+	"maps"
+
 	"github.com/kubewarden/audit-scanner/internal/constants"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	admissionv1 "k8s.io/api/admission/v1"
@@ -22,7 +24,78 @@ const (
 type Report interface {
 	SetSkipPolicies(n int)
 	SetErrorPolicies(n int)
-	AddResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool)
+	// AddResult adds the result of the evaluation of the given policy. The
+	// properties are added to the ones computed from the policy, they can be nil.
+	AddResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool, properties map[string]string)
+	// ReuseResult copies the result of the given policy from a report created
+	// by a previous scan of the same resource. The result is copied only when
+	// neither the resource nor the policy changed since it was computed.
+	// It returns true if the result has been reused.
+	ReuseResult(previous Report, policy policiesv1.Policy) bool
+	// MergeResults copies the results of the policies that are not audited
+	// from a report created by a previous scan of the same resource. It's used
+	// when only a subset of the policies is audited, to preserve the results
+	// of the other policies. The previous results of the audited policies are
+	// dropped, even when they no longer evaluate the resource.
+	MergeResults(previous Report, audited func(policy string) bool)
+	// GetSummary returns the number of results of the report by status.
+	GetSummary() Summary
+	// GetResults returns the results of the report.
+	GetResults() []Result
+	// GetScope returns the reference to the audited resource.
+	GetScope() *corev1.ObjectReference
+}
+
+// Result is a view of a result of a report, independent of the kind of
+// the report.
+type Result struct {
+	// Policy is the unique name of the policy
+	Policy     string
+	Status     string
+	Severity   string
+	Category   string
+	Message    string
+	Properties map[string]string
+}
+
+// Summary counts the results of a report by status.
+type Summary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+// SummarizeResults counts the given results by status. Unlike the summary of
+// a report, it doesn't include the policies skipped or errored for the whole
+// namespace.
+func SummarizeResults(results []Result) Summary {
+	var summary Summary
+	for _, result := range results {
+		switch result.Status {
+		case StatusPass:
+			summary.Pass++
+		case StatusFail:
+			summary.Fail++
+		case StatusWarn:
+			summary.Warn++
+		case StatusError:
+			summary.Error++
+		case StatusSkip:
+			summary.Skip++
+		}
+	}
+	return summary
+}
+
+// Add adds the counters of the given summary.
+func (s *Summary) Add(other Summary) {
+	s.Pass += other.Pass
+	s.Fail += other.Fail
+	s.Warn += other.Warn
+	s.Error += other.Error
+	s.Skip += other.Skip
 }
 
 func getCategoryAndMessage(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) (string, string) {
@@ -48,17 +121,17 @@ func getCategoryAndMessage(policy policiesv1.Policy, admissionReview *admissionv
 
 func computePolicyResult(errored bool, admissionReview *admissionv1.AdmissionReview) string {
 	if errored {
-		return statusError
+		return StatusError
 	}
 	if admissionReview.Response.Allowed {
-		return statusPass
+		return StatusPass
 	}
-	return statusFail
+	return StatusFail
 }
 
 func computePolicyResultSeverity(policy policiesv1.Policy) string {
 	if policy.GetPolicyMode() == policiesv1.PolicyMode(policiesv1.PolicyModeStatusMonitor) {
-		return severityInfo
+		return SeverityInfo
 	}
 
 	if s, present := policy.GetSeverity(); present {
@@ -68,8 +141,11 @@ func computePolicyResultSeverity(policy policiesv1.Policy) string {
 	return ""
 }
 
-func computeProperties(policy policiesv1.Policy) map[string]string {
-	properties := map[string]string{}
+func computeProperties(policy policiesv1.Policy, extraProperties map[string]string) map[string]string {
+	properties := maps.Clone(extraProperties)
+	if properties == nil {
+		properties = map[string]string{}
+	}
 	if policy.IsMutating() {
 		properties[typeMutating] = valueTypeTrue
 	} else {
@@ -120,3 +196,23 @@ func getReportScope(resource unstructured.Unstructured) *corev1.ObjectReference
 		ResourceVersion: resource.GetResourceVersion(),
 	}
 }
+
+// canReuseResult checks if a result computed by a previous scan is still valid.
+// That's the case when neither the audited resource nor the policy changed since
+// then. Errored results are never reused, the policy is evaluated again. The
+// same applies to context aware policies, because their outcome depends on other
+// resources of the cluster.
+func canReuseResult(previousScope, currentScope *corev1.ObjectReference, status string, properties map[string]string, policy policiesv1.Policy) bool {
+	if previousScope == nil || currentScope == nil || currentScope.ResourceVersion == "" {
+		return false
+	}
+	if previousScope.UID != currentScope.UID || previousScope.ResourceVersion != currentScope.ResourceVersion {
+		return false
+	}
+	if status == StatusError || policy.IsContextAware() {
+		return false
+	}
+
+	return properties[propertyPolicyUID] == string(policy.GetUID()) &&
+		properties[propertyPolicyResourceVersion] == policy.GetResourceVersion()
+}
@@ -2,20 +2,78 @@ package report
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 
+	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Store is an interface to abstract the storage of reports. It's agnostic to the
 // kind of report used (PolicyReport or OpenReport).
 type Store interface {
+	// GetReport returns the report stored for the given namespaced resource.
+	// It returns constants.ErrResourceNotFound when there's no such report.
+	GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error)
 	CreateOrPatchReport(ctx context.Context, report any) error
+	// FlushReports writes the reports of the namespace that have been created
+	// or patched but not written yet, keeping the results of the other
+	// resources. It's a no-op for the stores writing the reports immediately.
+	FlushReports(ctx context.Context, scanRunID, namespace string) error
 	DeleteOldReports(ctx context.Context, scanRunID, namespace string) error
+	// GetClusterReport returns the report stored for the given cluster-wide resource.
+	// It returns constants.ErrResourceNotFound when there's no such report.
+	GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error)
 	CreateOrPatchClusterReport(ctx context.Context, report any) error
+	// FlushClusterReports is like FlushReports, for the cluster reports.
+	FlushClusterReports(ctx context.Context, scanRunID string) error
 	DeleteOldClusterReports(ctx context.Context, scanRunID string) error
 }
 
+// StaleLister is implemented by the stores able to list the resources whose
+// reports don't belong to the current scan run, the ones DeleteOldReports and
+// DeleteOldClusterReports delete. Once the scan of a namespace is completed,
+// they are the resources that disappeared since the previous scan.
+type StaleLister interface {
+	// ListStaleResources returns the resources of the namespace whose reports
+	// don't belong to the given scan run.
+	ListStaleResources(ctx context.Context, scanRunID, namespace string) ([]corev1.ObjectReference, error)
+	// ListStaleClusterResources is like ListStaleResources, for the cluster reports.
+	ListStaleClusterResources(ctx context.Context, scanRunID string) ([]corev1.ObjectReference, error)
+}
+
+// ReportLister is implemented by the stores able to list the reports of all
+// the resources of a namespace at once, so the scan of a namespace reads the
+// reports of the previous scans with a single request, rather than one for
+// each resource.
+type ReportLister interface {
+	// ListReports returns the reports of the resources of the namespace, by
+	// resource UID.
+	ListReports(ctx context.Context, namespace string) (map[types.UID]Report, error)
+	// ListClusterReports is like ListReports, for the cluster reports.
+	ListClusterReports(ctx context.Context) (map[types.UID]Report, error)
+}
+
+// reportListOptions returns the options listing the reports created by the
+// audit scanner in the namespace.
+func reportListOptions(namespace string) []client.ListOption {
+	return []client.ListOption{client.InNamespace(namespace), client.MatchingLabels{labelAppManagedBy: labelApp}}
+}
+
+// staleListOptions returns the options listing the reports created by the
+// audit scanner that don't belong to the given scan run.
+func staleListOptions(scanRunID, namespace string) (*client.ListOptions, error) {
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse label selector: %w", err)
+	}
+	return &client.ListOptions{LabelSelector: labelSelector, Namespace: namespace}, nil
+}
+
 func NewReportStoreOfKind(kind CrdKind, client client.Client, logger *slog.Logger) Store {
 	if kind == ReportKindPolicyReport {
 		return NewPolicyReportStore(client, logger)
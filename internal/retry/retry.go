@@ -0,0 +1,55 @@
+// Package retry provides the helpers shared by the clients retrying the
+// requests failing because of a transient error.
+package retry
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// TransientError is a failure of a request that may not happen again when the
+// request is retried.
+type TransientError struct {
+	Err error
+	// StatusCode is the status code of the response, it's zero when the
+	// request failed without a response
+	StatusCode int
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient returns true if the error is, or wraps, a TransientError.
+func IsTransient(err error) bool {
+	var transient *TransientError
+	return errors.As(err, &transient)
+}
+
+// Backoff returns the delay before the given retry, starting from 1. The delay
+// grows exponentially from the initial backoff up to the maximum one, which is
+// ignored when zero, and a random jitter of up to half of it is removed so the
+// retries of concurrent requests are spread over time.
+func Backoff(initialBackoff, maxBackoff time.Duration, retry int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if maxBackoff > 0 && delay >= maxBackoff {
+			break
+		}
+	}
+	if maxBackoff > 0 && delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2                    //nolint:mnd // up to half of the delay is jitter
+	return delay - half + rand.N(half+1) //nolint:gosec // the jitter doesn't need a secure random source
+}
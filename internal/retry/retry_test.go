@@ -0,0 +1,35 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	for retry, expected := range map[int]time.Duration{
+		1:   100 * time.Millisecond,
+		2:   200 * time.Millisecond,
+		3:   400 * time.Millisecond,
+		5:   time.Second,
+		100: time.Second,
+	} {
+		delay := Backoff(100*time.Millisecond, time.Second, retry)
+		assert.LessOrEqual(t, delay, expected)
+		assert.GreaterOrEqual(t, delay, expected/2)
+	}
+
+	assert.Zero(t, Backoff(0, time.Second, 3))
+}
+
+func TestIsTransient(t *testing.T) {
+	transient := &TransientError{Err: errors.New("unavailable"), StatusCode: 503}
+
+	assert.True(t, IsTransient(transient))
+	assert.True(t, IsTransient(fmt.Errorf("request failed: %w", transient)))
+	assert.False(t, IsTransient(errors.New("bad request")))
+	assert.False(t, IsTransient(nil))
+}
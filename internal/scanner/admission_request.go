@@ -1,13 +1,23 @@
 package scanner
 
 import (
+	"slices"
+
 	admv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
 )
 
-func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionRequest {
+// newAdmissionRequest returns the dry-run admission request simulating the
+// given operation on the resource, served by the given GroupVersionResource and
+// sent by the given user. UPDATE requests have the resource as both the new and
+// the old object, as if the resource was updated without changes.
+func newAdmissionRequest(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admissionregistrationv1.OperationType, userInfo authenticationv1.UserInfo) *admv1.AdmissionRequest {
 	groupVersionKind := resource.GroupVersionKind()
 	request := admv1.AdmissionRequest{
 		UID:  resource.GetUID(),
@@ -18,29 +28,98 @@ func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionReq
 			Kind:    groupVersionKind.Kind,
 		},
 		Resource: metav1.GroupVersionResource{
-			Group:    groupVersionKind.Group,
-			Version:  groupVersionKind.Version,
-			Resource: groupVersionKind.Kind,
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
 		},
 		RequestKind: &metav1.GroupVersionKind{
 			Group:   groupVersionKind.Group,
 			Version: groupVersionKind.Version,
 			Kind:    groupVersionKind.Kind,
 		},
-		Operation: admv1.Create,
+		RequestResource: &metav1.GroupVersionResource{
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
+		},
+		Operation: admv1.Operation(operation),
 		Namespace: resource.GetNamespace(),
+		UserInfo:  userInfo,
 		Object: runtime.RawExtension{
 			Object: resource.DeepCopyObject(),
 			Raw:    nil,
 		},
+		DryRun:  ptr.To(true),
+		Options: newOperationOptions(operation),
+	}
+	if operation == admissionregistrationv1.Update {
+		request.OldObject = runtime.RawExtension{
+			Object: resource.DeepCopyObject(),
+			Raw:    nil,
+		}
 	}
 	return &request
 }
 
-func newAdmissionReview(resource unstructured.Unstructured) *admv1.AdmissionReview {
-	admissionRequest := newAdmissionRequest(resource)
+func newAdmissionReview(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admissionregistrationv1.OperationType, userInfo authenticationv1.UserInfo) *admv1.AdmissionReview {
+	admissionRequest := newAdmissionRequest(resource, gvr, operation, userInfo)
 	return &admv1.AdmissionReview{
 		Request:  admissionRequest,
 		Response: nil,
 	}
 }
+
+// newOperationOptions returns the dry-run options of the given operation, as
+// sent by the API server in the admission requests.
+func newOperationOptions(operation admissionregistrationv1.OperationType) runtime.RawExtension {
+	if operation == admissionregistrationv1.Update {
+		return runtime.RawExtension{
+			Object: &metav1.UpdateOptions{
+				TypeMeta: metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+				DryRun:   []string{metav1.DryRunAll},
+			},
+			Raw: nil,
+		}
+	}
+
+	return runtime.RawExtension{
+		Object: &metav1.CreateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "CreateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+			DryRun:   []string{metav1.DryRunAll},
+		},
+		Raw: nil,
+	}
+}
+
+// userInfo returns the user sending the admission requests auditing the given
+// resource. In manager mode, it's the manager that last changed the resource,
+// according to its managedFields, otherwise it's the fixed audit identity.
+// The managedFields don't record the groups of the manager, so it has none,
+// rather than the ones of the audit identity.
+func (c UserInfoConfig) userInfo(resource unstructured.Unstructured) authenticationv1.UserInfo {
+	userInfo := authenticationv1.UserInfo{
+		Username: c.Username,
+		Groups:   slices.Clone(c.Groups),
+	}
+	if c.Mode != UserInfoModeManager {
+		return userInfo
+	}
+
+	var lastManager string
+	var lastTime *metav1.Time
+	for _, entry := range resource.GetManagedFields() {
+		if entry.Manager == "" {
+			continue
+		}
+		if lastManager == "" || (entry.Time != nil && (lastTime == nil || lastTime.Before(entry.Time))) {
+			lastManager = entry.Manager
+			lastTime = entry.Time
+		}
+	}
+	if lastManager != "" {
+		userInfo.Username = lastManager
+		userInfo.Groups = nil
+	}
+
+	return userInfo
+}
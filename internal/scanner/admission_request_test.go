@@ -1,10 +1,17 @@
 package scanner
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	admv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -15,6 +22,15 @@ const (
 	resourceNamespace = "testing-namespace"
 )
 
+//nolint:gochecknoglobals // test fixtures
+var (
+	podsGVR       = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	auditUserInfo = authenticationv1.UserInfo{
+		Username: "system:serviceaccount:kubewarden:audit-scanner",
+		Groups:   []string{"system:serviceaccounts", "system:authenticated"},
+	}
+)
+
 func generateUnstructuredPodObject() unstructured.Unstructured {
 	groupVersionKind := schema.GroupVersionKind{
 		Group:   "core",
@@ -32,7 +48,7 @@ func generateUnstructuredPodObject() unstructured.Unstructured {
 
 func TestObjectInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podsGVR, admissionregistrationv1.Create, auditUserInfo)
 
 	admReqObj := admissionRequest.Object.Object
 	if admReqObj.GetObjectKind().GroupVersionKind().Group != obj.GroupVersionKind().Group {
@@ -48,7 +64,7 @@ func TestObjectInAdmissionRequest(t *testing.T) {
 
 func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podsGVR, admissionregistrationv1.Create, auditUserInfo)
 
 	if admissionRequest.Kind.Group != obj.GroupVersionKind().Group {
 		t.Errorf("Group diverge")
@@ -65,13 +81,13 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podsGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podsGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podsGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -84,7 +100,7 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 
 func TestGetAdmissionReview(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionReview := newAdmissionReview(obj)
+	admissionReview := newAdmissionReview(obj, podsGVR, admissionregistrationv1.Create, auditUserInfo)
 
 	if admissionReview.Response != nil {
 		t.Fatalf("Response should not be set")
@@ -107,13 +123,13 @@ func TestGetAdmissionReview(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podsGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podsGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podsGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -123,3 +139,98 @@ func TestGetAdmissionReview(t *testing.T) {
 		t.Errorf("Operation diverge")
 	}
 }
+
+func TestUpdateAdmissionRequest(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+	admissionRequest := newAdmissionRequest(obj, podsGVR, admissionregistrationv1.Update, auditUserInfo)
+
+	if admissionRequest.Operation != admv1.Update {
+		t.Errorf("Operation diverge")
+	}
+	if admissionRequest.OldObject.Object == nil {
+		t.Fatalf("OldObject should be set")
+	}
+	oldObj, ok := admissionRequest.OldObject.Object.(*unstructured.Unstructured)
+	if !ok || oldObj.GetUID() != obj.GetUID() {
+		t.Errorf("OldObject diverge")
+	}
+}
+
+func TestDryRunAdmissionRequest(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+
+	createRequest := newAdmissionRequest(obj, podsGVR, admissionregistrationv1.Create, auditUserInfo)
+	require.NotNil(t, createRequest.DryRun)
+	assert.True(t, *createRequest.DryRun)
+	assert.Equal(t, metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}, *createRequest.RequestResource)
+	assert.Equal(t, auditUserInfo, createRequest.UserInfo)
+	createOptions, ok := createRequest.Options.Object.(*metav1.CreateOptions)
+	require.True(t, ok)
+	assert.Equal(t, []string{metav1.DryRunAll}, createOptions.DryRun)
+	assert.Equal(t, "CreateOptions", createOptions.Kind)
+
+	updateRequest := newAdmissionRequest(obj, podsGVR, admissionregistrationv1.Update, auditUserInfo)
+	updateOptions, ok := updateRequest.Options.Object.(*metav1.UpdateOptions)
+	require.True(t, ok)
+	assert.Equal(t, []string{metav1.DryRunAll}, updateOptions.DryRun)
+
+	payload, err := json.Marshal(newAdmissionReview(obj, podsGVR, admissionregistrationv1.Create, auditUserInfo))
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `"options":{"kind":"CreateOptions","apiVersion":"meta.k8s.io/v1","dryRun":["All"]}`)
+}
+
+func TestUserInfo(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name             string
+		mode             UserInfoMode
+		managedFields    []metav1.ManagedFieldsEntry
+		expectedUsername string
+		expectedGroups   []string
+	}{
+		{
+			name: "fixed",
+			mode: UserInfoModeFixed,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Time: &earlier},
+			},
+			expectedUsername: auditUserInfo.Username,
+			expectedGroups:   auditUserInfo.Groups,
+		},
+		{
+			name: "last manager",
+			mode: UserInfoModeManager,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Time: &later},
+				{Manager: "kube-controller-manager", Time: &earlier},
+			},
+			expectedUsername: "kubectl",
+			// the groups of the audit identity are not sent as the manager's
+			expectedGroups: nil,
+		},
+		{
+			name:             "no managed fields",
+			mode:             UserInfoModeManager,
+			expectedUsername: auditUserInfo.Username,
+			expectedGroups:   auditUserInfo.Groups,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := generateUnstructuredPodObject()
+			obj.SetManagedFields(test.managedFields)
+			config := UserInfoConfig{
+				Mode:     test.mode,
+				Username: auditUserInfo.Username,
+				Groups:   auditUserInfo.Groups,
+			}
+
+			userInfo := config.userInfo(obj)
+			assert.Equal(t, test.expectedUsername, userInfo.Username)
+			assert.Equal(t, test.expectedGroups, userInfo.Groups)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/retry"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errCircuitOpen is the error of the evaluations not sent because the
+// circuit of their PolicyServer is open.
+var errCircuitOpen = errors.New("circuit open")
+
+// States of a circuit.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuit tracks the failures of the evaluations sent to a PolicyServer.
+type circuit struct {
+	state string
+	// failures is the number of consecutive failed evaluations
+	failures int
+	// openedAt is when the circuit was last opened
+	openedAt time.Time
+	// probing is true while the evaluation probing a half-open circuit is in flight
+	probing bool
+	// generation is incremented at each transition of the state, so the
+	// outcome of an evaluation allowed before is ignored
+	generation uint64
+}
+
+// admission identifies an evaluation allowed by a circuit.
+type admission struct {
+	// generation is the generation of the circuit when the evaluation was allowed
+	generation uint64
+	// probe is true if the evaluation probes the half-open circuit
+	probe bool
+}
+
+// circuitBreakers holds the circuits of the PolicyServers, keyed on their
+// name. A circuit opens after FailureThreshold consecutive failed
+// evaluations: the following evaluations fail right away instead of waiting
+// for the PolicyServer to time out. Once OpenTimeout elapsed, the circuit is
+// half-open: a single evaluation is sent to probe the PolicyServer, closing
+// the circuit if it succeeds, or opening it again if it fails.
+type circuitBreakers struct {
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	logger   *slog.Logger
+}
+
+func newCircuitBreakers(config CircuitBreakerConfig, logger *slog.Logger) *circuitBreakers {
+	return &circuitBreakers{
+		config:   config,
+		circuits: make(map[string]*circuit),
+		logger:   logger,
+	}
+}
+
+// allow returns an error wrapping errCircuitOpen if an evaluation cannot be
+// sent to the given PolicyServer. Otherwise, either done must be called with
+// the returned admission and the outcome of the evaluation, or abort if it's
+// not sent.
+func (b *circuitBreakers) allow(ctx context.Context, policyServer string) (admission, error) {
+	if b.config.FailureThreshold == 0 {
+		return admission{}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(policyServer)
+	switch c.state {
+	case circuitOpen:
+		if time.Since(c.openedAt) < b.config.OpenTimeout {
+			return admission{}, fmt.Errorf("%w: PolicyServer %s failed %d consecutive evaluations", errCircuitOpen, policyServer, c.failures)
+		}
+		c.transition(circuitHalfOpen)
+		c.probing = true
+		b.logger.InfoContext(ctx, "circuit half-open, probing the PolicyServer", slog.String("policy-server", policyServer))
+		return admission{generation: c.generation, probe: true}, nil
+	case circuitHalfOpen:
+		if c.probing {
+			return admission{}, fmt.Errorf("%w: PolicyServer %s is being probed", errCircuitOpen, policyServer)
+		}
+		// the previous probe has not been sent
+		c.probing = true
+		return admission{generation: c.generation, probe: true}, nil
+	}
+	return admission{generation: c.generation}, nil
+}
+
+// done records the outcome of an evaluation allowed by the circuit of the
+// given PolicyServer. Only the network errors and the status codes of an
+// unavailable PolicyServer count as failures. The evaluations interrupted by
+// the cancellation of the context count as neither a success nor a failure,
+// like the ones allowed before the last transition of the circuit: only the
+// probe settles a half-open circuit.
+func (b *circuitBreakers) done(ctx context.Context, policyServer string, allowed admission, err error) {
+	if b.config.FailureThreshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(policyServer)
+	if allowed.generation != c.generation {
+		return
+	}
+	if allowed.probe {
+		c.probing = false
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return
+	case isPolicyServerFailure(err):
+		c.failures++
+		if allowed.probe {
+			c.transition(circuitOpen)
+			b.logger.WarnContext(ctx, "circuit reopened, the PolicyServer is still failing",
+				slog.String("policy-server", policyServer),
+				slog.String("error", err.Error()),
+				slog.Duration("open-timeout", b.config.OpenTimeout))
+		} else if c.failures >= b.config.FailureThreshold {
+			c.transition(circuitOpen)
+			b.logger.WarnContext(ctx, "circuit opened, the evaluations sent to the PolicyServer fail until it recovers",
+				slog.String("policy-server", policyServer),
+				slog.Int("failures", c.failures),
+				slog.String("error", err.Error()),
+				slog.Duration("open-timeout", b.config.OpenTimeout))
+		}
+	default:
+		c.failures = 0
+		if allowed.probe {
+			c.transition(circuitClosed)
+			b.logger.InfoContext(ctx, "circuit closed, the PolicyServer recovered", slog.String("policy-server", policyServer))
+		}
+	}
+}
+
+// abort records that an evaluation allowed by the circuit of the given
+// PolicyServer has not been sent.
+func (b *circuitBreakers) abort(policyServer string, allowed admission) {
+	if b.config.FailureThreshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(policyServer)
+	if allowed.probe && allowed.generation == c.generation {
+		c.probing = false
+	}
+}
+
+func (b *circuitBreakers) get(policyServer string) *circuit {
+	c, ok := b.circuits[policyServer]
+	if !ok {
+		c = &circuit{state: circuitClosed}
+		b.circuits[policyServer] = c
+	}
+	return c
+}
+
+// transition moves the circuit to the given state, starting a new generation.
+func (c *circuit) transition(state string) {
+	c.state = state
+	c.generation++
+	if state == circuitOpen {
+		c.openedAt = time.Now()
+	}
+}
+
+// isPolicyServerFailure returns true if the request failed without a
+// response, or with the status code of an unavailable PolicyServer. A
+// PolicyServer limiting the requests is up.
+func isPolicyServerFailure(err error) bool {
+	var transient *retry.TransientError
+	return errors.As(err, &transient) && transient.StatusCode != http.StatusTooManyRequests
+}
+
+// circuitOpenReview returns the response of an evaluation not sent because of
+// an open circuit. It carries the reason of the error result.
+func circuitOpenReview(err error) *admissionv1.AdmissionReview {
+	return &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Result: &metav1.Status{
+				Code:    http.StatusServiceUnavailable,
+				Message: err.Error(),
+			},
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// evaluate records an evaluation allowed by the circuit with the given outcome.
+func evaluate(t *testing.T, breakers *circuitBreakers, policyServer string, err error) {
+	t.Helper()
+
+	allowed, allowErr := breakers.allow(t.Context(), policyServer)
+	require.NoError(t, allowErr)
+	breakers.done(t.Context(), policyServer, allowed, err)
+}
+
+func TestCircuitBreakers(t *testing.T) {
+	unavailable := &retry.TransientError{Err: errors.New("unavailable"), StatusCode: http.StatusServiceUnavailable}
+	breakers := newCircuitBreakers(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}, slog.Default())
+
+	// the circuit opens after the consecutive failures
+	evaluate(t, breakers, "default", unavailable)
+	evaluate(t, breakers, "default", unavailable)
+	_, err := breakers.allow(t.Context(), "default")
+	require.ErrorIs(t, err, errCircuitOpen)
+	assert.Contains(t, err.Error(), "PolicyServer default failed 2 consecutive evaluations")
+
+	// the circuits are kept by PolicyServer
+	_, err = breakers.allow(t.Context(), "other")
+	require.NoError(t, err)
+
+	// once the open timeout elapsed, a single evaluation probes the PolicyServer
+	breakers.circuits["default"].openedAt = time.Now().Add(-time.Hour)
+	probe, err := breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	_, err = breakers.allow(t.Context(), "default")
+	require.ErrorIs(t, err, errCircuitOpen)
+	breakers.done(t.Context(), "default", probe, unavailable)
+	_, err = breakers.allow(t.Context(), "default")
+	require.ErrorIs(t, err, errCircuitOpen)
+
+	// a successful probe closes the circuit
+	breakers.circuits["default"].openedAt = time.Now().Add(-time.Hour)
+	evaluate(t, breakers, "default", nil)
+	assert.Equal(t, circuitClosed, breakers.circuits["default"].state)
+	assert.Equal(t, 0, breakers.circuits["default"].failures)
+
+	// a PolicyServer limiting the requests, or rejecting them, is not failing
+	for _, err := range []error{
+		&retry.TransientError{Err: errors.New("too many requests"), StatusCode: http.StatusTooManyRequests},
+		errors.New("bad request"),
+	} {
+		evaluate(t, breakers, "default", err)
+	}
+	assert.Equal(t, circuitClosed, breakers.circuits["default"].state)
+
+	// the circuit breakers are disabled with a zero threshold
+	disabled := newCircuitBreakers(CircuitBreakerConfig{}, slog.Default())
+	for range 10 {
+		evaluate(t, disabled, "default", unavailable)
+	}
+}
+
+func TestCircuitBreakersWithStaleEvaluations(t *testing.T) {
+	unavailable := &retry.TransientError{Err: errors.New("unavailable"), StatusCode: http.StatusServiceUnavailable}
+	breakers := newCircuitBreakers(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Hour}, slog.Default())
+
+	// two evaluations are in flight when the first one opens the circuit
+	first, err := breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	stale, err := breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	otherStale, err := breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	breakers.done(t.Context(), "default", first, unavailable)
+	require.Equal(t, circuitOpen, breakers.circuits["default"].state)
+
+	breakers.circuits["default"].openedAt = time.Now().Add(-time.Hour)
+	probe, err := breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+
+	// the stale evaluations completing while the circuit is half-open don't
+	// settle it, whatever their outcome
+	breakers.done(t.Context(), "default", stale, nil)
+	assert.Equal(t, circuitHalfOpen, breakers.circuits["default"].state)
+	breakers.done(t.Context(), "default", otherStale, unavailable)
+	assert.Equal(t, circuitHalfOpen, breakers.circuits["default"].state)
+	_, err = breakers.allow(t.Context(), "default")
+	require.ErrorIs(t, err, errCircuitOpen, "the probe is still in flight")
+
+	// the probe does
+	breakers.done(t.Context(), "default", probe, nil)
+	assert.Equal(t, circuitClosed, breakers.circuits["default"].state)
+
+	// an aborted probe lets another evaluation probe the circuit
+	evaluate(t, breakers, "default", unavailable)
+	breakers.circuits["default"].openedAt = time.Now().Add(-time.Hour)
+	probe, err = breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	breakers.abort("default", probe)
+	probe, err = breakers.allow(t.Context(), "default")
+	require.NoError(t, err)
+	assert.True(t, probe.probe)
+}
+
+func TestSendAdmissionReviewWithOpenCircuit(t *testing.T) {
+	var requests atomic.Int32
+	server := newFlakyMockPolicyServer(100, http.StatusBadGateway, &requests)
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	scanner, err := NewScanner(Config{
+		Retry:          RetryConfig{MaxRetries: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: time.Hour},
+		Logger:         slog.Default(),
+	})
+	require.NoError(t, err)
+
+	// the retries stop once the circuit opens
+	_, attempts, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), "default", serverURL, &admissionv1.AdmissionReview{})
+	require.ErrorIs(t, err, errCircuitOpen)
+	assert.Equal(t, 3, attempts)
+
+	// the following evaluations fail without being sent, with the reason in the response
+	admissionReview, attempts, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), "default", serverURL, &admissionv1.AdmissionReview{})
+	require.ErrorIs(t, err, errCircuitOpen)
+	assert.Equal(t, 0, attempts)
+	assert.Contains(t, admissionReview.Response.Result.Message, "circuit open")
+	assert.Equal(t, int32(3), requests.Load())
+}
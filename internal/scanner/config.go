@@ -2,8 +2,12 @@ package scanner
 
 import (
 	"log/slog"
+	"time"
 
+	"github.com/kubewarden/audit-scanner/internal/events"
 	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/metrics"
+	"github.com/kubewarden/audit-scanner/internal/output"
 	"github.com/kubewarden/audit-scanner/internal/policies"
 	"github.com/kubewarden/audit-scanner/internal/report"
 )
@@ -21,6 +25,86 @@ type TLSConfig struct {
 	ClientKeyFile  string
 }
 
+// RetryConfig configures the retries of the evaluations that failed because of
+// a transient error of the PolicyServer, like a network error or a 503 status.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// disables the retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. The delay doubles
+	// at each retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxElapsedTime caps the total time spent evaluating a policy, retries
+	// included. Zero means no cap.
+	MaxElapsedTime time.Duration
+}
+
+// RateLimit limits the evaluations sent to a PolicyServer, so the audit
+// doesn't degrade the latency of the admission requests it serves.
+type RateLimit struct {
+	// QPS is the number of evaluations per second. Zero disables the limit.
+	QPS float64
+	// Burst is the number of evaluations that can be sent at once, above QPS.
+	Burst int
+	// MaxInFlight is the number of concurrent evaluations. Zero disables the limit.
+	MaxInFlight int
+}
+
+// RateLimitConfig configures the rate limits of the PolicyServers.
+type RateLimitConfig struct {
+	// Default is the rate limit of the PolicyServers without an override.
+	Default RateLimit
+	// Overrides are the rate limits of specific PolicyServers, by name.
+	Overrides map[string]RateLimit
+}
+
+// CircuitBreakerConfig configures the circuit breakers of the PolicyServers,
+// so the evaluations sent to a failing PolicyServer fail right away instead of
+// waiting for it to time out.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed evaluations opening
+	// the circuit of a PolicyServer. Zero disables the circuit breakers.
+	FailureThreshold int
+	// OpenTimeout is the time a circuit stays open before an evaluation is
+	// sent to probe the PolicyServer.
+	OpenTimeout time.Duration
+}
+
+// PreflightConfig configures the health check of the PolicyServers performed
+// before each scan run, so the policies of the unhealthy PolicyServers are
+// errored once instead of failing the evaluation of every resource.
+type PreflightConfig struct {
+	// Enabled enables the health check.
+	Enabled bool
+	// WaitTimeout is the time given to the unhealthy PolicyServers to recover.
+	// The scan proceeds without them once it expires. Zero means the scan
+	// proceeds without them right away.
+	WaitTimeout time.Duration
+}
+
+// UserInfoMode selects the user sending the simulated admission requests.
+type UserInfoMode string
+
+const (
+	// UserInfoModeFixed sends all the admission requests as the audit identity.
+	UserInfoModeFixed UserInfoMode = "fixed"
+	// UserInfoModeManager sends the admission requests as the manager that last
+	// changed the audited resource, according to its managedFields.
+	UserInfoModeManager UserInfoMode = "manager"
+)
+
+// UserInfoConfig configures the user sending the simulated admission requests,
+// so the policies inspecting the requesting user behave like at admission.
+type UserInfoConfig struct {
+	Mode UserInfoMode
+	// Username and Groups are the audit identity. In manager mode, they are
+	// used when the resource has no managedFields, and the groups are always
+	// those of the audit identity.
+	Username string
+	Groups   []string
+}
+
 type Config struct {
 	PoliciesClient *policies.Client
 	K8sClient      *k8s.Client
@@ -30,9 +114,35 @@ type Config struct {
 
 	TLS             TLSConfig
 	Parallelization ParallelizationConfig
+	Retry           RetryConfig
+	CircuitBreaker  CircuitBreakerConfig
+	RateLimit       RateLimitConfig
+	Preflight       PreflightConfig
+	UserInfo        UserInfoConfig
+	// ResourceFilter restricts the resources audited by the scans.
+	ResourceFilter ResourceFilter
 
-	OutputScan   bool
+	// ResultStream receives the reports of the audited resources. The reports
+	// are not streamed when nil.
+	ResultStream *output.Stream
 	DisableStore bool
+	// Incremental enables the reuse of the results computed by the previous
+	// scan for resources and policies that did not change since then.
+	Incremental bool
+	// Diff enables the comparison of the results with the reports of the
+	// previous scan, before they are overwritten.
+	Diff bool
+
+	// DrainTimeout is the time given to the audits in flight to complete,
+	// and to write their reports, when the scan is interrupted.
+	DrainTimeout time.Duration
+
+	// Events emits the Kubernetes Events of the failing results. The events are
+	// not emitted when nil.
+	Events *events.Recorder
+
+	// Metrics collects the Prometheus metrics. Metrics are disabled when nil.
+	Metrics *metrics.Metrics
 
 	Logger *slog.Logger
 }
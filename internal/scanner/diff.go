@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// recordChanges compares the results of a resource with the report of its
+// previous scan, before the report is overwritten. The changes are logged,
+// streamed and collected in the run summary.
+func (s *Scanner) recordChanges(ctx context.Context, runUID string, auditReport, previousReport report.Report) {
+	if !s.diff || s.disableStore {
+		return
+	}
+	runSummary := summary.FromContext(ctx)
+	if previousReport == nil || auditReport.GetScope() == nil {
+		// a resource audited for the first time has no changes
+		runSummary.AddChanges()
+		return
+	}
+
+	changes := summary.DiffResults(*auditReport.GetScope(), previousReport.GetResults(), auditReport.GetResults())
+	for _, change := range changes {
+		s.writeChange(ctx, runUID, change)
+	}
+	runSummary.AddChanges(changes...)
+}
+
+// recordDisappeared records the resources audited by the previous scan of the
+// namespace, or of the cluster-wide resources, but not found anymore. It must
+// be called before their reports are deleted.
+func (s *Scanner) recordDisappeared(ctx context.Context, runUID, namespace string, clusterWide bool) {
+	if !s.diff || s.disableStore {
+		return
+	}
+	lister, ok := s.reportStore.(report.StaleLister)
+	if !ok {
+		s.logger.WarnContext(ctx, "the report store cannot list the reports of the previous scan, the disappeared resources are not reported")
+		return
+	}
+
+	var err error
+	var resources []corev1.ObjectReference
+	if clusterWide {
+		resources, err = lister.ListStaleClusterResources(ctx, runUID)
+	} else {
+		resources, err = lister.ListStaleResources(ctx, runUID, namespace)
+	}
+	if err != nil {
+		s.logger.WarnContext(ctx, "cannot list the reports of the previous scan, the disappeared resources are not reported",
+			slog.String("error", err.Error()),
+			slog.String("namespace", namespace))
+		return
+	}
+
+	changes := make([]summary.Change, 0, len(resources))
+	for _, resource := range resources {
+		change := summary.DisappearedResource(resource)
+		s.writeChange(ctx, runUID, change)
+		changes = append(changes, change)
+	}
+	summary.FromContext(ctx).AddChanges(changes...)
+}
+
+func (s *Scanner) writeChange(ctx context.Context, runUID string, change summary.Change) {
+	if change.Kind == summary.ChangeDisappeared {
+		s.logger.InfoContext(ctx, "resource disappeared since the previous scan",
+			slog.String("resource", change.Resource),
+			slog.String("RunUID", runUID))
+	} else {
+		s.logger.InfoContext(ctx, "result changed since the previous scan",
+			slog.String("change", change.Kind),
+			slog.String("resource", change.Resource),
+			slog.String("policy", change.Policy),
+			slog.String("from", change.From),
+			slog.String("to", change.To),
+			slog.String("RunUID", runUID))
+	}
+	if err := s.resultStream.WriteChange(runUID, change); err != nil {
+		s.logger.ErrorContext(ctx, "error writing the change to the result stream", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,169 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"golang.org/x/sync/semaphore"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ScanManifests audits resources read from manifests, rather than from the
+// cluster, with the policies deployed in the cluster. The reports are never
+// stored, since the resources don't exist in the cluster: they can be printed
+// with the output scan option.
+// The namespaces of the resources are looked up among the given resources
+// first, then in the cluster. Namespaced resources without a namespace are
+// audited as if they were in the default namespace.
+func (s *Scanner) ScanManifests(ctx context.Context, resources []unstructured.Unstructured, runUID string) error {
+	s.logger.InfoContext(ctx, "manifests scan started",
+		slog.String("RunUID", runUID),
+		slog.Int("resources", len(resources)))
+
+	// the scanner used to audit the manifests never stores the reports, nor
+	// emits events on resources that don't exist
+	offline := *s
+	offline.disableStore = true
+	offline.incremental = false
+	offline.events = nil
+
+	clusterPolicies, err := s.policiesClient.GetClusterWidePolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
+	}
+	runSummary := summary.FromContext(ctx)
+	runSummary.AddErroredPolicies(clusterPolicies.ErroredPolicies)
+	runSummary.AddSkippedPolicies(clusterPolicies.SkippedPolicies)
+	s.selectResources(ctx, clusterPolicies.PoliciesByGVR)
+
+	manifestNamespaces, err := getManifestNamespaces(resources)
+	if err != nil {
+		return err
+	}
+	policiesByNamespace := make(map[string]*policies.Policies)
+
+	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
+	var workers sync.WaitGroup
+	drainCtx, cancelDrain := s.drainContext(ctx)
+	defer cancelDrain()
+
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+		gvr, err := s.policiesClient.GetGroupVersionResource(gvk)
+		if err != nil {
+			s.logger.WarnContext(ctx, "cannot audit resource of unknown kind",
+				slog.String("error", err.Error()),
+				slog.String("resource", resource.GetName()))
+			runSummary.AddListFailure(gvk.String(), resource.GetNamespace(), err)
+			continue
+		}
+		namespaced, err := s.policiesClient.IsNamespacedResource(gvr)
+		if err != nil {
+			return fmt.Errorf("failed to check if resource %s is namespaced: %w", gvr.String(), err)
+		}
+		s.metrics.RecordResourceListed(gvr.String())
+
+		auditablePolicies := clusterPolicies
+		resource := *resource.DeepCopy()
+		if namespaced {
+			if resource.GetNamespace() == "" {
+				resource.SetNamespace(metav1.NamespaceDefault)
+			}
+			nsName := resource.GetNamespace()
+			if _, found := policiesByNamespace[nsName]; !found {
+				namespace, err := s.getManifestNamespace(ctx, manifestNamespaces, nsName)
+				if err != nil {
+					return err
+				}
+				namespacePolicies, err := s.policiesClient.GetPoliciesByNamespace(ctx, namespace)
+				if err != nil {
+					return fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", nsName, err)
+				}
+				runSummary.AddNamespace()
+				runSummary.AddErroredPolicies(namespacePolicies.ErroredPolicies)
+				runSummary.AddSkippedPolicies(namespacePolicies.SkippedPolicies)
+				s.selectResources(ctx, namespacePolicies.PoliciesByGVR)
+				policiesByNamespace[nsName] = namespacePolicies
+			}
+			auditablePolicies = policiesByNamespace[nsName]
+		}
+
+		pols, found := auditablePolicies.PoliciesByGVR[gvr]
+		if !found {
+			s.logger.DebugContext(ctx, "no policies target the resource, skipping...",
+				slog.String("resource", resource.GetName()),
+				slog.String("resource-GVR", gvr.String()),
+				slog.String("ns", resource.GetNamespace()))
+			continue
+		}
+
+		if err := semaphore.Acquire(ctx, 1); err != nil {
+			break
+		}
+		workers.Add(1)
+
+		go func() {
+			defer semaphore.Release(1)
+			defer workers.Done()
+
+			if !namespaced {
+				offline.auditClusterResource(drainCtx, pols, resource, gvr, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum, auditablePolicies.Selects)
+				return
+			}
+			if err := offline.auditResource(drainCtx, pols, resource, gvr, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum, auditablePolicies.Selects); err != nil {
+				s.logger.ErrorContext(ctx, "error auditing resource",
+					slog.String("error", err.Error()),
+					slog.String("RunUID", runUID))
+			}
+		}()
+	}
+	workers.Wait()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("manifests scan interrupted: %w", ctx.Err())
+	}
+	s.logger.InfoContext(ctx, "manifests scan finished")
+	return nil
+}
+
+// getManifestNamespace returns the namespace with the given name, looking it
+// up among the manifests first, then in the cluster. A namespace that doesn't
+// exist yet is returned without labels.
+func (s *Scanner) getManifestNamespace(ctx context.Context, manifestNamespaces map[string]*corev1.Namespace, nsName string) (*corev1.Namespace, error) {
+	if namespace, found := manifestNamespaces[nsName]; found {
+		return namespace, nil
+	}
+
+	namespace, err := s.k8sClient.GetNamespace(ctx, nsName)
+	if err != nil {
+		if apimachineryerrors.IsNotFound(err) {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: nsName}}, nil
+		}
+		return nil, fmt.Errorf("failed to get namespace %s: %w", nsName, err)
+	}
+	return namespace, nil
+}
+
+// getManifestNamespaces returns the namespaces defined in the manifests, by name.
+func getManifestNamespaces(resources []unstructured.Unstructured) (map[string]*corev1.Namespace, error) {
+	namespaces := make(map[string]*corev1.Namespace)
+	for _, resource := range resources {
+		if resource.GroupVersionKind() != corev1.SchemeGroupVersion.WithKind("Namespace") {
+			continue
+		}
+		namespace := &corev1.Namespace{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, namespace); err != nil {
+			return nil, fmt.Errorf("failed to convert namespace %s: %w", resource.GetName(), err)
+		}
+		namespaces[namespace.GetName()] = namespace
+	}
+	return namespaces, nil
+}
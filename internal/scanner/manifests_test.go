@@ -0,0 +1,153 @@
+package scanner
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/manifests"
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	auditscheme "github.com/kubewarden/audit-scanner/internal/scheme"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+const testManifests = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: staging
+  labels:
+    env: staging
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  namespace: staging
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: busybox
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: unknown
+`
+
+func TestScanManifests(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a ClusterAdmissionPolicy targeting the pods of the staging namespace,
+	// selected by the labels of the namespace defined in the manifests
+	stagingClusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("stagingClusterAdmissionPolicy").
+		NamespaceSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"env": "staging"},
+		}).
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme)
+	clientset := fake.NewSimpleClientset()
+	client, err := testutils.NewFakeClient(
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+		stagingClusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+
+	resources, err := manifests.Decode(strings.NewReader(testManifests), "test")
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	runSummary := summary.NewRunSummary(runUID, summary.ScopeManifests, "")
+	err = scanner.ScanManifests(summary.NewContext(t.Context(), runSummary), resources, runUID)
+	require.NoError(t, err)
+
+	// the namespace and the pod without namespace are evaluated by one policy,
+	// the pod in the staging namespace by both
+	assert.Equal(t, int32(4), evaluations.Load())
+	data := runSummary.Data()
+	assert.Equal(t, 3, data.ResourcesAudited)
+	assert.Equal(t, report.Summary{Pass: 4}, data.Results)
+	require.Len(t, data.ListFailures, 1)
+	assert.Equal(t, "example.com/v1, Kind=Unknown", data.ListFailures[0].Resource)
+
+	// the reports of the manifests are not stored
+	policyReports := wgpolicy.PolicyReportList{}
+	require.NoError(t, client.List(t.Context(), &policyReports))
+	assert.Empty(t, policyReports.Items)
+	clusterPolicyReports := wgpolicy.ClusterPolicyReportList{}
+	require.NoError(t, client.List(t.Context(), &clusterPolicyReports))
+	assert.Empty(t, clusterPolicyReports.Items)
+}
@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kubewarden/audit-scanner/internal/policies"
+)
+
+// policyCache holds the policies grouped by GVR of each scope audited by
+// ScanResource, keyed on the namespace name, or on "" for the cluster-wide
+// resources. The policies are fetched on first use, and dropped when they
+// change.
+type policyCache struct {
+	mu sync.Mutex
+	// generation changes every time the cache is invalidated, so the policies
+	// fetched before are not cached
+	generation uint64
+	policies   map[string]*policies.Policies
+}
+
+func newPolicyCache() *policyCache {
+	return &policyCache{
+		policies: make(map[string]*policies.Policies),
+	}
+}
+
+func (c *policyCache) get(scope string) (*policies.Policies, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	auditablePolicies, found := c.policies[scope]
+	return auditablePolicies, c.generation, found
+}
+
+// add caches the policies of the scope, unless the cache has been invalidated
+// since the given generation.
+func (c *policyCache) add(scope string, generation uint64, auditablePolicies *policies.Policies) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation == c.generation {
+		c.policies[scope] = auditablePolicies
+	}
+}
+
+func (c *policyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.policies)
+}
+
+// InvalidatePolicies drops the policies cached by ScanResource, so the next
+// resources are audited with the policies currently deployed. It must be
+// called when the policies, or the labels of the namespaces, change.
+func (s *Scanner) InvalidatePolicies() {
+	s.policyCache.invalidate()
+}
+
+// getScopePolicies returns the auditable policies of the namespace, or the
+// cluster-wide ones when nsName is empty, from the cache when possible.
+func (s *Scanner) getScopePolicies(ctx context.Context, nsName string) (*policies.Policies, error) {
+	auditablePolicies, generation, found := s.policyCache.get(nsName)
+	if found {
+		return auditablePolicies, nil
+	}
+
+	if nsName == "" {
+		var err error
+		auditablePolicies, err = s.policiesClient.GetClusterWidePolicies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
+		}
+	} else {
+		namespace, err := s.k8sClient.GetNamespace(ctx, nsName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace %s: %w", nsName, err)
+		}
+		auditablePolicies, err = s.policiesClient.GetPoliciesByNamespace(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", nsName, err)
+		}
+	}
+	s.policyCache.add(nsName, generation, auditablePolicies)
+
+	return auditablePolicies, nil
+}
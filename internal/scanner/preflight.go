@@ -0,0 +1,131 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+)
+
+// preflightInterval is the delay between the health checks of the
+// PolicyServers while waiting for them to recover
+const preflightInterval = 5 * time.Second
+
+// Preflight checks the health of the PolicyServers running the audited
+// policies, if enabled. A PolicyServer is healthy when its Deployment has ready
+// replicas and its audit endpoint answers. The unhealthy PolicyServers are
+// given the configured time to recover, then their policies are recorded once
+// as errored in the run summary and they are not evaluated by the scan.
+// It returns an error only if the context is canceled while waiting.
+func (s *Scanner) Preflight(ctx context.Context) error {
+	if !s.preflight.Enabled {
+		return nil
+	}
+
+	deadline := time.Now().Add(s.preflight.WaitTimeout)
+	var unhealthy []policies.PolicyServerHealth
+	for waiting := false; ; waiting = true {
+		var err error
+		unhealthy, err = s.checkPolicyServers(ctx)
+		if ctx.Err() != nil {
+			return fmt.Errorf("PolicyServers health check interrupted: %w", ctx.Err())
+		}
+		if err != nil {
+			// the scan is not prevented by a failure of the health check
+			s.logger.WarnContext(ctx, "cannot check the health of the PolicyServers, auditing all the policies",
+				slog.String("error", err.Error()))
+			s.policiesClient.SetUnhealthyPolicyServers(nil)
+			return nil
+		}
+		if len(unhealthy) == 0 || time.Now().Add(preflightInterval).After(deadline) {
+			break
+		}
+
+		if !waiting {
+			s.logger.InfoContext(ctx, "waiting for the unhealthy PolicyServers to recover",
+				slog.String("policy-servers", policyServerNames(unhealthy)),
+				slog.Duration("wait-timeout", s.preflight.WaitTimeout))
+		}
+		timer := time.NewTimer(preflightInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("PolicyServers health check interrupted: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	runSummary := summary.FromContext(ctx)
+	reasons := make(map[string]string, len(unhealthy))
+	for _, health := range unhealthy {
+		s.logger.ErrorContext(ctx, "the PolicyServer is unhealthy, its policies are not audited",
+			slog.String("policy-server", health.Name),
+			slog.String("reason", health.Reason),
+			slog.Any("policies", health.Policies))
+		runSummary.AddUnhealthyPolicyServer(health.Name, health.Reason)
+		runSummary.AddErroredPolicies(health.Policies)
+		reasons[health.Name] = health.Reason
+	}
+	s.policiesClient.SetUnhealthyPolicyServers(reasons)
+	return nil
+}
+
+// checkPolicyServers returns the unhealthy PolicyServers running the audited
+// policies.
+func (s *Scanner) checkPolicyServers(ctx context.Context) ([]policies.PolicyServerHealth, error) {
+	healths, err := s.policiesClient.CheckPolicyServers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the PolicyServers: %w", err)
+	}
+
+	var unhealthy []policies.PolicyServerHealth
+	for _, health := range healths {
+		if health.Healthy() {
+			if err := s.probeAuditEndpoint(ctx, health.AuditURL); err != nil {
+				health.Reason = err.Error()
+			}
+		}
+		if !health.Healthy() {
+			unhealthy = append(unhealthy, health)
+		}
+	}
+	return unhealthy, nil
+}
+
+// probeAuditEndpoint sends an empty request to the given audit endpoint. Any
+// answer means that the PolicyServer is up, even if the request is rejected,
+// unless it's a status code of an unavailable server.
+func (s *Scanner) probeAuditEndpoint(ctx context.Context, url *url.URL) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), strings.NewReader("{}"))
+	if err != nil {
+		return fmt.Errorf("failed to build the audit endpoint request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("the audit endpoint is unreachable: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	// a PolicyServer limiting the requests is up
+	if res.StatusCode != http.StatusTooManyRequests && isRetryableStatusCode(res.StatusCode) {
+		return fmt.Errorf("the audit endpoint is unavailable: status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+func policyServerNames(healths []policies.PolicyServerHealth) string {
+	names := make([]string, 0, len(healths))
+	for _, health := range healths {
+		names = append(names, health.Name)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// previousReportsKey is the key of the reports of the previous scans in the
+// context of the scan of a namespace, or of the cluster-wide resources.
+type previousReportsKey struct{}
+
+// readsPreviousReports returns true if the reports of the previous scans are
+// read before being overwritten.
+func (s *Scanner) readsPreviousReports() bool {
+	return (s.incremental || s.partial || s.diff) && !s.disableStore
+}
+
+// withPreviousReports returns a context carrying the reports of the previous
+// scans of the namespace, or of the cluster-wide resources, listed with a
+// single request when the store can list them. Otherwise, the report of each
+// resource is read when it's audited.
+func (s *Scanner) withPreviousReports(ctx context.Context, namespace string, clusterWide bool) context.Context {
+	if !s.readsPreviousReports() {
+		return ctx
+	}
+	lister, ok := s.reportStore.(report.ReportLister)
+	if !ok {
+		return ctx
+	}
+
+	var reports map[types.UID]report.Report
+	var err error
+	if clusterWide {
+		reports, err = lister.ListClusterReports(ctx)
+	} else {
+		reports, err = lister.ListReports(ctx, namespace)
+	}
+	if err != nil {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			s.logger.WarnContext(ctx, "cannot list the reports of the previous scan, reading the report of each resource",
+				slog.String("error", err.Error()),
+				slog.String("namespace", namespace))
+		}
+		return ctx
+	}
+
+	return context.WithValue(ctx, previousReportsKey{}, reports)
+}
+
+// getPreviousReport returns the report stored by the previous scan of the given
+// resource. It returns nil when the scan is neither incremental nor partial and
+// doesn't compare the results, or when there's no previous report. The report
+// is looked up in the reports carried by the context, when they were listed.
+func (s *Scanner) getPreviousReport(ctx context.Context, resource unstructured.Unstructured, clusterWide bool) report.Report {
+	if !s.readsPreviousReports() {
+		return nil
+	}
+	if reports, ok := ctx.Value(previousReportsKey{}).(map[types.UID]report.Report); ok {
+		return reports[resource.GetUID()]
+	}
+
+	var previousReport report.Report
+	var err error
+	if clusterWide {
+		previousReport, err = s.reportStore.GetClusterReport(ctx, resource)
+	} else {
+		previousReport, err = s.reportStore.GetReport(ctx, resource)
+	}
+	if err != nil {
+		if !errors.Is(err, constants.ErrResourceNotFound) {
+			s.logger.WarnContext(ctx, "cannot get the report of the previous scan, evaluating all the policies",
+				slog.String("error", err.Error()),
+				slog.String("resource", resource.GetName()))
+		}
+		return nil
+	}
+
+	return previousReport
+}
+
+// hasStoredReport returns true if the store has a report of the given resource,
+// written by a previous scan.
+func (s *Scanner) hasStoredReport(ctx context.Context, resource unstructured.Unstructured) bool {
+	if s.disableStore {
+		return false
+	}
+
+	var err error
+	if resource.GetNamespace() == "" {
+		_, err = s.reportStore.GetClusterReport(ctx, resource)
+	} else {
+		_, err = s.reportStore.GetReport(ctx, resource)
+	}
+	if err != nil && !errors.Is(err, constants.ErrResourceNotFound) {
+		s.logger.WarnContext(ctx, "cannot get the report of the previous scan",
+			slog.String("error", err.Error()),
+			slog.String("resource", resource.GetName()))
+	}
+
+	return err == nil
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// policyServerLimiter limits the evaluations sent to a single PolicyServer.
+type policyServerLimiter struct {
+	// limiter is nil when the rate is not limited
+	limiter *rate.Limiter
+	// inFlight is nil when the concurrent evaluations are not limited
+	inFlight *semaphore.Weighted
+}
+
+// rateLimiters holds the limiters of the PolicyServers, keyed on their name,
+// like the overrides. The PolicyServers sharing a host, like with
+// --policy-server-url, are limited separately. The limiters are created on
+// first use.
+type rateLimiters struct {
+	config   RateLimitConfig
+	mu       sync.Mutex
+	limiters map[string]*policyServerLimiter
+}
+
+func newRateLimiters(config RateLimitConfig) *rateLimiters {
+	return &rateLimiters{
+		config:   config,
+		limiters: make(map[string]*policyServerLimiter),
+	}
+}
+
+// acquire blocks until an evaluation can be sent to the given PolicyServer.
+// The returned function must be called once the evaluation is done.
+func (r *rateLimiters) acquire(ctx context.Context, policyServer string) (func(), error) {
+	limiter := r.get(policyServer)
+
+	if limiter.inFlight != nil {
+		if err := limiter.inFlight.Acquire(ctx, 1); err != nil {
+			return nil, fmt.Errorf("cannot acquire in-flight slot of PolicyServer %s: %w", policyServer, err)
+		}
+	}
+	release := func() {
+		if limiter.inFlight != nil {
+			limiter.inFlight.Release(1)
+		}
+	}
+
+	if limiter.limiter != nil {
+		if err := limiter.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, fmt.Errorf("rate limit of PolicyServer %s: %w", policyServer, err)
+		}
+	}
+
+	return release, nil
+}
+
+func (r *rateLimiters) get(policyServer string) *policyServerLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if limiter, ok := r.limiters[policyServer]; ok {
+		return limiter
+	}
+
+	rateLimit := r.config.Default
+	if override, ok := r.config.Overrides[policyServer]; ok {
+		rateLimit = override
+	}
+
+	limiter := &policyServerLimiter{}
+	if rateLimit.QPS > 0 {
+		limiter.limiter = rate.NewLimiter(rate.Limit(rateLimit.QPS), max(rateLimit.Burst, 1))
+	}
+	if rateLimit.MaxInFlight > 0 {
+		limiter.inFlight = semaphore.NewWeighted(int64(rateLimit.MaxInFlight))
+	}
+	r.limiters[policyServer] = limiter
+
+	return limiter
+}
+
+// ParseRateLimitOverride parses a PolicyServer rate limit override in the
+// `<PolicyServer name>=<qps>:<burst>:<max in flight>` format.
+func ParseRateLimitOverride(override string) (string, RateLimit, error) {
+	policyServer, limits, found := strings.Cut(override, "=")
+	if !found || policyServer == "" {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit override %q, expected <PolicyServer name>=<qps>:<burst>:<max in flight>", override)
+	}
+
+	values := strings.Split(limits, ":")
+	if len(values) != 3 { //nolint:mnd // qps, burst and max in flight
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit override %q, expected <PolicyServer name>=<qps>:<burst>:<max in flight>", override)
+	}
+	qps, err := strconv.ParseFloat(values[0], 64)
+	if err != nil || qps < 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid QPS in rate limit override %q", override)
+	}
+	burst, err := strconv.Atoi(values[1])
+	if err != nil || burst < 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid burst in rate limit override %q", override)
+	}
+	maxInFlight, err := strconv.Atoi(values[2])
+	if err != nil || maxInFlight < 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid max in flight in rate limit override %q", override)
+	}
+
+	return policyServer, RateLimit{QPS: qps, Burst: burst, MaxInFlight: maxInFlight}, nil
+}
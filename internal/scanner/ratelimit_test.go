@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitersMaxInFlight(t *testing.T) {
+	limiters := newRateLimiters(RateLimitConfig{
+		Default: RateLimit{MaxInFlight: 2},
+		Overrides: map[string]RateLimit{
+			"critical": {MaxInFlight: 1},
+		},
+	})
+
+	tests := []struct {
+		name                string
+		policyServer        string
+		expectedMaxInFlight int
+	}{
+		{"default rate limit", "default", 2},
+		{"overridden rate limit", "critical", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			inFlight := 0
+			maxInFlight := 0
+
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release, err := limiters.acquire(t.Context(), test.policyServer)
+					assert.NoError(t, err)
+					defer release()
+
+					mu.Lock()
+					inFlight++
+					maxInFlight = max(maxInFlight, inFlight)
+					mu.Unlock()
+
+					time.Sleep(5 * time.Millisecond)
+
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, test.expectedMaxInFlight, maxInFlight)
+		})
+	}
+}
+
+func TestRateLimitersQPS(t *testing.T) {
+	limiters := newRateLimiters(RateLimitConfig{Default: RateLimit{QPS: 20, Burst: 1}})
+
+	start := time.Now()
+	for range 3 {
+		release, err := limiters.acquire(t.Context(), "default")
+		require.NoError(t, err)
+		release()
+	}
+
+	// the first request is allowed by the burst, the other two wait 50ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestRateLimitersCanceledContext(t *testing.T) {
+	limiters := newRateLimiters(RateLimitConfig{Default: RateLimit{MaxInFlight: 1}})
+
+	release, err := limiters.acquire(t.Context(), "default")
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = limiters.acquire(ctx, "default")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseRateLimitOverride(t *testing.T) {
+	tests := []struct {
+		override             string
+		expectedPolicyServer string
+		expectedRateLimit    RateLimit
+		expectedError        bool
+	}{
+		{"default=10:20:5", "default", RateLimit{QPS: 10, Burst: 20, MaxInFlight: 5}, false},
+		{"critical=0.5:1:0", "critical", RateLimit{QPS: 0.5, Burst: 1, MaxInFlight: 0}, false},
+		{"default", "", RateLimit{}, true},
+		{"=10:20:5", "", RateLimit{}, true},
+		{"default=10:20", "", RateLimit{}, true},
+		{"default=fast:20:5", "", RateLimit{}, true},
+		{"default=10:-1:5", "", RateLimit{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.override, func(t *testing.T) {
+			policyServer, rateLimit, err := ParseRateLimitOverride(test.override)
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPolicyServer, policyServer)
+			assert.Equal(t, test.expectedRateLimit, rateLimit)
+		})
+	}
+}
+
+func TestRateLimitersSharedHost(t *testing.T) {
+	limiters := newRateLimiters(RateLimitConfig{
+		Default: RateLimit{MaxInFlight: 2},
+		Overrides: map[string]RateLimit{
+			"critical": {MaxInFlight: 1},
+		},
+	})
+
+	// the PolicyServers reached on the same host, like with --policy-server-url,
+	// keep their own limits whatever the order of the evaluations
+	releaseDefault, err := limiters.acquire(t.Context(), "default")
+	require.NoError(t, err)
+	defer releaseDefault()
+	releaseCritical, err := limiters.acquire(t.Context(), "critical")
+	require.NoError(t, err)
+	defer releaseCritical()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiters.acquire(ctx, "critical")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	release, err := limiters.acquire(t.Context(), "default")
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceFilter restricts the resources audited by the scans. The entries are
+// shell patterns matching either:
+//   - the resource name, optionally qualified by its group: "deployments", "deployments.apps"
+//   - the GroupVersionResource: "apps/v1/deployments", "v1/pods"
+//   - the kind, optionally qualified by its group: "Deployment", "Deployment.apps"
+type ResourceFilter struct {
+	// Include lists the audited resources. All the resources are audited when empty
+	Include []string
+	// Exclude lists the resources never audited. It takes precedence over Include
+	Exclude []string
+}
+
+// Validate checks that all the entries of the filter are valid patterns.
+func (r ResourceFilter) Validate() error {
+	// the patterns are matched like the ones bounding the wildcard rules
+	return policies.ResourceFilter(r).Validate()
+}
+
+// IsEmpty returns true if the filter doesn't restrict the audited resources.
+func (r ResourceFilter) IsEmpty() bool {
+	return policies.ResourceFilter(r).IsEmpty()
+}
+
+// allows returns true if a resource known by any of the given names is audited.
+func (r ResourceFilter) allows(names []string) bool {
+	return policies.ResourceFilter(r).Allows(names...)
+}
+
+// selectResources removes from policiesByGVR the resources excluded by the
+// resource filter, recording them in the run summary.
+func (s *Scanner) selectResources(ctx context.Context, policiesByGVR map[schema.GroupVersionResource][]*policies.Policy) {
+	if s.resourceFilter.IsEmpty() {
+		return
+	}
+
+	for gvr := range policiesByGVR {
+		if s.resourceFilter.allows(s.resourceFilterNames(gvr)) {
+			continue
+		}
+		s.logger.DebugContext(ctx, "resource excluded from the scan, skipping...",
+			slog.String("resource-GVR", gvr.String()))
+		delete(policiesByGVR, gvr)
+		summary.FromContext(ctx).AddExcludedResource(gvr.String())
+	}
+}
+
+// resourceFilterNames returns the names the resource filter patterns are
+// matched against.
+func (s *Scanner) resourceFilterNames(gvr schema.GroupVersionResource) []string {
+	names := []string{
+		gvr.Resource,
+		gvr.GroupResource().String(),
+		gvr.GroupVersion().String() + "/" + gvr.Resource,
+	}
+
+	gvk, err := s.policiesClient.GetGroupVersionKind(gvr)
+	if err != nil {
+		s.logger.Debug("cannot get the kind of the resource, matching it by resource only",
+			slog.String("error", err.Error()),
+			slog.String("resource-GVR", gvr.String()))
+		return names
+	}
+
+	return append(names, gvk.Kind, gvk.GroupKind().String())
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/summary"
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSelectResources(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	deploymentsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	namespacesGVR := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+
+	tests := []struct {
+		name              string
+		resourceFilter    ResourceFilter
+		expectedGVRs      []schema.GroupVersionResource
+		expectedExclusion []string
+	}{
+		{
+			name:         "no filter",
+			expectedGVRs: []schema.GroupVersionResource{podsGVR, deploymentsGVR, namespacesGVR},
+		},
+		{
+			name:              "include by kind",
+			resourceFilter:    ResourceFilter{Include: []string{"Deployment", "Pod"}},
+			expectedGVRs:      []schema.GroupVersionResource{podsGVR, deploymentsGVR},
+			expectedExclusion: []string{namespacesGVR.String()},
+		},
+		{
+			name:              "include by group-qualified resource pattern",
+			resourceFilter:    ResourceFilter{Include: []string{"*.apps"}},
+			expectedGVRs:      []schema.GroupVersionResource{deploymentsGVR},
+			expectedExclusion: []string{namespacesGVR.String(), podsGVR.String()},
+		},
+		{
+			name:              "exclude by GVR",
+			resourceFilter:    ResourceFilter{Exclude: []string{"v1/*"}},
+			expectedGVRs:      []schema.GroupVersionResource{deploymentsGVR},
+			expectedExclusion: []string{namespacesGVR.String(), podsGVR.String()},
+		},
+		{
+			name:              "exclude takes precedence",
+			resourceFilter:    ResourceFilter{Include: []string{"Deployment.apps", "pods"}, Exclude: []string{"apps/*/deployments"}},
+			expectedGVRs:      []schema.GroupVersionResource{podsGVR},
+			expectedExclusion: []string{namespacesGVR.String(), deploymentsGVR.String()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := testutils.NewFakeClient()
+			require.NoError(t, err)
+			policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", "", slog.Default())
+
+			config := newTestConfig(policiesClient, nil, nil)
+			config.ResourceFilter = test.resourceFilter
+			scanner, err := NewScanner(config)
+			require.NoError(t, err)
+
+			policiesByGVR := map[schema.GroupVersionResource][]*policies.Policy{
+				podsGVR:        nil,
+				deploymentsGVR: nil,
+				namespacesGVR:  nil,
+			}
+			runSummary := summary.NewRunSummary("run-uid", summary.ScopeAll, "")
+			scanner.selectResources(summary.NewContext(t.Context(), runSummary), policiesByGVR)
+
+			var gvrs []schema.GroupVersionResource
+			for gvr := range policiesByGVR {
+				gvrs = append(gvrs, gvr)
+			}
+			assert.ElementsMatch(t, test.expectedGVRs, gvrs)
+			assert.Equal(t, test.expectedExclusion, runSummary.Data().ExcludedResources)
+		})
+	}
+}
+
+func TestNewScannerWithInvalidResourceFilter(t *testing.T) {
+	config := newTestConfig(nil, nil, nil)
+	config.ResourceFilter = ResourceFilter{Include: []string{"[pods"}}
+
+	_, err := NewScanner(config)
+	require.Error(t, err)
+}
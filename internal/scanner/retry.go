@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/retry"
+)
+
+// isRetryable returns true if the request failed because of a transient error
+// and the context is still valid.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return retry.IsTransient(err)
+}
+
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the given retry, spreading the retries of
+// concurrent evaluations over time.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	return retry.Backoff(c.InitialBackoff, c.MaxBackoff, attempt)
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// newFlakyMockPolicyServer returns a PolicyServer failing the first requests
+// with the given status code.
+func newFlakyMockPolicyServer(failures int32, statusCode int, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= failures {
+			writer.WriteHeader(statusCode)
+			return
+		}
+
+		response, err := json.Marshal(admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		})
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = writer.Write(response)
+	}))
+}
+
+func TestSendAdmissionReviewWithRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int32
+		statusCode       int
+		retry            RetryConfig
+		expectedError    bool
+		expectedAttempts int
+	}{
+		{
+			name:             "retryable failures",
+			failures:         2,
+			statusCode:       http.StatusServiceUnavailable,
+			retry:            RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+			expectedError:    false,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retries exhausted",
+			failures:         5,
+			statusCode:       http.StatusTooManyRequests,
+			retry:            RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+			expectedError:    true,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "non retryable failure",
+			failures:         1,
+			statusCode:       http.StatusBadRequest,
+			retry:            RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries disabled",
+			failures:         1,
+			statusCode:       http.StatusBadGateway,
+			retry:            RetryConfig{},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "max elapsed time exceeded",
+			failures:         5,
+			statusCode:       http.StatusGatewayTimeout,
+			retry:            RetryConfig{MaxRetries: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxElapsedTime: time.Minute},
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := newFlakyMockPolicyServer(test.failures, test.statusCode, &requests)
+			defer server.Close()
+			serverURL, err := url.Parse(server.URL)
+			require.NoError(t, err)
+
+			scanner, err := NewScanner(Config{Retry: test.retry, Logger: slog.Default()})
+			require.NoError(t, err)
+
+			admissionReview, attempts, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), "default", serverURL, &admissionv1.AdmissionReview{})
+			if test.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, admissionReview.Response.Allowed)
+			}
+			assert.Equal(t, test.expectedAttempts, attempts)
+			assert.Equal(t, int32(test.expectedAttempts), requests.Load())
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	retry := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt, expected := range map[int]time.Duration{
+		1:   100 * time.Millisecond,
+		2:   200 * time.Millisecond,
+		3:   400 * time.Millisecond,
+		5:   time.Second,
+		100: time.Second,
+	} {
+		delay := retry.backoff(attempt)
+		assert.LessOrEqual(t, delay, expected)
+		assert.GreaterOrEqual(t, delay, expected/2)
+	}
+}
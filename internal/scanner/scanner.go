@@ -13,19 +13,27 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/kubewarden/audit-scanner/internal/events"
 	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/metrics"
+	"github.com/kubewarden/audit-scanner/internal/output"
 	"github.com/kubewarden/audit-scanner/internal/policies"
 	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/retry"
+	"github.com/kubewarden/audit-scanner/internal/summary"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	"golang.org/x/sync/semaphore"
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const httpClientTimeout = 10 * time.Second
@@ -36,14 +44,37 @@ type Scanner struct {
 	k8sClient      *k8s.Client
 	reportStore    report.Store
 	// http client used to make requests against the Policy Server
-	httpClient               http.Client
-	outputScan               bool
-	disableStore             bool
+	httpClient http.Client
+	// resultStream receives the reports of the audited resources, it's nil when they are not streamed
+	resultStream *output.Stream
+	disableStore bool
+	incremental  bool
+	// diff is true when the results are compared with the reports of the previous scan
+	diff bool
+	// partial is true when only a subset of the policies, or of the resources,
+	// is audited. The results are merged into the reports of the previous
+	// scans, which are not deleted
+	partial                  bool
 	parallelNamespacesAudits int
 	parallelResourcesAudits  int
 	parallelPoliciesAudits   int
 	logger                   *slog.Logger
 	reportKind               report.CrdKind
+	retry                    RetryConfig
+	rateLimiters             *rateLimiters
+	circuitBreakers          *circuitBreakers
+	preflight                PreflightConfig
+	userInfo                 UserInfoConfig
+	resourceFilter           ResourceFilter
+	// drainTimeout is the time given to the audits in flight to complete
+	// when the scan is interrupted
+	drainTimeout time.Duration
+	// metrics collects the Prometheus metrics, it's nil when they are disabled
+	metrics *metrics.Metrics
+	// events emits the Kubernetes Events of the failing results, it's nil when they are disabled
+	events *events.Recorder
+	// policyCache holds the policies of the scopes audited by ScanResource
+	policyCache *policyCache
 }
 
 // NewScanner creates a new scanner
@@ -63,6 +94,30 @@ func NewScanner(config Config) (*Scanner, error) {
 	}
 
 	logger := config.Logger.With("component", "scanner")
+	if config.Incremental && config.DisableStore {
+		return nil, errors.New("incremental scans require the report store to be enabled")
+	}
+	if config.Diff && config.DisableStore {
+		return nil, errors.New("comparing the results with the previous scan requires the report store to be enabled")
+	}
+	if config.Preflight.WaitTimeout < 0 {
+		return nil, errors.New("the time waited for the PolicyServers to recover cannot be negative")
+	}
+	if config.CircuitBreaker.FailureThreshold < 0 {
+		return nil, errors.New("the number of failures opening the circuit of a PolicyServer cannot be negative")
+	}
+	if config.Retry.MaxRetries < 0 {
+		return nil, errors.New("the number of retries cannot be negative")
+	}
+	if err := config.ResourceFilter.Validate(); err != nil {
+		return nil, err
+	}
+	switch config.UserInfo.Mode {
+	case "", UserInfoModeFixed, UserInfoModeManager:
+	default:
+		return nil, fmt.Errorf("invalid user info mode %q: supported values are %q and %q", config.UserInfo.Mode, UserInfoModeFixed, UserInfoModeManager)
+	}
+
 	if config.TLS.CAFile != "" {
 		caCert, err := os.ReadFile(config.TLS.CAFile)
 		if err != nil {
@@ -117,13 +172,26 @@ func NewScanner(config Config) (*Scanner, error) {
 		k8sClient:                config.K8sClient,
 		reportStore:              config.ReportStore,
 		httpClient:               httpClient,
-		outputScan:               config.OutputScan,
+		resultStream:             config.ResultStream,
 		disableStore:             config.DisableStore,
+		incremental:              config.Incremental,
+		diff:                     config.Diff,
+		partial:                  (config.PoliciesClient != nil && config.PoliciesClient.AuditsPolicySubset()) || !config.ResourceFilter.IsEmpty(),
 		parallelNamespacesAudits: config.Parallelization.ParallelNamespacesAudits,
 		parallelResourcesAudits:  config.Parallelization.ParallelResourcesAudits,
 		parallelPoliciesAudits:   config.Parallelization.PoliciesAudits,
 		logger:                   logger,
 		reportKind:               config.ReportKind,
+		retry:                    config.Retry,
+		rateLimiters:             newRateLimiters(config.RateLimit),
+		circuitBreakers:          newCircuitBreakers(config.CircuitBreaker, logger),
+		preflight:                config.Preflight,
+		userInfo:                 config.UserInfo,
+		resourceFilter:           config.ResourceFilter,
+		drainTimeout:             config.DrainTimeout,
+		metrics:                  config.Metrics,
+		events:                   config.Events,
+		policyCache:              newPolicyCache(),
 	}, nil
 }
 
@@ -138,6 +206,9 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
 	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 	var workers sync.WaitGroup
+	ctx = s.withPreviousReports(ctx, nsName, false)
+	drainCtx, cancelDrain := s.drainContext(ctx)
+	defer cancelDrain()
 
 	namespace, err := s.k8sClient.GetNamespace(ctx, nsName)
 	if err != nil {
@@ -153,6 +224,11 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		slog.Int("policies-to-evaluate", policies.PolicyNum),
 		slog.Int("policies-skipped", policies.SkippedNum),
 		slog.Int("policies-errored", policies.ErroredNum))
+	runSummary := summary.FromContext(ctx)
+	runSummary.AddNamespace()
+	runSummary.AddErroredPolicies(policies.ErroredPolicies)
+	runSummary.AddSkippedPolicies(policies.SkippedPolicies)
+	s.selectResources(ctx, policies.PoliciesByGVR)
 
 	for gvr, pols := range policies.PoliciesByGVR {
 		pager := s.k8sClient.GetResources(gvr, nsName)
@@ -162,6 +238,7 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 			if !ok {
 				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
 			}
+			s.metrics.RecordResourceListed(gvr.String())
 
 			err := semaphore.Acquire(ctx, 1)
 			if err != nil {
@@ -174,7 +251,7 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 				defer semaphore.Release(1)
 				defer workers.Done()
 
-				if err := s.auditResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum); err != nil {
+				if err := s.auditResource(drainCtx, policiesToAudit, *resource, gvr, runUID, policies.SkippedNum, policies.ErroredNum, policies.Selects); err != nil {
 					s.logger.ErrorContext(ctx, "error auditing resource",
 						slog.String("error", err.Error()),
 						slog.String("RunUID", runUID))
@@ -182,6 +259,10 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 			}()
 			return nil
 		})
+		if err != nil && ctx.Err() != nil {
+			// the scan has been interrupted, the other GVRs would fail too
+			break
+		}
 		if err != nil {
 			// If we fail to get the resources, we log the error inside the pager function
 			// and continue with the next GVR. Otherwise, the scan would stop
@@ -190,12 +271,31 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 				slog.String("error", err.Error()),
 				slog.String("resource-GVK", gvr.String()),
 				slog.String("ns", nsName))
+			runSummary.AddListFailure(gvr.String(), nsName, err)
+			s.metrics.RecordListFailure(gvr.String())
 			continue
 		}
 	}
 	workers.Wait()
 
+	if ctx.Err() != nil {
+		// the resources that have not been audited still have the reports
+		// of the previous scan, which must not be deleted
+		s.logger.WarnContext(ctx, "namespace scan interrupted, keeping the reports of the previous scan",
+			slog.String("namespace", nsName),
+			slog.String("RunUID", runUID))
+		s.flushReports(drainCtx, runUID, nsName)
+		return fmt.Errorf("namespace %s scan interrupted: %w", nsName, ctx.Err())
+	}
+
+	if s.partial {
+		s.flushReports(ctx, runUID, nsName)
+		s.logger.InfoContext(ctx, "Namespaced resources scan finished, keeping the reports of the previous scans as only a subset of the policies or of the resources has been audited")
+		return nil
+	}
+	s.recordDisappeared(ctx, runUID, nsName, false)
 	if err := s.reportStore.DeleteOldReports(ctx, runUID, nsName); err != nil {
+		s.metrics.RecordReportStoreError(metrics.StoreOperationDeleteReports)
 		s.logger.ErrorContext(ctx, "error deleting old reports",
 			slog.String("error", err.Error()),
 			slog.String("RunUID", runUID))
@@ -215,16 +315,20 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 	nsList, err := s.k8sClient.GetAuditedNamespaces(ctx)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "error scanning all namespaces", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to get the namespaces to audit: %w", err)
 	}
 	semaphore := semaphore.NewWeighted(int64(s.parallelNamespacesAudits))
 	var workers sync.WaitGroup
+	var errMutex sync.Mutex
 
 	for _, namespace := range nsList.Items {
-		workers.Add(1)
-		acquireErr := semaphore.Acquire(ctx, 1)
-		if acquireErr != nil {
-			return fmt.Errorf("failed to acquire the permission to audit namespace: %w", err)
+		if acquireErr := semaphore.Acquire(ctx, 1); acquireErr != nil {
+			// the scan has been interrupted, wait for the namespaces
+			// being scanned to be completed
+			err = errors.Join(err, fmt.Errorf("failed to acquire the permission to audit namespace: %w", acquireErr))
+			break
 		}
+		workers.Add(1)
 		namespaceName := namespace.Name
 
 		go func() {
@@ -233,7 +337,9 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 
 			if e := s.ScanNamespace(ctx, namespaceName, runUID); e != nil {
 				s.logger.ErrorContext(ctx, "error scanning namespace", slog.String("error", e.Error()), slog.String("ns", namespaceName))
+				errMutex.Lock()
 				err = errors.Join(err, e)
+				errMutex.Unlock()
 			}
 		}()
 	}
@@ -256,6 +362,9 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 
 	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 	var workers sync.WaitGroup
+	ctx = s.withPreviousReports(ctx, "", true)
+	drainCtx, cancelDrain := s.drainContext(ctx)
+	defer cancelDrain()
 
 	policies, err := s.policiesClient.GetClusterWidePolicies(ctx)
 	if err != nil {
@@ -267,6 +376,10 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 		slog.Int("policies-skipped", policies.SkippedNum),
 		slog.Int("policies-errored", policies.ErroredNum),
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
+	runSummary := summary.FromContext(ctx)
+	runSummary.AddErroredPolicies(policies.ErroredPolicies)
+	runSummary.AddSkippedPolicies(policies.SkippedPolicies)
+	s.selectResources(ctx, policies.PoliciesByGVR)
 
 	for gvr, pols := range policies.PoliciesByGVR {
 		pager := s.k8sClient.GetResources(gvr, "")
@@ -275,23 +388,28 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 			if !ok {
 				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
 			}
+			s.metrics.RecordResourceListed(gvr.String())
 
-			workers.Add(1)
 			err := semaphore.Acquire(ctx, 1)
 			if err != nil {
 				return fmt.Errorf("failed to acquire the permission to audit a resource: %w", err)
 			}
+			workers.Add(1)
 			policiesToAudit := pols
 
 			go func() {
 				defer semaphore.Release(1)
 				defer workers.Done()
 
-				s.auditClusterResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum)
+				s.auditClusterResource(drainCtx, policiesToAudit, *resource, gvr, runUID, policies.SkippedNum, policies.ErroredNum, policies.Selects)
 			}()
 
 			return nil
 		})
+		if err != nil && ctx.Err() != nil {
+			// the scan has been interrupted, the other GVRs would fail too
+			break
+		}
 		if err != nil {
 			// If we fail to get the resources, we log the error inside the pager function
 			// and continue with the next GVR. Otherwise, the scan would stop
@@ -299,13 +417,31 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 			s.logger.WarnContext(ctx, "Failed to list resources",
 				slog.String("error", err.Error()),
 				slog.String("resource-GVK", gvr.String()))
+			runSummary.AddListFailure(gvr.String(), "", err)
+			s.metrics.RecordListFailure(gvr.String())
 			continue
 		}
 	}
 
 	workers.Wait()
 
+	if ctx.Err() != nil {
+		// the resources that have not been audited still have the reports
+		// of the previous scan, which must not be deleted
+		s.logger.WarnContext(ctx, "cluster-wide resources scan interrupted, keeping the reports of the previous scan",
+			slog.String("RunUID", runUID))
+		s.flushClusterReports(drainCtx, runUID)
+		return fmt.Errorf("cluster-wide resources scan interrupted: %w", ctx.Err())
+	}
+
+	if s.partial {
+		s.flushClusterReports(ctx, runUID)
+		s.logger.InfoContext(ctx, "Cluster-wide resources scan finished, keeping the reports of the previous scans as only a subset of the policies or of the resources has been audited")
+		return nil
+	}
+	s.recordDisappeared(ctx, runUID, "", true)
 	if err := s.reportStore.DeleteOldClusterReports(ctx, runUID); err != nil {
+		s.metrics.RecordReportStoreError(metrics.StoreOperationDeleteClusterReports)
 		s.logger.ErrorContext(ctx, "error deleting old ClusterReports",
 			slog.String("error", err.Error()),
 			slog.String("RunUID", runUID))
@@ -314,14 +450,104 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 	return nil
 }
 
+// drainContext returns the context used to audit the resources. It's not
+// canceled together with ctx, so the evaluations in flight when the scan is
+// interrupted are completed and their reports are written. It's canceled once
+// the drain timeout has elapsed since ctx was canceled.
+func (s *Scanner) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(s.drainTimeout, cancel)
+		context.AfterFunc(drainCtx, func() { timer.Stop() })
+	})
+
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// ScanResource audits a single resource with all the policies targeting its
+// GroupVersionResource and updates its report. It's used to re-audit the
+// resources that changed since the last scan.
+// Resources that are not targeted by any policy are ignored, unless they have
+// a report, whose results are removed. The policies of each namespace are
+// cached until InvalidatePolicies is called.
+func (s *Scanner) ScanResource(ctx context.Context, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string) error {
+	nsName := resource.GetNamespace()
+	auditablePolicies, err := s.getScopePolicies(ctx, nsName)
+	if err != nil {
+		return err
+	}
+
+	s.selectResources(ctx, auditablePolicies.PoliciesByGVR)
+	pols, found := auditablePolicies.PoliciesByGVR[gvr]
+	if !found {
+		if !s.hasStoredReport(ctx, resource) {
+			s.logger.DebugContext(ctx, "no policies target the resource, skipping...",
+				slog.String("resource", resource.GetName()),
+				slog.String("resource-GVR", gvr.String()),
+				slog.String("ns", nsName))
+			return nil
+		}
+		// the policies targeting the resource have been deleted or changed:
+		// the report is written again without their results
+		s.logger.DebugContext(ctx, "no policies target the resource anymore, removing their results...",
+			slog.String("resource", resource.GetName()),
+			slog.String("resource-GVR", gvr.String()),
+			slog.String("ns", nsName))
+	}
+
+	if nsName == "" {
+		s.auditClusterResource(ctx, pols, resource, gvr, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum, auditablePolicies.Selects)
+		s.flushClusterReports(ctx, runUID)
+		return nil
+	}
+	if err := s.auditResource(ctx, pols, resource, gvr, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum, auditablePolicies.Selects); err != nil {
+		return err
+	}
+	s.flushReports(ctx, runUID, nsName)
+	return nil
+}
+
+// flushReports writes the reports of the namespace buffered by the report
+// store, like the aggregated reports.
+func (s *Scanner) flushReports(ctx context.Context, runUID, nsName string) {
+	if s.disableStore {
+		return
+	}
+	if err := s.reportStore.FlushReports(ctx, runUID, nsName); err != nil {
+		s.metrics.RecordReportStoreError(metrics.StoreOperationFlushReports)
+		s.logger.ErrorContext(ctx, "error flushing reports",
+			slog.String("error", err.Error()),
+			slog.String("namespace", nsName),
+			slog.String("RunUID", runUID))
+	}
+}
+
+// flushClusterReports writes the cluster reports buffered by the report store.
+func (s *Scanner) flushClusterReports(ctx context.Context, runUID string) {
+	if s.disableStore {
+		return
+	}
+	if err := s.reportStore.FlushClusterReports(ctx, runUID); err != nil {
+		s.metrics.RecordReportStoreError(metrics.StoreOperationFlushClusterReports)
+		s.logger.ErrorContext(ctx, "error flushing cluster reports",
+			slog.String("error", err.Error()),
+			slog.String("RunUID", runUID))
+	}
+}
+
 type policyAuditResult struct {
 	policy                  policiesv1.Policy
 	admissionReviewResponse *admissionv1.AdmissionReview
 	errored                 bool
+	attempts                int
+	operation               admissionregistrationv1.OperationType
 }
 
 //gocognit:ignore
-func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) error {
+func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, gvr schema.GroupVersionResource, runUID string, skippedPoliciesNum, erroredPoliciesNum int, audited func(policy string) bool) error {
 	s.logger.InfoContext(ctx, "audit resource",
 		slog.String("resource", resource.GetName()),
 		slog.Int("policies-to-evaluate", len(policies)),
@@ -331,7 +557,20 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 	var workers sync.WaitGroup
 	auditResults := make(chan policyAuditResult, len(policies))
 
+	policyReport := report.NewReportOfKind(s.reportKind, runUID, resource)
+	policyReport.SetErrorPolicies(erroredPoliciesNum)
+	policyReport.SetSkipPolicies(skippedPoliciesNum)
+	previousReport := s.getPreviousReport(ctx, resource, false)
+	userInfo := s.userInfo.userInfo(resource)
+
 	for _, policyToUse := range policies {
+		if s.incremental && previousReport != nil && policyReport.ReuseResult(previousReport, policyToUse.Policy) {
+			s.logger.DebugContext(ctx, "reusing result of the previous scan",
+				slog.String("policy", policyToUse.GetName()),
+				slog.String("resource", resource.GetName()))
+			continue
+		}
+
 		err := semaphore.Acquire(ctx, 1)
 		if err != nil {
 			return fmt.Errorf("failed to acquire the permission to audit a resource: %w", err)
@@ -340,6 +579,7 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 
 		url := policyToUse.PolicyServer
 		policy := policyToUse.Policy
+		operation := policyToUse.Operation
 
 		go func() {
 			defer semaphore.Release(1)
@@ -354,11 +594,19 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 				return
 			}
 
-			admissionReviewRequest := newAdmissionReview(resource)
-			admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
+			admissionReviewRequest := newAdmissionReview(resource, gvr, operation, userInfo)
+			evaluationStart := time.Now()
+			admissionReviewResponse, attempts, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, policy.GetPolicyServer(), url, admissionReviewRequest)
+			evaluationDuration := time.Since(evaluationStart)
 			errored := false
 
-			if responseErr != nil {
+			if errors.Is(responseErr, errCircuitOpen) {
+				errored = true
+				// the transition of the circuit is logged once, not for every evaluation
+				s.logger.DebugContext(ctx, "circuit open, skipping the evaluation as error...",
+					slog.String("policy", policy.GetUniqueName()),
+					slog.String("resource", resource.GetName()))
+			} else if responseErr != nil {
 				errored = true
 				// log responseErr, will end in PolicyReportResult too
 				s.logger.ErrorContext(ctx, "error sending AdmissionReview to PolicyServer",
@@ -387,42 +635,47 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 						slog.Bool("allowed", admissionReviewResponse.Response.Allowed)))
 			}
 
+			s.metrics.RecordEvaluation(policy.GetUniqueName(), policy.GetPolicyServer(), evaluationStatus(errored, admissionReviewResponse), evaluationDuration)
+
 			auditResults <- policyAuditResult{
 				policy,
 				admissionReviewResponse,
 				errored,
+				attempts,
+				operation,
 			}
 		}()
 	}
 	workers.Wait()
 	close(auditResults)
 
-	policyReport := report.NewReportOfKind(s.reportKind, runUID, resource)
-	policyReport.SetErrorPolicies(erroredPoliciesNum)
-	policyReport.SetSkipPolicies(skippedPoliciesNum)
 	for res := range auditResults {
-		policyReport.AddResult(res.policy, res.admissionReviewResponse, res.errored)
+		policyReport.AddResult(res.policy, res.admissionReviewResponse, res.errored, evaluationProperties(res.attempts, res.operation))
+	}
+	summary.FromContext(ctx).AddResource(policyReport.GetResults())
+	output.FromContext(ctx).Add(policyReport)
+	s.metrics.RecordResults(policyReport.GetResults())
+	s.recordChanges(ctx, runUID, policyReport, previousReport)
+	s.events.Record(policyReport, previousReport)
+	if s.partial && previousReport != nil {
+		policyReport.MergeResults(previousReport, audited)
 	}
 
-	if s.outputScan {
-		policyReportJSON, err := json.Marshal(policyReport)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "error while marshalling PolicyReport to JSON, skipping output scan", slog.String("error", err.Error()))
-		}
-
-		s.logger.InfoContext(ctx, "PolicyReport summary", slog.String("report", string(policyReportJSON)))
+	if err := s.resultStream.WriteReport(runUID, policyReport); err != nil {
+		s.logger.ErrorContext(ctx, "error writing PolicyReport to the result stream", slog.String("error", err.Error()))
 	}
 
 	if !s.disableStore {
 		err := s.reportStore.CreateOrPatchReport(ctx, policyReport)
 		if err != nil {
+			s.metrics.RecordReportStoreError(metrics.StoreOperationWriteReport)
 			s.logger.ErrorContext(ctx, "error adding PolicyReport to store.", slog.String("error", err.Error()))
 		}
 	}
 	return nil
 }
 
-func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) {
+func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, gvr schema.GroupVersionResource, runUID string, skippedPoliciesNum, erroredPoliciesNum int, audited func(policy string) bool) {
 	s.logger.InfoContext(ctx, "audit clusterwide resource",
 		slog.String("resource", resource.GetName()),
 		slog.Int("policies-to-evaluate", len(policies)))
@@ -430,9 +683,20 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 	clusterReport := report.NewClusterReportOfKind(s.reportKind, runUID, resource)
 	clusterReport.SetSkipPolicies(skippedPoliciesNum)
 	clusterReport.SetErrorPolicies(erroredPoliciesNum)
+	previousReport := s.getPreviousReport(ctx, resource, true)
+	userInfo := s.userInfo.userInfo(resource)
+
 	for _, p := range policies {
 		url := p.PolicyServer
 		policy := p.Policy
+		operation := p.Operation
+
+		if s.incremental && previousReport != nil && clusterReport.ReuseResult(previousReport, policy) {
+			s.logger.DebugContext(ctx, "reusing result of the previous scan",
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()))
+			continue
+		}
 
 		matches, err := policyMatches(policy, resource)
 		if err != nil {
@@ -443,11 +707,19 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 			continue
 		}
 
-		admissionReviewRequest := newAdmissionReview(resource)
-		admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
+		admissionReviewRequest := newAdmissionReview(resource, gvr, operation, userInfo)
+		evaluationStart := time.Now()
+		admissionReviewResponse, attempts, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, policy.GetPolicyServer(), url, admissionReviewRequest)
+		evaluationDuration := time.Since(evaluationStart)
 		errored := false
 
-		if responseErr != nil {
+		if errors.Is(responseErr, errCircuitOpen) {
+			errored = true
+			// the transition of the circuit is logged once, not for every evaluation
+			s.logger.DebugContext(ctx, "circuit open, skipping the evaluation as error...",
+				slog.String("policy", policy.GetUniqueName()),
+				slog.String("resource", resource.GetName()))
+		} else if responseErr != nil {
 			errored = true
 			// log error, will end in ClusterPolicyReportResult too
 			s.logger.ErrorContext(ctx, "error sending AdmissionReview to PolicyServer", slog.String("error", responseErr.Error()),
@@ -475,26 +747,53 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 					slog.Bool("allowed", admissionReviewResponse.Response.Allowed)))
 		}
 
-		clusterReport.AddResult(policy, admissionReviewResponse, errored)
-	}
+		s.metrics.RecordEvaluation(policy.GetUniqueName(), policy.GetPolicyServer(), evaluationStatus(errored, admissionReviewResponse), evaluationDuration)
 
-	if s.outputScan {
-		clusterPolicyReportJSON, err := json.Marshal(clusterReport)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "error while marshalling ClusterPolicyReport to JSON, skipping output scan", slog.String("error", err.Error()))
-		}
+		clusterReport.AddResult(policy, admissionReviewResponse, errored, evaluationProperties(attempts, operation))
+	}
+	summary.FromContext(ctx).AddResource(clusterReport.GetResults())
+	output.FromContext(ctx).Add(clusterReport)
+	s.metrics.RecordResults(clusterReport.GetResults())
+	s.recordChanges(ctx, runUID, clusterReport, previousReport)
+	s.events.Record(clusterReport, previousReport)
+	if s.partial && previousReport != nil {
+		clusterReport.MergeResults(previousReport, audited)
+	}
 
-		s.logger.InfoContext(ctx, "ClusterPolicyReport summary", slog.Any("report", clusterPolicyReportJSON))
+	if err := s.resultStream.WriteReport(runUID, clusterReport); err != nil {
+		s.logger.ErrorContext(ctx, "error writing ClusterPolicyReport to the result stream", slog.String("error", err.Error()))
 	}
 
 	if !s.disableStore {
 		err := s.reportStore.CreateOrPatchClusterReport(ctx, clusterReport)
 		if err != nil {
+			s.metrics.RecordReportStoreError(metrics.StoreOperationWriteClusterReport)
 			s.logger.ErrorContext(ctx, "error adding ClusterPolicyReport to store", slog.String("error", err.Error()))
 		}
 	}
 }
 
+// evaluationStatus returns the status of an evaluation, as reported by the metrics.
+func evaluationStatus(errored bool, admissionReview *admissionv1.AdmissionReview) string {
+	if errored {
+		return metrics.EvaluationError
+	}
+	if admissionReview.Response.Allowed {
+		return metrics.EvaluationPass
+	}
+	return metrics.EvaluationFail
+}
+
+// evaluationProperties returns the properties added to the result of an
+// evaluation, so PolicyServers needing retries and the simulated operation are
+// visible in the reports.
+func evaluationProperties(attempts int, operation admissionregistrationv1.OperationType) map[string]string {
+	return map[string]string{
+		report.PropertyEvaluationAttempts: strconv.Itoa(attempts),
+		report.PropertyOperation:          string(operation),
+	}
+}
+
 func policyMatches(policy policiesv1.Policy, resource unstructured.Unstructured) (bool, error) {
 	if policy.GetObjectSelector() == nil {
 		return true, nil
@@ -513,13 +812,59 @@ func policyMatches(policy policiesv1.Policy, resource unstructured.Unstructured)
 	return true, nil
 }
 
-func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *url.URL, admissionRequest *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, error) {
+// sendAdmissionReviewToPolicyServer sends the admission review to the
+// PolicyServer, retrying the transient failures according to the retry
+// configuration. Each attempt is subject to the rate limit of the PolicyServer.
+// It returns the number of attempts together with the response. When the
+// circuit of the PolicyServer is open, the request is not sent: the returned
+// error wraps errCircuitOpen and the response carries its reason.
+func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, policyServer string, url *url.URL, admissionRequest *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, int, error) {
 	payload, err := json.Marshal(admissionRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode the admission request: %w", err)
+		return nil, 0, fmt.Errorf("failed to encode the admission request: %w", err)
+	}
+
+	start := time.Now()
+	for attempts := 1; ; attempts++ {
+		allowed, err := s.circuitBreakers.allow(ctx, policyServer)
+		if err != nil {
+			return circuitOpenReview(err), attempts - 1, err
+		}
+		release, err := s.rateLimiters.acquire(ctx, policyServer)
+		if err != nil {
+			// the request of this attempt has not been sent
+			s.circuitBreakers.abort(policyServer, allowed)
+			return nil, attempts - 1, err
+		}
+		admissionReview, err := s.doAdmissionReviewRequest(ctx, url, payload)
+		release()
+		s.circuitBreakers.done(ctx, policyServer, allowed, err)
+		if err == nil || !isRetryable(ctx, err) || attempts > s.retry.MaxRetries {
+			return admissionReview, attempts, err
+		}
+
+		delay := s.retry.backoff(attempts)
+		if s.retry.MaxElapsedTime > 0 && time.Since(start)+delay > s.retry.MaxElapsedTime {
+			return nil, attempts, err
+		}
+		s.logger.DebugContext(ctx, "retrying request to PolicyServer",
+			slog.String("error", err.Error()),
+			slog.String("url", url.String()),
+			slog.Int("attempt", attempts),
+			slog.Duration("backoff", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempts, err
+		case <-timer.C:
+		}
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewBuffer(payload))
+func (s *Scanner) doAdmissionReviewRequest(ctx context.Context, url *url.URL, payload []byte) (*admissionv1.AdmissionReview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build the policy server request: %w", err)
 	}
@@ -527,15 +872,19 @@ func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *ur
 
 	res, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request to policy server failed: %w ", err)
+		return nil, &retry.TransientError{Err: fmt.Errorf("request to policy server failed: %w ", err)}
 	}
 	defer res.Body.Close()
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read body of response: %w", err)
+		return nil, &retry.TransientError{Err: fmt.Errorf("cannot read body of response: %w", err)}
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d body: %s", res.StatusCode, body)
+		err = fmt.Errorf("unexpected status code: %d body: %s", res.StatusCode, body)
+		if isRetryableStatusCode(res.StatusCode) {
+			return nil, &retry.TransientError{Err: err, StatusCode: res.StatusCode}
+		}
+		return nil, err
 	}
 
 	admissionReview := admissionv1.AdmissionReview{}
@@ -1,6 +1,8 @@
 package scanner
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -8,14 +10,20 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	auditConstants "github.com/kubewarden/audit-scanner/internal/constants"
 	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/metrics"
+	"github.com/kubewarden/audit-scanner/internal/output"
 	"github.com/kubewarden/audit-scanner/internal/policies"
 	"github.com/kubewarden/audit-scanner/internal/report"
 	auditscheme "github.com/kubewarden/audit-scanner/internal/scheme"
+	"github.com/kubewarden/audit-scanner/internal/summary"
 	"github.com/kubewarden/audit-scanner/internal/testutils"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
@@ -27,6 +35,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	dynamicFake "k8s.io/client-go/dynamic/fake"
@@ -53,7 +62,6 @@ func newTestConfig(policiesClient *policies.Client, k8sClient *k8s.Client, repor
 			ParallelResourcesAudits:  parallelResourcesAudits,
 			PoliciesAudits:           parallelPoliciesAudits,
 		},
-		OutputScan:   false,
 		DisableStore: false,
 		Logger:       slog.Default(),
 		ReportKind:   report.ReportKindPolicyReport,
@@ -82,6 +90,25 @@ func newMockPolicyServer() *httptest.Server {
 	}))
 }
 
+func newMockPolicyServerWithCounter(evaluations *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		evaluations.Add(1)
+
+		admissionReview := admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		}
+		response, err := json.Marshal(admissionReview)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = writer.Write(response)
+	}))
+}
+
 func newMockPolicyServerWithErrors() *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
 		writer.WriteHeader(http.StatusBadGateway)
@@ -387,9 +414,9 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -593,9 +620,9 @@ func TestScanClusterWideResourcesWithPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -703,9 +730,9 @@ func TestScanWithHTTPErrors(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServerWithErrors.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServerWithErrors.URL, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -726,6 +753,8 @@ func TestScanWithHTTPErrors(t *testing.T) {
 	assert.Equal(t, 1, podPolicyReport.Summary.Error)
 	assert.Equal(t, 0, podPolicyReport.Summary.Skip)
 	assert.Len(t, podPolicyReport.Results, 1)
+	// retries are disabled in the tests
+	assert.Equal(t, "1", podPolicyReport.Results[0].Properties[report.PropertyEvaluationAttempts])
 
 	namespacePolicyReport := wgpolicy.ClusterPolicyReport{}
 	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &namespacePolicyReport)
@@ -826,9 +855,9 @@ func TestScanWithMTLS(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
@@ -963,8 +992,8 @@ func TestScanFailureClusterWideResources(t *testing.T) {
 	})
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, 1, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, 1, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
@@ -1259,8 +1288,8 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 	})
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 	policyReportStore := report.NewPolicyReportStore(client, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
@@ -1548,9 +1577,9 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 
 	openReportStore := report.NewOpenReportStore(client, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
@@ -1753,9 +1782,9 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
 
 	openReportStore := report.NewOpenReportStore(client, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
@@ -1786,3 +1815,1297 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 	assert.Len(t, clusterPolicyReport.Results, 3)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 }
+
+// countingReportStore counts the reports read one at a time.
+type countingReportStore struct {
+	*report.OpenReportStore
+	gets atomic.Int32
+}
+
+func (s *countingReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	s.gets.Add(1)
+	return s.OpenReportStore.GetReport(ctx, resource) //nolint:wrapcheck // the store already wraps the errors
+}
+
+func (s *countingReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (report.Report, error) {
+	s.gets.Add(1)
+	return s.OpenReportStore.GetClusterReport(ctx, resource) //nolint:wrapcheck // the store already wraps the errors
+}
+
+func TestIncrementalScan(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "namespace",
+			UID:             "namespace-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "namespace",
+			UID:             "pod-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	openReportStore := &countingReportStore{OpenReportStore: report.NewOpenReportStore(client, logger).(*report.OpenReportStore)}
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.Incremental = true
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	// the first scan evaluates all the resources
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), evaluations.Load())
+
+	// the second scan reuses the results, since nothing changed
+	runUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), evaluations.Load())
+	// the previous reports are listed once per scan, rather than read by resource
+	assert.Zero(t, openReportStore.gets.Load())
+
+	podReport := openreports.Report{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, podReport.Summary.Pass)
+	assert.Len(t, podReport.Results, 1)
+	assert.Equal(t, runUID, podReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	namespaceReport := openreports.ClusterReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace.GetUID())}, &namespaceReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, namespaceReport.Summary.Pass)
+	assert.Len(t, namespaceReport.Results, 1)
+	assert.Equal(t, runUID, namespaceReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	// the resource is changed, so it must be evaluated again
+	pod.SetResourceVersion("2")
+	unstructuredPod, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	require.NoError(t, err)
+	_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace").Update(t.Context(), &unstructured.Unstructured{Object: unstructuredPod}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), evaluations.Load())
+}
+
+func TestScanResourceWithoutPolicies(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	openReportStore := report.NewOpenReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+
+	podReport := openreports.Report{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Len(t, podReport.Results, 1)
+
+	// the policy is deleted, so its result is removed from the report of the resource
+	err = client.Delete(t.Context(), clusterAdmissionPolicy)
+	require.NoError(t, err)
+	unstructuredPod, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	require.NoError(t, err)
+	podsGVR := corev1.SchemeGroupVersion.WithResource("pods")
+	err = scanner.ScanResource(t.Context(), podsGVR, unstructured.Unstructured{Object: unstructuredPod}, uuid.New().String())
+	require.NoError(t, err)
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Empty(t, podReport.Results)
+	assert.Zero(t, podReport.Summary.Fail)
+
+	// no report is created for the resources that have none
+	otherPod := &unstructured.Unstructured{}
+	otherPod.SetAPIVersion("v1")
+	otherPod.SetKind("Pod")
+	otherPod.SetName("other-pod")
+	otherPod.SetNamespace("namespace")
+	otherPod.SetUID("other-pod-uid")
+	err = scanner.ScanResource(t.Context(), podsGVR, *otherPod, uuid.New().String())
+	require.NoError(t, err)
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: "other-pod-uid", Namespace: "namespace"}, &openreports.Report{})
+	require.Error(t, err)
+}
+
+func TestPartialScan(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	podsRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods"},
+	}
+
+	// two ClusterAdmissionPolicies targeting pods, only the first one is audited by the partial scan
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(podsRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(podsRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	reportStore := report.NewPolicyReportStore(client, logger)
+
+	// the first scan evaluates all the policies
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, reportStore))
+	require.NoError(t, err)
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), evaluations.Load())
+
+	// the partial scan evaluates only the selected policy
+	policyFilter, err := policies.NewPolicyFilter([]string{"clusterAdmissionPolicy1"}, "")
+	require.NoError(t, err)
+	policiesClient = policies.NewClient(client, nil, policies.ResourceFilter{}, policyFilter, "kubewarden", mockPolicyServer.URL, logger)
+	scanner, err = NewScanner(newTestConfig(policiesClient, k8sClient, reportStore))
+	require.NoError(t, err)
+	runUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), evaluations.Load())
+
+	// the result of the other policy is preserved
+	podReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Equal(t, 2, podReport.Summary.Pass)
+	assert.Len(t, podReport.Results, 2)
+	assert.Equal(t, runUID, podReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+}
+
+func TestScanWithResourceFilter(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "namespace",
+			UID:       "deployment-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and deployments
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"apps"},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"deployments"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+		deployment,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	reportStore := report.NewPolicyReportStore(client, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+
+	// the first scan audits all the resources
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, reportStore))
+	require.NoError(t, err)
+	previousRunUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), previousRunUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), evaluations.Load())
+
+	// the filtered scan audits only the pods
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.ResourceFilter = ResourceFilter{Exclude: []string{"deployments.apps"}}
+	scanner, err = NewScanner(config)
+	require.NoError(t, err)
+	runUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), evaluations.Load())
+
+	podReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &podReport)
+	require.NoError(t, err)
+	assert.Equal(t, runUID, podReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	// the report of the excluded resource is not deleted as a stale one
+	deploymentReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(deployment.GetUID()), Namespace: "namespace"}, &deploymentReport)
+	require.NoError(t, err)
+	assert.Equal(t, previousRunUID, deploymentReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	assert.Len(t, deploymentReport.Results, 1)
+}
+
+func TestScanWithAggregatedReports(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "namespace",
+			UID:             "namespace-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod1",
+			Namespace:       "namespace",
+			UID:             "pod1-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	pod2 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod2",
+			Namespace:       "namespace",
+			UID:             "pod2-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod1,
+		pod2,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	reportStore := report.NewAggregatedOpenReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, reportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.Incremental = true
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	err = scanner.ScanAllNamespaces(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), evaluations.Load())
+
+	// the second scan reuses the results stored in the aggregated reports
+	runUID := uuid.New().String()
+	err = scanner.ScanAllNamespaces(t.Context(), runUID)
+	require.NoError(t, err)
+	err = scanner.ScanClusterWideResources(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), evaluations.Load())
+
+	reportList := openreports.ReportList{}
+	err = client.List(t.Context(), &reportList)
+	require.NoError(t, err)
+	require.Len(t, reportList.Items, 1)
+	assert.Equal(t, 2, reportList.Items[0].Summary.Pass)
+	require.Len(t, reportList.Items[0].Results, 2)
+	assert.Equal(t, "pod1", reportList.Items[0].Results[0].Subjects[0].Name)
+	assert.Equal(t, "pod2", reportList.Items[0].Results[1].Subjects[0].Name)
+	assert.Equal(t, runUID, reportList.Items[0].GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	clusterReportList := openreports.ClusterReportList{}
+	err = client.List(t.Context(), &clusterReportList)
+	require.NoError(t, err)
+	require.Len(t, clusterReportList.Items, 1)
+	require.Len(t, clusterReportList.Items[0].Results, 1)
+	assert.Equal(t, "namespace", clusterReportList.Items[0].Results[0].Subjects[0].Name)
+}
+
+func TestScanRunSummary(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a ClusterAdmissionPolicy targeting an unknown resource, it should be errored
+	erroredClusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("erroredClusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"foo"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+		erroredClusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	var resultStream bytes.Buffer
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.Metrics = metrics.NewMetrics()
+	config.ResultStream = output.NewStream(&resultStream)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	runSummary := summary.NewRunSummary(runUID, summary.ScopeAll, "")
+	collector := output.NewCollector()
+	ctx := output.NewContext(summary.NewContext(t.Context(), runSummary), collector)
+	err = scanner.ScanClusterWideResources(ctx, runUID)
+	require.NoError(t, err)
+	err = scanner.ScanAllNamespaces(ctx, runUID)
+	require.NoError(t, err)
+
+	data := runSummary.Data()
+	assert.Equal(t, 1, data.NamespacesAudited)
+	assert.Equal(t, 2, data.ResourcesAudited)
+	// the errored policy is listed once, rather than counted in the results of each resource
+	assert.Equal(t, report.Summary{Pass: 2}, data.Results)
+	assert.Equal(t, []string{"clusterwide-erroredClusterAdmissionPolicy"}, data.ErroredPolicies)
+	assert.Empty(t, data.ListFailures)
+
+	// the results of the reports are collected to be rendered
+	resources := collector.Resources()
+	require.Len(t, resources, 2)
+	assert.Equal(t, "Namespace", resources[0].Resource.Kind)
+	assert.Equal(t, "Pod", resources[1].Resource.Kind)
+	assert.Equal(t, "namespace", resources[1].Resource.Namespace)
+	require.Len(t, resources[1].Results, 1)
+	assert.Equal(t, "clusterwide-clusterAdmissionPolicy", resources[1].Results[0].Policy)
+
+	// a record is streamed for each report
+	records := strings.Split(strings.TrimSpace(resultStream.String()), "\n")
+	require.Len(t, records, 2)
+	assert.Contains(t, records[0], `"kind":"ClusterPolicyReport"`)
+	assert.Contains(t, records[1], `"kind":"PolicyReport"`)
+
+	recorder := httptest.NewRecorder()
+	config.Metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(), `kubewarden_audit_scanner_policy_evaluations_total{policy="clusterwide-clusterAdmissionPolicy",policy_server="default",status="pass"} 2`)
+	assert.Contains(t, recorder.Body.String(), `kubewarden_audit_scanner_resources_listed_total{resource="/v1, Resource=pods"} 1`)
+}
+
+func TestScanNamespaceInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// the scan is interrupted while the pod is being evaluated
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		cancel()
+
+		response, err := json.Marshal(admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		})
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a report of the previous scan, it must be kept since the scan didn't complete
+	oldPolicyReport := testutils.NewPolicyReportFactory().
+		Name("oldPolicyReport").
+		Namespace(namespace.GetName()).
+		WithAppLabel().
+		RunUID(uuid.New().String()).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+		oldPolicyReport,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.DrainTimeout = time.Minute
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	err = scanner.ScanNamespace(ctx, "namespace", uuid.New().String())
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the evaluation in flight has been completed and its report written
+	policyReport := wgpolicy.PolicyReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: "namespace"}, &policyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, policyReport.Summary.Pass)
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: oldPolicyReport.GetName(), Namespace: oldPolicyReport.GetNamespace()}, &wgpolicy.PolicyReport{})
+	require.NoError(t, err)
+}
+
+func TestScanInterruptedWhileListing(t *testing.T) {
+	mockPolicyServer := httptest.NewServer(http.NotFoundHandler())
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"apps"},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"deployments"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	// the scan is interrupted while the first resources are listed, the
+	// resources listed after must not be recorded as list failures
+	var cancel context.CancelFunc
+	dynamicClient.PrependReactor("list", "*", func(testingclient.Action) (bool, runtime.Object, error) {
+		cancel()
+		return false, nil, nil
+	})
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+
+	runSummary := summary.NewRunSummary("run-uid", summary.ScopeAll, "")
+	ctx, cancel := context.WithCancel(summary.NewContext(t.Context(), runSummary))
+	defer cancel()
+	err = scanner.ScanNamespace(ctx, "namespace", uuid.New().String())
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithCancel(summary.NewContext(t.Context(), runSummary))
+	defer cancel()
+	err = scanner.ScanClusterWideResources(ctx, uuid.New().String())
+	require.ErrorIs(t, err, context.Canceled)
+
+	data := runSummary.Data()
+	assert.Empty(t, data.ListFailures)
+	assert.Zero(t, data.ListFailuresDropped)
+}
+
+func TestScanDiff(t *testing.T) {
+	var allowed atomic.Bool
+	allowed.Store(true)
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		response, err := json.Marshal(admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: allowed.Load()},
+		})
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "namespace",
+			UID:       "pod1-uid",
+		},
+	}
+
+	pod2 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod2",
+			Namespace: "namespace",
+			UID:       "pod2-uid",
+		},
+	}
+
+	// a ClusterAdmissionPolicy targeting pods and namespaces
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods", "namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod1,
+		pod2,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	var resultStream bytes.Buffer
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.Diff = true
+	config.ResultStream = output.NewStream(&resultStream)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	scan := func() summary.Data {
+		runUID := uuid.New().String()
+		runSummary := summary.NewRunSummary(runUID, summary.ScopeAll, "")
+		ctx := summary.NewContext(t.Context(), runSummary)
+		require.NoError(t, scanner.ScanClusterWideResources(ctx, runUID))
+		require.NoError(t, scanner.ScanAllNamespaces(ctx, runUID))
+		return runSummary.Data()
+	}
+
+	// the first scan has nothing to compare with
+	data := scan()
+	require.NotNil(t, data.Diff)
+	assert.Equal(t, summary.Diff{}, *data.Diff)
+
+	// the resources start failing, and a pod is deleted
+	allowed.Store(false)
+	err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace").Delete(t.Context(), "pod2", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	resultStream.Reset()
+	data = scan()
+	require.NotNil(t, data.Diff)
+	assert.Equal(t, 2, data.Diff.NewlyFailing)
+	assert.Equal(t, 1, data.Diff.Disappeared)
+	assert.Equal(t, []summary.Change{
+		{Kind: summary.ChangeNewlyFailing, Resource: "Namespace/namespace", Policy: "clusterwide-clusterAdmissionPolicy", From: report.StatusPass, To: report.StatusFail},
+		{Kind: summary.ChangeNewlyFailing, Resource: "namespace/Pod/pod1", Policy: "clusterwide-clusterAdmissionPolicy", From: report.StatusPass, To: report.StatusFail},
+		{Kind: summary.ChangeDisappeared, Resource: "namespace/Pod/pod2"},
+	}, data.Diff.Changes)
+	// the changes are streamed along with the reports
+	assert.Equal(t, 3, strings.Count(resultStream.String(), `"type":"change"`))
+	assert.Contains(t, resultStream.String(), `"change":{"kind":"disappeared","resource":"namespace/Pod/pod2"}`)
+
+	// the resources are fixed
+	allowed.Store(true)
+	data = scan()
+	require.NotNil(t, data.Diff)
+	assert.Equal(t, summary.Diff{
+		Fixed: 2,
+		Changes: []summary.Change{
+			{Kind: summary.ChangeFixed, Resource: "Namespace/namespace", Policy: "clusterwide-clusterAdmissionPolicy", From: report.StatusFail, To: report.StatusPass},
+			{Kind: summary.ChangeFixed, Resource: "namespace/Pod/pod1", Policy: "clusterwide-clusterAdmissionPolicy", From: report.StatusFail, To: report.StatusPass},
+		},
+	}, *data.Diff)
+}
+
+func TestScanPreflight(t *testing.T) {
+	var evaluations atomic.Int32
+	var unavailable atomic.Bool
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if unavailable.Load() {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		evaluations.Add(1)
+		response, err := json.Marshal(admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{Allowed: true},
+		})
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(response)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+	policyServerDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+	// a PolicyServer without a Deployment
+	brokenPolicyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "broken",
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "namespace",
+			UID:       "pod-uid",
+		},
+	}
+
+	rule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods"},
+	}
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(rule).
+		Build()
+	brokenClusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("brokenClusterAdmissionPolicy").
+		PolicyServer("broken").
+		Rule(rule).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerDeployment,
+		policyServerService,
+		brokenPolicyServer,
+		clusterAdmissionPolicy,
+		brokenClusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	policyReportStore := report.NewPolicyReportStore(client, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	config.Preflight = PreflightConfig{Enabled: true}
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	scan := func() summary.Data {
+		runUID := uuid.New().String()
+		runSummary := summary.NewRunSummary(runUID, summary.ScopeAll, "")
+		ctx := summary.NewContext(t.Context(), runSummary)
+		require.NoError(t, scanner.Preflight(ctx))
+		require.NoError(t, scanner.ScanAllNamespaces(ctx, runUID))
+		return runSummary.Data()
+	}
+
+	// the policies of the PolicyServer without a Deployment are errored once,
+	// without being evaluated
+	data := scan()
+	assert.Equal(t, []summary.UnhealthyPolicyServer{
+		{Name: "broken", Reason: `the Deployment "policy-server-broken" does not exist`},
+	}, data.UnhealthyPolicyServers)
+	assert.Equal(t, []string{"clusterwide-brokenClusterAdmissionPolicy"}, data.ErroredPolicies)
+	// the audit endpoint is probed once, then the pod is evaluated. The
+	// errored policy is listed, rather than counted in the results
+	assert.Equal(t, int32(2), evaluations.Load())
+	assert.Equal(t, report.Summary{Pass: 1}, data.Results)
+
+	// the PolicyServer whose audit endpoint is unavailable is unhealthy too
+	unavailable.Store(true)
+	data = scan()
+	require.Len(t, data.UnhealthyPolicyServers, 2)
+	assert.Equal(t, "default", data.UnhealthyPolicyServers[1].Name)
+	assert.Equal(t, "the audit endpoint is unavailable: status code 503", data.UnhealthyPolicyServers[1].Reason)
+	assert.Equal(t, []string{"clusterwide-brokenClusterAdmissionPolicy", "clusterwide-clusterAdmissionPolicy"}, data.ErroredPolicies)
+	assert.Equal(t, report.Summary{}, data.Results)
+
+	_, err = NewScanner(Config{Preflight: PreflightConfig{Enabled: true, WaitTimeout: -time.Second}, Logger: logger})
+	require.Error(t, err)
+}
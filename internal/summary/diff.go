@@ -0,0 +1,149 @@
+package summary
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Kinds of the changes of the results since the previous scan run.
+const (
+	// ChangeNewlyFailing is a result that went from pass to fail
+	ChangeNewlyFailing = "newly-failing"
+	// ChangeFixed is a result that went from fail to pass
+	ChangeFixed = "fixed"
+	// ChangeRecovered is a result that is not an error anymore
+	ChangeRecovered = "recovered"
+	// ChangeDisappeared is a resource audited by the previous scan run, but
+	// not found anymore
+	ChangeDisappeared = "disappeared"
+)
+
+// maxDiffChanges is the number of changes listed by a Diff, so the summary
+// fits in a ConfigMap. The changes are still counted once the list is full,
+// and only the first ones in the order of the list are kept, whatever the
+// order they are found in.
+const maxDiffChanges = 1000
+
+// Change is a change of a result, or a disappeared resource, since the previous
+// scan run.
+type Change struct {
+	// Kind is either newly-failing, fixed, recovered or disappeared
+	Kind string `json:"kind"`
+	// Resource is the audited resource, as namespace/Kind/name, or Kind/name
+	// for the cluster-wide resources
+	Resource string `json:"resource"`
+	// Policy is the unique name of the policy, empty for a disappeared resource
+	Policy string `json:"policy,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// Diff lists the changes of the results since the previous scan run.
+type Diff struct {
+	NewlyFailing int `json:"newlyFailing"`
+	Fixed        int `json:"fixed"`
+	Recovered    int `json:"recovered"`
+	Disappeared  int `json:"disappeared"`
+	// Changes are the changes, sorted by resource and policy, up to 1000 of them
+	Changes []Change `json:"changes,omitempty"`
+	// Dropped is the number of changes counted but not listed
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// DiffResults returns the changes between the results of the previous scan of
+// a resource and the current ones. The results of the policies that were not
+// evaluated by both scans are ignored.
+func DiffResults(resource corev1.ObjectReference, previous, current []report.Result) []Change {
+	var changes []Change
+	for _, result := range current {
+		index := slices.IndexFunc(previous, func(previousResult report.Result) bool {
+			return previousResult.Policy == result.Policy
+		})
+		if index < 0 {
+			continue
+		}
+		from := previous[index].Status
+		var kind string
+		switch {
+		case from == report.StatusPass && result.Status == report.StatusFail:
+			kind = ChangeNewlyFailing
+		case from == report.StatusFail && result.Status == report.StatusPass:
+			kind = ChangeFixed
+		case from == report.StatusError && result.Status != report.StatusError:
+			kind = ChangeRecovered
+		default:
+			continue
+		}
+		changes = append(changes, Change{
+			Kind:     kind,
+			Resource: resourceID(resource),
+			Policy:   result.Policy,
+			From:     from,
+			To:       result.Status,
+		})
+	}
+	return changes
+}
+
+// DisappearedResource returns the change of a resource audited by the previous
+// scan run, but not found anymore.
+func DisappearedResource(resource corev1.ObjectReference) Change {
+	return Change{Kind: ChangeDisappeared, Resource: resourceID(resource)}
+}
+
+// AddChanges records the changes of the results since the previous scan run.
+// The diff is part of the summary once this method is called, even with no
+// changes.
+func (s *RunSummary) AddChanges(changes ...Change) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data.Diff == nil {
+		s.data.Diff = &Diff{}
+	}
+	diff := s.data.Diff
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeNewlyFailing:
+			diff.NewlyFailing++
+		case ChangeFixed:
+			diff.Fixed++
+		case ChangeRecovered:
+			diff.Recovered++
+		case ChangeDisappeared:
+			diff.Disappeared++
+		}
+		index, _ := slices.BinarySearchFunc(diff.Changes, change, compareChanges)
+		if index >= maxDiffChanges {
+			diff.Dropped++
+			continue
+		}
+		diff.Changes = slices.Insert(diff.Changes, index, change)
+		if len(diff.Changes) > maxDiffChanges {
+			diff.Changes = diff.Changes[:maxDiffChanges]
+			diff.Dropped++
+		}
+	}
+}
+
+func compareChanges(a, b Change) int {
+	if c := strings.Compare(a.Resource, b.Resource); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Policy, b.Policy)
+}
+
+// resourceID returns namespace/Kind/name, or Kind/name for the cluster-wide
+// resources.
+func resourceID(resource corev1.ObjectReference) string {
+	if resource.Namespace == "" {
+		return resource.Kind + "/" + resource.Name
+	}
+	return resource.Namespace + "/" + resource.Kind + "/" + resource.Name
+}
@@ -0,0 +1,85 @@
+package summary
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDiffResults(t *testing.T) {
+	pod := corev1.ObjectReference{Kind: "Pod", Name: "pod", Namespace: "default"}
+	previous := []report.Result{
+		{Policy: "newly-failing", Status: report.StatusPass},
+		{Policy: "fixed", Status: report.StatusFail},
+		{Policy: "recovered", Status: report.StatusError},
+		{Policy: "still-failing", Status: report.StatusFail},
+		{Policy: "now-errored", Status: report.StatusPass},
+		{Policy: "not-evaluated", Status: report.StatusPass},
+	}
+	current := []report.Result{
+		{Policy: "new", Status: report.StatusFail},
+		{Policy: "newly-failing", Status: report.StatusFail},
+		{Policy: "fixed", Status: report.StatusPass},
+		{Policy: "recovered", Status: report.StatusFail},
+		{Policy: "still-failing", Status: report.StatusFail},
+		{Policy: "now-errored", Status: report.StatusError},
+	}
+
+	assert.Equal(t, []Change{
+		{Kind: ChangeNewlyFailing, Resource: "default/Pod/pod", Policy: "newly-failing", From: report.StatusPass, To: report.StatusFail},
+		{Kind: ChangeFixed, Resource: "default/Pod/pod", Policy: "fixed", From: report.StatusFail, To: report.StatusPass},
+		{Kind: ChangeRecovered, Resource: "default/Pod/pod", Policy: "recovered", From: report.StatusError, To: report.StatusFail},
+	}, DiffResults(pod, previous, current))
+	assert.Empty(t, DiffResults(pod, nil, current))
+}
+
+func TestRunSummaryChanges(t *testing.T) {
+	runSummary := NewRunSummary("run-uid", ScopeAll, "")
+	assert.Nil(t, runSummary.Data().Diff)
+
+	// the diff is part of the summary even without changes
+	runSummary.AddChanges()
+	require.NotNil(t, runSummary.Data().Diff)
+	assert.Equal(t, Diff{}, *runSummary.Data().Diff)
+
+	runSummary.AddChanges(
+		Change{Kind: ChangeFixed, Resource: "default/Pod/b", Policy: "policy"},
+		Change{Kind: ChangeNewlyFailing, Resource: "default/Pod/a", Policy: "policy"},
+	)
+	runSummary.AddChanges(DisappearedResource(corev1.ObjectReference{Kind: "Namespace", Name: "old"}))
+	diff := runSummary.Data().Diff
+	assert.Equal(t, 1, diff.NewlyFailing)
+	assert.Equal(t, 1, diff.Fixed)
+	assert.Equal(t, 1, diff.Disappeared)
+	require.Len(t, diff.Changes, 3)
+	assert.Equal(t, "Namespace/old", diff.Changes[0].Resource)
+	assert.Equal(t, "default/Pod/a", diff.Changes[1].Resource)
+	assert.Equal(t, "default/Pod/b", diff.Changes[2].Resource)
+	assert.Zero(t, diff.Dropped)
+
+	// the changes are still counted once the list is full
+	for i := range maxDiffChanges {
+		runSummary.AddChanges(Change{Kind: ChangeRecovered, Resource: fmt.Sprintf("default/Pod/pod-%04d", i), Policy: "policy"})
+	}
+	diff = runSummary.Data().Diff
+	assert.Equal(t, maxDiffChanges, diff.Recovered)
+	assert.Len(t, diff.Changes, maxDiffChanges)
+	assert.Equal(t, 3, diff.Dropped)
+
+	// the listed changes are the first ones in order, whatever the order they are found in
+	reversedSummary := NewRunSummary("run-uid", ScopeAll, "")
+	for i := maxDiffChanges - 1; i >= 0; i-- {
+		reversedSummary.AddChanges(Change{Kind: ChangeRecovered, Resource: fmt.Sprintf("default/Pod/pod-%04d", i), Policy: "policy"})
+	}
+	reversedSummary.AddChanges(
+		Change{Kind: ChangeFixed, Resource: "default/Pod/b", Policy: "policy"},
+		Change{Kind: ChangeNewlyFailing, Resource: "default/Pod/a", Policy: "policy"},
+		DisappearedResource(corev1.ObjectReference{Kind: "Namespace", Name: "old"}),
+	)
+	assert.Equal(t, diff.Changes, reversedSummary.Data().Diff.Changes)
+	assert.Equal(t, "default/Pod/pod-0996", diff.Changes[maxDiffChanges-1].Resource)
+}
@@ -0,0 +1,128 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/kubewarden/audit-scanner/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// configMapNamePrefix is the prefix of the name of the ConfigMaps storing the summaries
+	configMapNamePrefix = "audit-scanner-run-"
+	// summaryKey is the key of the ConfigMap data containing the summary in JSON format
+	summaryKey = "summary.json"
+	// summaryLabel identifies the ConfigMaps storing the summaries
+	summaryLabel = "kubewarden.io/audit-scanner-run-summary"
+	// outcomeLabel exposes the outcome of the run, to easily find the failed ones
+	outcomeLabel = "kubewarden.io/audit-scanner-run-outcome"
+)
+
+// Store stores the run summaries as ConfigMaps in the Kubewarden namespace.
+type Store struct {
+	// client is a controller-runtime client
+	client client.Client
+	// namespace where the ConfigMaps are stored
+	namespace string
+	// history is the number of summaries to keep
+	history int
+	// logger is used to log the messages
+	logger *slog.Logger
+}
+
+// NewStore creates a new Store keeping the given number of summaries.
+func NewStore(client client.Client, namespace string, history int, logger *slog.Logger) *Store {
+	return &Store{
+		client:    client,
+		namespace: namespace,
+		history:   history,
+		logger:    logger.With("component", "summarystore"),
+	}
+}
+
+// Save creates or updates the ConfigMap storing the given summary.
+func (s *Store) Save(ctx context.Context, summary *RunSummary) error {
+	data := summary.Data()
+	content, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the summary of run %s: %w", data.RunUID, err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapNamePrefix + data.RunUID,
+			Namespace: s.namespace,
+		},
+	}
+	operation, err := controllerutil.CreateOrPatch(ctx, s.client, configMap, func() error {
+		configMap.Labels = map[string]string{
+			"app.kubernetes.io/managed-by":    "kubewarden",
+			constants.AuditScannerRunUIDLabel: data.RunUID,
+			summaryLabel:                      "true",
+			outcomeLabel:                      data.Outcome,
+		}
+		configMap.Data = map[string]string{
+			summaryKey: string(content),
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the summary of run %s: %w", data.RunUID, err)
+	}
+	s.logger.DebugContext(ctx, "run summary saved",
+		slog.String("RunUID", data.RunUID),
+		slog.String("operation", string(operation)))
+
+	return nil
+}
+
+// Prune deletes the oldest summaries, keeping only the configured number of them.
+func (s *Store) Prune(ctx context.Context) error {
+	configMaps := &corev1.ConfigMapList{}
+	err := s.client.List(ctx, configMaps, client.InNamespace(s.namespace), client.MatchingLabels{summaryLabel: "true"})
+	if err != nil {
+		return fmt.Errorf("failed to list the run summaries: %w", err)
+	}
+	if len(configMaps.Items) <= s.history {
+		return nil
+	}
+
+	summaries := make([]Data, 0, len(configMaps.Items))
+	for _, configMap := range configMaps.Items {
+		var data Data
+		if err := json.Unmarshal([]byte(configMap.Data[summaryKey]), &data); err != nil {
+			s.logger.WarnContext(ctx, "cannot read run summary, skipping...",
+				slog.String("error", err.Error()),
+				slog.String("configmap", configMap.GetName()))
+			continue
+		}
+		summaries = append(summaries, data)
+	}
+	// newest first
+	slices.SortFunc(summaries, func(a, b Data) int {
+		return b.StartTime.Compare(a.StartTime)
+	})
+
+	for _, data := range summaries[min(s.history, len(summaries)):] {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapNamePrefix + data.RunUID,
+				Namespace: s.namespace,
+			},
+		}
+		if err := s.client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete the summary of run %s: %w", data.RunUID, err)
+		}
+		s.logger.DebugContext(ctx, "run summary deleted", slog.String("RunUID", data.RunUID))
+	}
+
+	return nil
+}
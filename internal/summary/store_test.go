@@ -0,0 +1,66 @@
+package summary
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStoreSave(t *testing.T) {
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewStore(client, "kubewarden", 10, slog.Default())
+
+	runSummary := NewRunSummary("run-uid", ScopeAll, "")
+	require.NoError(t, store.Save(t.Context(), runSummary))
+
+	configMap := corev1.ConfigMap{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: "audit-scanner-run-run-uid", Namespace: "kubewarden"}, &configMap)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeRunning, configMap.Labels[outcomeLabel])
+
+	// the summary is updated when the run finishes
+	runSummary.AddNamespace()
+	runSummary.Finish(nil)
+	require.NoError(t, store.Save(t.Context(), runSummary))
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: "audit-scanner-run-run-uid", Namespace: "kubewarden"}, &configMap)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeCompleted, configMap.Labels[outcomeLabel])
+
+	var data Data
+	require.NoError(t, json.Unmarshal([]byte(configMap.Data[summaryKey]), &data))
+	assert.Equal(t, "run-uid", data.RunUID)
+	assert.Equal(t, OutcomeCompleted, data.Outcome)
+	assert.Equal(t, 1, data.NamespacesAudited)
+}
+
+func TestStorePrune(t *testing.T) {
+	client, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewStore(client, "kubewarden", 2, slog.Default())
+
+	startTime := time.Now()
+	for i, runUID := range []string{"oldest", "old", "new"} {
+		runSummary := NewRunSummary(runUID, ScopeAll, "")
+		runSummary.data.StartTime = startTime.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, store.Save(t.Context(), runSummary))
+	}
+
+	require.NoError(t, store.Prune(t.Context()))
+
+	configMaps := corev1.ConfigMapList{}
+	require.NoError(t, client.List(t.Context(), &configMaps))
+	names := []string{}
+	for _, configMap := range configMaps.Items {
+		names = append(names, configMap.GetName())
+	}
+	assert.ElementsMatch(t, []string{"audit-scanner-run-old", "audit-scanner-run-new"}, names)
+}
@@ -0,0 +1,325 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+)
+
+// Outcome of a scan run.
+const (
+	OutcomeRunning     = "running"
+	OutcomeCompleted   = "completed"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted"
+)
+
+// Scope of a scan run.
+const (
+	ScopeAll         = "all"
+	ScopeClusterWide = "cluster"
+	ScopeNamespace   = "namespace"
+	// ScopeNamespaces is the scope of the runs auditing the namespaces
+	// selected by name, label selector or name pattern.
+	ScopeNamespaces = "namespaces"
+	// ScopeManifests is the scope of the runs auditing resources read from
+	// manifests instead of the cluster
+	ScopeManifests = "manifests"
+)
+
+// maxListFailures is the number of failures listing the resources recorded by
+// a summary, so it fits in a ConfigMap even when the API server fails for all
+// the namespaces. Like the changes of a Diff, the failures are still counted
+// once the list is full, and only the first ones in the order of the list are
+// kept.
+const maxListFailures = 1000
+
+// RunSummary collects the outcome of a scan run.
+// It's safe for concurrent use. All the methods are no-op on a nil RunSummary,
+// so the code auditing the resources doesn't need to check if a summary is
+// collected.
+type RunSummary struct {
+	mutex sync.Mutex
+	data  Data
+}
+
+// Data is the content of a RunSummary.
+type Data struct {
+	RunUID string `json:"runUID"`
+	// Scope is the scope of the run: all the resources, only the cluster-wide ones, a single namespace, or manifests
+	Scope string `json:"scope"`
+	// Namespace is the namespace scanned when the scope is a single namespace
+	Namespace string     `json:"namespace,omitempty"`
+	StartTime time.Time  `json:"startTime"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	// Outcome is either running, completed, failed or interrupted
+	Outcome string `json:"outcome"`
+	// Error is the error that made the run fail
+	Error             string `json:"error,omitempty"`
+	NamespacesAudited int    `json:"namespacesAudited"`
+	ResourcesAudited  int    `json:"resourcesAudited"`
+	// Results are the totals of the results of all the reports of the run
+	Results report.Summary `json:"results"`
+	// ErroredPolicies are the unique names of the policies that could not be evaluated
+	ErroredPolicies []string `json:"erroredPolicies,omitempty"`
+	// SkippedPolicies are the unique names of the policies that don't match the audit constraints
+	SkippedPolicies []string `json:"skippedPolicies,omitempty"`
+	// UnhealthyPolicyServers are the PolicyServers found unhealthy before the scan, their policies are errored
+	UnhealthyPolicyServers []UnhealthyPolicyServer `json:"unhealthyPolicyServers,omitempty"`
+	// ListFailures are the failures listing the resources to be audited, sorted
+	// by namespace and resource, up to 1000 of them
+	ListFailures []ListFailure `json:"listFailures,omitempty"`
+	// ListFailuresDropped is the number of failures listing the resources
+	// counted but not listed
+	ListFailuresDropped int `json:"listFailuresDropped,omitempty"`
+	// FailedResults count the fail and error results by policy, status and severity
+	FailedResults []PolicyResultCount `json:"failedResults,omitempty"`
+	// ExcludedResources are the resources targeted by the policies, but excluded from the scan
+	ExcludedResources []string `json:"excludedResources,omitempty"`
+	// Diff lists the changes of the results since the previous scan run, it's
+	// nil when they are not compared
+	Diff *Diff `json:"diff,omitempty"`
+}
+
+// PolicyResultCount counts the results of a policy with a given status and severity.
+type PolicyResultCount struct {
+	// Policy is the unique name of the policy
+	Policy   string `json:"policy"`
+	Status   string `json:"status"`
+	Severity string `json:"severity,omitempty"`
+	Count    int    `json:"count"`
+}
+
+// UnhealthyPolicyServer describes a PolicyServer found unhealthy before the scan.
+type UnhealthyPolicyServer struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ListFailure describes a failure listing the resources to be audited.
+type ListFailure struct {
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Error     string `json:"error"`
+}
+
+// NewRunSummary creates a new running RunSummary.
+func NewRunSummary(runUID, scope, namespace string) *RunSummary {
+	return &RunSummary{
+		data: Data{
+			RunUID:    runUID,
+			Scope:     scope,
+			Namespace: namespace,
+			StartTime: time.Now().UTC(),
+			Outcome:   OutcomeRunning,
+		},
+	}
+}
+
+// AddNamespace counts an audited namespace.
+func (s *RunSummary) AddNamespace() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.NamespacesAudited++
+}
+
+// AddResource counts an audited resource and the results of its report.
+func (s *RunSummary) AddResource(results []report.Result) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.ResourcesAudited++
+	s.data.Results.Add(report.SummarizeResults(results))
+	for _, result := range results {
+		if result.Status == report.StatusFail || result.Status == report.StatusError {
+			s.addFailedResult(PolicyResultCount{Policy: result.Policy, Status: result.Status, Severity: result.Severity})
+		}
+	}
+}
+
+// addFailedResult counts a fail or error result, keeping the counters sorted
+// by policy, status and severity.
+func (s *RunSummary) addFailedResult(key PolicyResultCount) {
+	index, found := slices.BinarySearchFunc(s.data.FailedResults, key, comparePolicyResultCounts)
+	if !found {
+		s.data.FailedResults = slices.Insert(s.data.FailedResults, index, key)
+	}
+	s.data.FailedResults[index].Count++
+}
+
+func comparePolicyResultCounts(a, b PolicyResultCount) int {
+	if c := strings.Compare(a.Policy, b.Policy); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Status, b.Status); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Severity, b.Severity)
+}
+
+// AddErroredPolicies records the policies that could not be evaluated.
+func (s *RunSummary) AddErroredPolicies(policies []string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, policy := range policies {
+		if !slices.Contains(s.data.ErroredPolicies, policy) {
+			s.data.ErroredPolicies = append(s.data.ErroredPolicies, policy)
+		}
+	}
+	slices.Sort(s.data.ErroredPolicies)
+}
+
+// AddSkippedPolicies records the policies that don't match the audit constraints.
+func (s *RunSummary) AddSkippedPolicies(policies []string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, policy := range policies {
+		index, found := slices.BinarySearch(s.data.SkippedPolicies, policy)
+		if !found {
+			s.data.SkippedPolicies = slices.Insert(s.data.SkippedPolicies, index, policy)
+		}
+	}
+}
+
+// AddUnhealthyPolicyServer records a PolicyServer found unhealthy before the scan.
+func (s *RunSummary) AddUnhealthyPolicyServer(name, reason string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, found := slices.BinarySearchFunc(s.data.UnhealthyPolicyServers, name, func(policyServer UnhealthyPolicyServer, name string) int {
+		return strings.Compare(policyServer.Name, name)
+	})
+	if found {
+		s.data.UnhealthyPolicyServers[index].Reason = reason
+		return
+	}
+	s.data.UnhealthyPolicyServers = slices.Insert(s.data.UnhealthyPolicyServers, index, UnhealthyPolicyServer{Name: name, Reason: reason})
+}
+
+// AddExcludedResource records a resource targeted by the policies, but
+// excluded from the scan.
+func (s *RunSummary) AddExcludedResource(resource string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, found := slices.BinarySearch(s.data.ExcludedResources, resource)
+	if !found {
+		s.data.ExcludedResources = slices.Insert(s.data.ExcludedResources, index, resource)
+	}
+}
+
+// AddListFailure records a failure listing the resources to be audited.
+func (s *RunSummary) AddListFailure(resource, namespace string, err error) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	failure := ListFailure{
+		Resource:  resource,
+		Namespace: namespace,
+		Error:     err.Error(),
+	}
+	index, _ := slices.BinarySearchFunc(s.data.ListFailures, failure, compareListFailures)
+	if index >= maxListFailures {
+		s.data.ListFailuresDropped++
+		return
+	}
+	s.data.ListFailures = slices.Insert(s.data.ListFailures, index, failure)
+	if len(s.data.ListFailures) > maxListFailures {
+		s.data.ListFailures = s.data.ListFailures[:maxListFailures]
+		s.data.ListFailuresDropped++
+	}
+}
+
+func compareListFailures(a, b ListFailure) int {
+	if c := strings.Compare(a.Namespace, b.Namespace); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Resource, b.Resource)
+}
+
+// Finish marks the run as completed, or as failed if err is not nil. The run is
+// marked as interrupted if err is caused by the cancellation of its context.
+func (s *RunSummary) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	endTime := time.Now().UTC()
+	s.data.EndTime = &endTime
+	s.data.Outcome = OutcomeCompleted
+	if err != nil {
+		s.data.Outcome = OutcomeFailed
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			s.data.Outcome = OutcomeInterrupted
+		}
+		s.data.Error = err.Error()
+	}
+}
+
+// Data returns a copy of the content of the summary.
+func (s *RunSummary) Data() Data {
+	if s == nil {
+		return Data{}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := s.data
+	data.ErroredPolicies = slices.Clone(s.data.ErroredPolicies)
+	data.SkippedPolicies = slices.Clone(s.data.SkippedPolicies)
+	data.UnhealthyPolicyServers = slices.Clone(s.data.UnhealthyPolicyServers)
+	data.ListFailures = slices.Clone(s.data.ListFailures)
+	data.FailedResults = slices.Clone(s.data.FailedResults)
+	data.ExcludedResources = slices.Clone(s.data.ExcludedResources)
+	if s.data.Diff != nil {
+		diff := *s.data.Diff
+		diff.Changes = slices.Clone(s.data.Diff.Changes)
+		data.Diff = &diff
+	}
+	return data
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the given summary.
+// The scanner collects the outcome of the run in the summary found in the context.
+func NewContext(ctx context.Context, summary *RunSummary) context.Context {
+	return context.WithValue(ctx, contextKey{}, summary)
+}
+
+// FromContext returns the summary carried by ctx, or nil if there's none.
+func FromContext(ctx context.Context) *RunSummary {
+	summary, _ := ctx.Value(contextKey{}).(*RunSummary)
+	return summary
+}
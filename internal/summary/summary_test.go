@@ -0,0 +1,117 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSummary(t *testing.T) {
+	runSummary := NewRunSummary("run-uid", ScopeAll, "")
+	assert.Equal(t, OutcomeRunning, runSummary.Data().Outcome)
+
+	runSummary.AddNamespace()
+	runSummary.AddNamespace()
+	runSummary.AddResource([]report.Result{
+		{Policy: "policy-b", Status: report.StatusFail, Severity: report.SeverityHigh},
+		{Policy: "policy-a", Status: report.StatusPass},
+	})
+	runSummary.AddResource([]report.Result{
+		{Policy: "policy-b", Status: report.StatusFail, Severity: report.SeverityHigh},
+		{Policy: "policy-a", Status: report.StatusFail},
+		{Policy: "policy-c", Status: report.StatusError},
+	})
+	runSummary.AddErroredPolicies([]string{"policy-b", "policy-a"})
+	runSummary.AddErroredPolicies([]string{"policy-a"})
+	runSummary.AddSkippedPolicies([]string{"policy-d", "policy-c"})
+	runSummary.AddSkippedPolicies([]string{"policy-c"})
+	runSummary.AddUnhealthyPolicyServer("server-b", "no ready replicas")
+	runSummary.AddUnhealthyPolicyServer("server-a", "no ready replicas")
+	runSummary.AddUnhealthyPolicyServer("server-b", "unavailable")
+	runSummary.AddListFailure("apps/v1, Resource=deployments", "default", errors.New("forbidden"))
+	runSummary.AddExcludedResource("/v1, Resource=pods")
+	runSummary.AddExcludedResource("/v1, Resource=events")
+	runSummary.AddExcludedResource("/v1, Resource=pods")
+	runSummary.Finish(nil)
+
+	data := runSummary.Data()
+	assert.Equal(t, "run-uid", data.RunUID)
+	assert.Equal(t, ScopeAll, data.Scope)
+	assert.Equal(t, OutcomeCompleted, data.Outcome)
+	assert.Empty(t, data.Error)
+	require.NotNil(t, data.EndTime)
+	assert.False(t, data.EndTime.Before(data.StartTime))
+	assert.Equal(t, 2, data.NamespacesAudited)
+	assert.Equal(t, 2, data.ResourcesAudited)
+	// the results are counted from the results of the reports only
+	assert.Equal(t, report.Summary{Pass: 1, Fail: 3, Error: 1}, data.Results)
+	assert.Equal(t, []PolicyResultCount{
+		{Policy: "policy-a", Status: report.StatusFail, Count: 1},
+		{Policy: "policy-b", Status: report.StatusFail, Severity: report.SeverityHigh, Count: 2},
+		{Policy: "policy-c", Status: report.StatusError, Count: 1},
+	}, data.FailedResults)
+	assert.Equal(t, []string{"policy-a", "policy-b"}, data.ErroredPolicies)
+	assert.Equal(t, []string{"policy-c", "policy-d"}, data.SkippedPolicies)
+	assert.Equal(t, []UnhealthyPolicyServer{
+		{Name: "server-a", Reason: "no ready replicas"},
+		{Name: "server-b", Reason: "unavailable"},
+	}, data.UnhealthyPolicyServers)
+	assert.Equal(t, []ListFailure{{Resource: "apps/v1, Resource=deployments", Namespace: "default", Error: "forbidden"}}, data.ListFailures)
+	assert.Equal(t, []string{"/v1, Resource=events", "/v1, Resource=pods"}, data.ExcludedResources)
+}
+
+func TestRunSummaryFailed(t *testing.T) {
+	runSummary := NewRunSummary("run-uid", ScopeNamespace, "default")
+	runSummary.Finish(errors.New("cannot list policies"))
+
+	data := runSummary.Data()
+	assert.Equal(t, OutcomeFailed, data.Outcome)
+	assert.Equal(t, "cannot list policies", data.Error)
+	assert.Equal(t, "default", data.Namespace)
+}
+
+func TestNilRunSummary(t *testing.T) {
+	runSummary := FromContext(t.Context())
+	require.Nil(t, runSummary)
+
+	// all the methods are no-op on a nil summary
+	runSummary.AddNamespace()
+	runSummary.AddResource(nil)
+	runSummary.AddSkippedPolicies([]string{"policy"})
+	runSummary.AddErroredPolicies([]string{"policy"})
+	runSummary.AddListFailure("pods", "default", errors.New("forbidden"))
+	runSummary.Finish(nil)
+	assert.Equal(t, Data{}, runSummary.Data())
+
+	runSummary = NewRunSummary("run-uid", ScopeAll, "")
+	assert.Same(t, runSummary, FromContext(NewContext(t.Context(), runSummary)))
+}
+
+func TestRunSummaryInterrupted(t *testing.T) {
+	runSummary := NewRunSummary("run-uid", ScopeAll, "")
+	runSummary.Finish(fmt.Errorf("namespace scan interrupted: %w", context.Canceled))
+
+	data := runSummary.Data()
+	assert.Equal(t, OutcomeInterrupted, data.Outcome)
+	assert.Equal(t, "namespace scan interrupted: context canceled", data.Error)
+}
+
+func TestRunSummaryListFailuresLimit(t *testing.T) {
+	runSummary := NewRunSummary("run-uid", ScopeAll, "")
+	// the failures are found in reverse order, the first ones in the order of
+	// the list are kept
+	for i := maxListFailures + 9; i >= 0; i-- {
+		runSummary.AddListFailure("/v1, Resource=pods", fmt.Sprintf("namespace-%04d", i), errors.New("forbidden"))
+	}
+
+	data := runSummary.Data()
+	assert.Len(t, data.ListFailures, maxListFailures)
+	assert.Equal(t, 10, data.ListFailuresDropped)
+	assert.Equal(t, "namespace-0000", data.ListFailures[0].Namespace)
+	assert.Equal(t, fmt.Sprintf("namespace-%04d", maxListFailures-1), data.ListFailures[maxListFailures-1].Namespace)
+}
@@ -334,12 +334,14 @@ type ClusterAdmissionPolicyFactory struct {
 	rules             []admissionregistrationv1.RuleWithOperations
 	backgroundAudit   bool
 	status            policiesv1.PolicyStatusEnum
+	policyServer      string
 }
 
 func NewClusterAdmissionPolicyFactory() *ClusterAdmissionPolicyFactory {
 	return &ClusterAdmissionPolicyFactory{
 		backgroundAudit: true,
 		status:          policiesv1.PolicyStatusActive,
+		policyServer:    "default",
 	}
 }
 
@@ -385,6 +387,12 @@ func (factory *ClusterAdmissionPolicyFactory) Status(status policiesv1.PolicySta
 	return factory
 }
 
+func (factory *ClusterAdmissionPolicyFactory) PolicyServer(policyServer string) *ClusterAdmissionPolicyFactory {
+	factory.policyServer = policyServer
+
+	return factory
+}
+
 func (factory *ClusterAdmissionPolicyFactory) Build() *policiesv1.ClusterAdmissionPolicy {
 	policy := &policiesv1.ClusterAdmissionPolicy{
 		TypeMeta: metav1.TypeMeta{
@@ -398,7 +406,7 @@ func (factory *ClusterAdmissionPolicyFactory) Build() *policiesv1.ClusterAdmissi
 			NamespaceSelector: factory.namespaceSelector,
 			PolicySpec: policiesv1.PolicySpec{
 				ObjectSelector:  factory.objectSelector,
-				PolicyServer:    "default",
+				PolicyServer:    factory.policyServer,
 				Rules:           factory.rules,
 				BackgroundAudit: factory.backgroundAudit,
 			},
@@ -0,0 +1,36 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/scanner"
+	"k8s.io/client-go/dynamic"
+)
+
+type Config struct {
+	Scanner        *scanner.Scanner
+	PoliciesClient *policies.Client
+	K8sClient      *k8s.Client
+	DynamicClient  dynamic.Interface
+
+	// Namespace restricts the watch to the resources of the given namespace.
+	Namespace string
+	// ClusterWide restricts the watch to the cluster-wide resources.
+	ClusterWide bool
+	// NamespacedOnly restricts the watch to the resources of the namespaces
+	// selected by the K8sClient.
+	NamespacedOnly bool
+
+	// ResyncPeriod is the interval between two full scans.
+	ResyncPeriod time.Duration
+	// Workers is the number of changed resources audited in parallel.
+	Workers int
+	// FullScan scans all the resources within the scope of the watcher.
+	FullScan func(ctx context.Context, runUID string) error
+
+	Logger *slog.Logger
+}
@@ -0,0 +1,537 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/audit-scanner/internal/constants"
+	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/scanner"
+	auditscheme "github.com/kubewarden/audit-scanner/internal/scheme"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// maxRetries is the number of times a resource is audited again after a failure
+// before giving up. The resource is audited anyway by the next full scan.
+const maxRetries = 5
+
+// Watcher keeps the reports up to date by auditing the resources as soon as
+// they, or the policies targeting them, change.
+// A full scan is performed at startup and then periodically, so the reports
+// never drift from the state of the cluster.
+type Watcher struct {
+	scanner        *scanner.Scanner
+	policiesClient *policies.Client
+	k8sClient      *k8s.Client
+	scheme         *runtime.Scheme
+	// namespace restricts the audit to the resources of the given namespace
+	namespace string
+	// clusterWide restricts the audit to the cluster-wide resources
+	clusterWide bool
+	// namespacedOnly restricts the audit to the resources of the selected namespaces
+	namespacedOnly bool
+	resyncPeriod   time.Duration
+	workers        int
+	fullScan       func(ctx context.Context, runUID string) error
+	logger         *slog.Logger
+
+	// resourceInformerFactory creates the informers of the audited resources
+	resourceInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	// policyInformerFactory creates the informers of the Kubewarden policies
+	policyInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	// informers contains the informers of the audited resources, by GVR
+	informers      map[schema.GroupVersionResource]cache.SharedIndexInformer
+	informersMutex sync.RWMutex
+	queue          workqueue.TypedRateLimitingInterface[resourceKey]
+	// policyQueue holds the policies that changed, whose targeted resources
+	// have to be audited again. They are handled by a worker, rather than by
+	// the event handlers, so the policy events are not delayed
+	policyQueue workqueue.TypedInterface[policyKey]
+	// changedPolicies contains the last known state of the policies in
+	// policyQueue, including the deleted ones
+	changedPolicies      map[policyKey]policiesv1.Policy
+	changedPoliciesMutex sync.Mutex
+	// runUID is the UID of the last full scan. The reports of the resources
+	// audited because of a change are labeled with it, so that they are
+	// not deleted when the full scan removes the old reports.
+	runUID      string
+	runUIDMutex sync.RWMutex
+}
+
+// resourceKey identifies a resource that has to be audited.
+type resourceKey struct {
+	gvr schema.GroupVersionResource
+	// key is the namespace/name key used by the informer cache
+	key string
+}
+
+// policyKey identifies a policy that changed.
+type policyKey struct {
+	gvr schema.GroupVersionResource
+	// key is the namespace/name key used by the informer cache
+	key string
+}
+
+// NewWatcher creates a new Watcher.
+func NewWatcher(config Config) (*Watcher, error) {
+	if config.ResyncPeriod <= 0 {
+		return nil, errors.New("the resync period must be greater than zero")
+	}
+	if config.Workers <= 0 {
+		return nil, errors.New("the number of workers must be greater than zero")
+	}
+
+	scheme, err := auditscheme.NewScheme()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheme: %w", err)
+	}
+
+	return &Watcher{
+		scanner:                 config.Scanner,
+		policiesClient:          config.PoliciesClient,
+		k8sClient:               config.K8sClient,
+		scheme:                  scheme,
+		namespace:               config.Namespace,
+		clusterWide:             config.ClusterWide,
+		namespacedOnly:          config.NamespacedOnly,
+		resyncPeriod:            config.ResyncPeriod,
+		workers:                 config.Workers,
+		fullScan:                config.FullScan,
+		logger:                  config.Logger.With("component", "watcher"),
+		resourceInformerFactory: dynamicinformer.NewFilteredDynamicSharedInformerFactory(config.DynamicClient, 0, config.Namespace, nil),
+		policyInformerFactory:   dynamicinformer.NewDynamicSharedInformerFactory(config.DynamicClient, 0),
+		informers:               make(map[schema.GroupVersionResource]cache.SharedIndexInformer),
+		queue:                   workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[resourceKey]()),
+		policyQueue:             workqueue.NewTyped[policyKey](),
+		changedPolicies:         make(map[policyKey]policiesv1.Policy),
+	}, nil
+}
+
+// Run watches the resources and the policies until the context is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.resourceInformerFactory.Shutdown()
+	defer w.policyInformerFactory.Shutdown()
+
+	if err := w.syncInformers(ctx); err != nil {
+		return err
+	}
+	if err := w.startPolicyInformers(ctx); err != nil {
+		return err
+	}
+
+	var workers sync.WaitGroup
+	for range w.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for w.processNextResource(ctx) {
+			}
+		}()
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+
+		for w.processNextPolicy(ctx) {
+		}
+	}()
+
+	w.logger.InfoContext(ctx, "watch started", slog.Duration("resync-period", w.resyncPeriod))
+	w.resync(ctx)
+
+	ticker := time.NewTicker(w.resyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.queue.ShutDown()
+			w.policyQueue.ShutDown()
+			workers.Wait()
+			w.logger.InfoContext(ctx, "watch finished")
+			return nil
+		case <-ticker.C:
+			if err := w.syncInformers(ctx); err != nil {
+				w.logger.ErrorContext(ctx, "error watching the audited resources", slog.String("error", err.Error()))
+			}
+			w.resync(ctx)
+		}
+	}
+}
+
+// resync performs a full scan with a new run UID.
+func (w *Watcher) resync(ctx context.Context) {
+	runUID := uuid.New().String()
+	w.setRunUID(runUID)
+	// the labels of the namespaces may have changed since the policies were cached
+	w.scanner.InvalidatePolicies()
+
+	w.logger.InfoContext(ctx, "full scan started", slog.String("RunUID", runUID))
+	if err := w.fullScan(ctx, runUID); err != nil {
+		w.logger.ErrorContext(ctx, "error performing the full scan",
+			slog.String("error", err.Error()),
+			slog.String("RunUID", runUID))
+	}
+}
+
+// syncInformers starts watching the resources targeted by the policies that
+// are not watched yet.
+func (w *Watcher) syncInformers(ctx context.Context) error {
+	gvrs, err := w.getAuditedGroupVersionResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.informersMutex.Lock()
+	for _, gvr := range gvrs {
+		if _, found := w.informers[gvr]; found {
+			continue
+		}
+
+		informer := w.resourceInformerFactory.ForResource(gvr).Informer()
+		_, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj interface{}, isInInitialList bool) {
+				// the resources existing when the informer starts are audited by the full scan
+				if !isInInitialList {
+					w.enqueueResource(gvr, obj)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if !resourceVersionChanged(oldObj, newObj) {
+					return
+				}
+				w.enqueueResource(gvr, newObj)
+			},
+			// the reports of deleted resources are garbage collected by
+			// Kubernetes, since they are owned by the resource.
+		})
+		if err != nil {
+			w.informersMutex.Unlock()
+			return fmt.Errorf("failed to add event handler for resource %s: %w", gvr.String(), err)
+		}
+		w.informers[gvr] = informer
+		w.logger.DebugContext(ctx, "watching resource", slog.String("resource-GVR", gvr.String()))
+	}
+	w.informersMutex.Unlock()
+
+	w.resourceInformerFactory.Start(ctx.Done())
+	for gvr, synced := range w.resourceInformerFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync the cache of resource %s", gvr.String())
+		}
+	}
+
+	return nil
+}
+
+// getAuditedGroupVersionResources returns the GVRs targeted by the policies
+// within the scope of the watcher.
+func (w *Watcher) getAuditedGroupVersionResources(ctx context.Context) ([]schema.GroupVersionResource, error) {
+	var gvrs []schema.GroupVersionResource
+
+	if w.namespace == "" && !w.namespacedOnly {
+		clusterWidePolicies, err := w.policiesClient.GetClusterWidePolicies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
+		}
+		for gvr := range clusterWidePolicies.PoliciesByGVR {
+			gvrs = append(gvrs, gvr)
+		}
+	}
+
+	if w.clusterWide {
+		return gvrs, nil
+	}
+
+	var nsNames []string
+	if w.namespace != "" {
+		nsNames = []string{w.namespace}
+	} else {
+		nsList, err := w.k8sClient.GetAuditedNamespaces(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain audited namespaces: %w", err)
+		}
+		for _, namespace := range nsList.Items {
+			nsNames = append(nsNames, namespace.Name)
+		}
+	}
+
+	for _, nsName := range nsNames {
+		namespace, err := w.k8sClient.GetNamespace(ctx, nsName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace %s: %w", nsName, err)
+		}
+		namespacedPolicies, err := w.policiesClient.GetPoliciesByNamespace(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", nsName, err)
+		}
+		for gvr := range namespacedPolicies.PoliciesByGVR {
+			gvrs = append(gvrs, gvr)
+		}
+	}
+
+	return gvrs, nil
+}
+
+// startPolicyInformers starts watching the Kubewarden policies.
+func (w *Watcher) startPolicyInformers(ctx context.Context) error {
+	for _, gvr := range policyGroupVersionResources() {
+		informer := w.policyInformerFactory.ForResource(gvr).Informer()
+		_, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj interface{}, isInInitialList bool) {
+				if !isInInitialList {
+					w.enqueuePolicy(ctx, gvr, obj)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if !resourceVersionChanged(oldObj, newObj) {
+					return
+				}
+				w.enqueuePolicy(ctx, gvr, newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				w.enqueuePolicy(ctx, gvr, obj)
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add event handler for policies %s: %w", gvr.String(), err)
+		}
+	}
+
+	w.policyInformerFactory.Start(ctx.Done())
+	for gvr, synced := range w.policyInformerFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync the cache of policies %s", gvr.String())
+		}
+	}
+
+	return nil
+}
+
+// enqueuePolicy adds a policy that changed to the policy queue, keeping its
+// last known state.
+func (w *Watcher) enqueuePolicy(ctx context.Context, gvr schema.GroupVersionResource, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		w.logger.ErrorContext(ctx, "cannot get the key of the policy", slog.String("error", err.Error()))
+		return
+	}
+	policy, err := w.toPolicy(obj)
+	if err != nil {
+		w.logger.ErrorContext(ctx, "cannot handle policy change", slog.String("error", err.Error()))
+		return
+	}
+
+	w.changedPoliciesMutex.Lock()
+	w.changedPolicies[policyKey{gvr: gvr, key: key}] = policy
+	w.changedPoliciesMutex.Unlock()
+	w.policyQueue.Add(policyKey{gvr: gvr, key: key})
+}
+
+// processNextPolicy handles the next policy in the policy queue. It returns
+// false when the queue has been shut down.
+func (w *Watcher) processNextPolicy(ctx context.Context) bool {
+	key, shutdown := w.policyQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.policyQueue.Done(key)
+
+	w.changedPoliciesMutex.Lock()
+	policy, found := w.changedPolicies[key]
+	delete(w.changedPolicies, key)
+	w.changedPoliciesMutex.Unlock()
+	if found {
+		w.onPolicyChange(ctx, policy)
+	}
+
+	return true
+}
+
+// onPolicyChange audits again all the resources targeted by the given policy.
+func (w *Watcher) onPolicyChange(ctx context.Context, policy policiesv1.Policy) {
+	w.logger.InfoContext(ctx, "policy changed, auditing the targeted resources",
+		slog.String("policy", policy.GetUniqueName()))
+
+	// the resources are audited with the policies currently deployed
+	w.scanner.InvalidatePolicies()
+	// the policy may target resources that are not watched yet
+	if err := w.syncInformers(ctx); err != nil {
+		w.logger.ErrorContext(ctx, "error watching the audited resources", slog.String("error", err.Error()))
+	}
+
+	w.informersMutex.RLock()
+	defer w.informersMutex.RUnlock()
+	groupVersionResources, err := w.policiesClient.GetTargetedGroupVersionResources(ctx, policy)
+	if err != nil {
+		w.logger.ErrorContext(ctx, "cannot get the resources targeted by the policy",
+			slog.String("error", err.Error()),
+			slog.String("policy", policy.GetUniqueName()))
+		return
+	}
+	for _, gvr := range groupVersionResources {
+		informer, found := w.informers[gvr]
+		if !found {
+			continue
+		}
+		var resources []interface{}
+		if policy.GetNamespace() != "" {
+			// namespaced policies target only the resources of their namespace
+			resources, err = informer.GetIndexer().ByIndex(cache.NamespaceIndex, policy.GetNamespace())
+			if err != nil {
+				w.logger.ErrorContext(ctx, "cannot list the resources targeted by the policy",
+					slog.String("error", err.Error()),
+					slog.String("policy", policy.GetUniqueName()))
+				continue
+			}
+		} else {
+			resources = informer.GetStore().List()
+		}
+		for _, resource := range resources {
+			w.enqueueResource(gvr, resource)
+		}
+	}
+}
+
+// toPolicy converts an object returned by the dynamic informers into a policy.
+func (w *Watcher) toPolicy(obj interface{}) (policiesv1.Policy, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errors.New("failed to convert object to *unstructured.Unstructured")
+	}
+	typed, err := w.scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object of kind %s: %w", u.GroupVersionKind().String(), err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+	policy, ok := typed.(policiesv1.Policy)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a policy kind", u.GetKind())
+	}
+
+	return policy, nil
+}
+
+func (w *Watcher) enqueueResource(gvr schema.GroupVersionResource, obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		w.logger.Error("cannot get the key of the resource", slog.String("error", err.Error()))
+		return
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok && u.GetNamespace() != "" && w.k8sClient.IsNamespaceSkipped(u.GetNamespace()) {
+		return
+	}
+
+	w.queue.Add(resourceKey{gvr: gvr, key: key})
+}
+
+// processNextResource audits the next resource in the queue. It returns false
+// when the queue has been shut down.
+func (w *Watcher) processNextResource(ctx context.Context) bool {
+	key, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(key)
+
+	w.informersMutex.RLock()
+	informer := w.informers[key.gvr]
+	w.informersMutex.RUnlock()
+
+	obj, exists, err := informer.GetIndexer().GetByKey(key.key)
+	if err != nil || !exists {
+		// the resource has been deleted in the meantime
+		w.queue.Forget(key)
+		return true
+	}
+	resource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		w.queue.Forget(key)
+		return true
+	}
+	if resource.GetNamespace() != "" {
+		// the labels of the namespace may not match the namespace selector
+		audited, err := w.k8sClient.IsNamespaceAudited(ctx, resource.GetNamespace())
+		if err != nil {
+			w.logger.ErrorContext(ctx, "cannot check if the namespace of the resource is audited",
+				slog.String("error", err.Error()),
+				slog.String("resource", key.key))
+		}
+		if err != nil || !audited {
+			w.queue.Forget(key)
+			return true
+		}
+	}
+
+	runUID := w.getRunUID()
+	if err := w.scanner.ScanResource(ctx, key.gvr, *resource.DeepCopy(), runUID); err != nil {
+		if w.queue.NumRequeues(key) < maxRetries {
+			w.logger.WarnContext(ctx, "error auditing resource, retrying",
+				slog.String("error", err.Error()),
+				slog.String("resource", key.key),
+				slog.String("RunUID", runUID))
+			w.queue.AddRateLimited(key)
+			return true
+		}
+		w.logger.ErrorContext(ctx, "error auditing resource",
+			slog.String("error", err.Error()),
+			slog.String("resource", key.key),
+			slog.String("RunUID", runUID))
+	}
+	w.queue.Forget(key)
+
+	return true
+}
+
+func (w *Watcher) setRunUID(runUID string) {
+	w.runUIDMutex.Lock()
+	defer w.runUIDMutex.Unlock()
+	w.runUID = runUID
+}
+
+func (w *Watcher) getRunUID() string {
+	w.runUIDMutex.RLock()
+	defer w.runUIDMutex.RUnlock()
+	return w.runUID
+}
+
+// resourceVersionChanged returns false for the periodic resync events of the
+// informers, where the object did not change.
+func resourceVersionChanged(oldObj, newObj interface{}) bool {
+	oldResource, ok := oldObj.(*unstructured.Unstructured)
+	if !ok {
+		return true
+	}
+	newResource, ok := newObj.(*unstructured.Unstructured)
+	if !ok {
+		return true
+	}
+	return oldResource.GetResourceVersion() != newResource.GetResourceVersion()
+}
+
+// policyGroupVersionResources returns the GVRs of the Kubewarden policies.
+func policyGroupVersionResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "clusteradmissionpolicies"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "clusteradmissionpolicygroups"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "admissionpolicies"},
+		{Group: constants.KubewardenPoliciesGroup, Version: constants.KubewardenPoliciesVersion, Resource: "admissionpolicygroups"},
+	}
+}
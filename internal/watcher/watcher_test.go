@@ -0,0 +1,229 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/audit-scanner/internal/k8s"
+	"github.com/kubewarden/audit-scanner/internal/policies"
+	"github.com/kubewarden/audit-scanner/internal/report"
+	"github.com/kubewarden/audit-scanner/internal/scanner"
+	auditscheme "github.com/kubewarden/audit-scanner/internal/scheme"
+	"github.com/kubewarden/audit-scanner/internal/testutils"
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	eventuallyTimeout = 10 * time.Second
+	eventuallyTick    = 50 * time.Millisecond
+)
+
+func newMockPolicyServerWithCounter(evaluations *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		evaluations.Add(1)
+
+		admissionReview := admissionv1.AdmissionReview{
+			Response: &admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		}
+		response, err := json.Marshal(admissionReview)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = writer.Write(response)
+	}))
+}
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	t.Helper()
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestWatch(t *testing.T) {
+	var evaluations atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithCounter(&evaluations)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace",
+			UID:  "namespace-uid",
+		},
+	}
+
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod",
+			Namespace:       "namespace",
+			UID:             "pod-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// an AdmissionPolicy targeting pods
+	admissionPolicy := testutils.
+		NewAdmissionPolicyFactory().
+		Name("policy").
+		Namespace("namespace").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+	admissionPolicy.SetResourceVersion("1")
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		namespace,
+		pod,
+		admissionPolicy,
+	)
+	clientset := fake.NewSimpleClientset(
+		namespace,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		admissionPolicy,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, k8s.NamespaceFilter{}, 100, logger)
+	policiesClient := policies.NewClient(client, nil, policies.ResourceFilter{}, policies.PolicyFilter{}, "kubewarden", mockPolicyServer.URL, logger)
+	scanner, err := scanner.NewScanner(scanner.Config{
+		PoliciesClient: policiesClient,
+		K8sClient:      k8sClient,
+		ReportStore:    report.NewOpenReportStore(client, logger),
+		ReportKind:     report.ReportKindOpenReport,
+		Parallelization: scanner.ParallelizationConfig{
+			ParallelNamespacesAudits: 1,
+			ParallelResourcesAudits:  1,
+			PoliciesAudits:           1,
+		},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	var fullScans atomic.Int32
+	watcher, err := NewWatcher(Config{
+		Scanner:        scanner,
+		PoliciesClient: policiesClient,
+		K8sClient:      k8sClient,
+		DynamicClient:  dynamicClient,
+		ResyncPeriod:   time.Hour,
+		Workers:        1,
+		FullScan: func(ctx context.Context, runUID string) error {
+			defer fullScans.Add(1)
+			return scanner.ScanAllNamespaces(ctx, runUID)
+		},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	// the full scan is performed at startup
+	require.Eventually(t, func() bool { return fullScans.Load() == 1 }, eventuallyTimeout, eventuallyTick)
+	assert.Equal(t, int32(1), evaluations.Load())
+
+	// a new resource is audited as soon as it's created
+	newPod := pod.DeepCopy()
+	newPod.SetName("new-pod")
+	newPod.SetUID("new-pod-uid")
+	_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("namespace").Create(ctx, toUnstructured(t, newPod), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		newPodReport := openreports.Report{}
+		return client.Get(ctx, types.NamespacedName{Name: "new-pod-uid", Namespace: "namespace"}, &newPodReport) == nil
+	}, eventuallyTimeout, eventuallyTick)
+	assert.Equal(t, int32(2), evaluations.Load())
+
+	// all the resources targeted by a policy are audited when the policy changes
+	admissionPolicy.SetResourceVersion("2")
+	_, err = dynamicClient.Resource(policiesv1.GroupVersion.WithResource("admissionpolicies")).Namespace("namespace").Update(ctx, toUnstructured(t, admissionPolicy), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return evaluations.Load() == 4 }, eventuallyTimeout, eventuallyTick)
+
+	// the results of a deleted policy are removed, since the cached policies
+	// are dropped when the policies change
+	err = client.Delete(ctx, admissionPolicy)
+	require.NoError(t, err)
+	err = dynamicClient.Resource(policiesv1.GroupVersion.WithResource("admissionpolicies")).Namespace("namespace").Delete(ctx, admissionPolicy.GetName(), metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		podReport := openreports.Report{}
+		err := client.Get(ctx, types.NamespacedName{Name: "pod-uid", Namespace: "namespace"}, &podReport)
+		return err == nil && len(podReport.Results) == 0
+	}, eventuallyTimeout, eventuallyTick)
+	assert.Equal(t, int32(4), evaluations.Load())
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), fullScans.Load())
+}